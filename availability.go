@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Restaurants name their time zone; embed the zone database so it
+	// loads on hosts without one
+	_ "time/tzdata"
+)
+
+// DefaultTimeZone is the time zone of restaurants created without one
+const DefaultTimeZone = "UTC"
+
+// weekdays are the day names of availability windows, in week order
+var weekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// Weekdays is stored as a JSON array of day names, e.g. ["sat","sun"]
+type Weekdays []string
+
+// Scan implements sql.Scanner
+func (w *Weekdays) Scan(src interface{}) error {
+	days := Weekdays{}
+	if err := scanJSONArray(src, &days); err != nil {
+		return err
+	}
+	*w = days
+	return nil
+}
+
+// Value implements driver.Valuer
+func (w Weekdays) Value() (driver.Value, error) {
+	if w == nil {
+		w = Weekdays{}
+	}
+	data, err := json.Marshal(w)
+	return string(data), err
+}
+
+// has reports whether the days include day; no days means every day
+func (w Weekdays) has(day time.Weekday) bool {
+	if len(w) == 0 {
+		return true
+	}
+	for _, d := range w {
+		if d == weekdays[(int(day)+6)%7] {
+			return true
+		}
+	}
+	return false
+}
+
+// AvailabilityWindow is a period in which a menu item or category can be
+// ordered, in the time zone of its restaurant: from StartTime to EndTime on
+// Days, between StartDate and EndDate inclusive. No days means every day and
+// missing dates leave the range open. An end time at or before the start
+// time runs past midnight, so equal times cover the whole day.
+type AvailabilityWindow struct {
+	Days      Weekdays `json:"days" db:"days" validate:"max=7,unique,dive,oneof=mon tue wed thu fri sat sun"`
+	StartTime string   `json:"start_time" db:"start_time" validate:"omitempty,datetime=15:04"`
+	EndTime   string   `json:"end_time" db:"end_time" validate:"omitempty,datetime=15:04"`
+	StartDate *string  `json:"start_date" db:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   *string  `json:"end_date" db:"end_date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// minutes returns the start and end of w in minutes after midnight
+func (w AvailabilityWindow) minutes() (int, int) {
+	clock := func(s string) int {
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return 0
+		}
+		return t.Hour()*60 + t.Minute()
+	}
+	return clock(w.StartTime), clock(w.EndTime)
+}
+
+// opensOn reports whether w opens on the day of t: a day of the window
+// within its dates
+func (w AvailabilityWindow) opensOn(t time.Time) bool {
+	date := t.Format("2006-01-02")
+	return w.Days.has(t.Weekday()) &&
+		(w.StartDate == nil || *w.StartDate <= date) &&
+		(w.EndDate == nil || date <= *w.EndDate)
+}
+
+// open reports whether t, in the time zone of the restaurant, falls in w.
+// The hours of a window running past midnight belong to the day it opened.
+func (w AvailabilityWindow) open(t time.Time) bool {
+	start, end := w.minutes()
+	minute := t.Hour()*60 + t.Minute()
+	switch {
+	case start == end:
+		return w.opensOn(t)
+	case start < end:
+		return start <= minute && minute < end && w.opensOn(t)
+	case minute >= start:
+		return w.opensOn(t)
+	case minute < end:
+		return w.opensOn(t.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+// String describes w for error messages, e.g. "sat, sun 07:00-11:00"
+func (w AvailabilityWindow) String() string {
+	var parts []string
+	if len(w.Days) == 0 {
+		parts = append(parts, "every day")
+	} else {
+		parts = append(parts, strings.Join(w.Days, ", "))
+	}
+	if start, end := w.minutes(); start != end {
+		parts = append(parts, w.StartTime+"-"+w.EndTime)
+	}
+	if w.StartDate != nil {
+		parts = append(parts, "from "+*w.StartDate)
+	}
+	if w.EndDate != nil {
+		parts = append(parts, "until "+*w.EndDate)
+	}
+	return strings.Join(parts, " ")
+}
+
+// scheduleOpen reports whether t falls in any of windows; an empty
+// schedule is always open
+func scheduleOpen(windows []AvailabilityWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.open(t) {
+			return true
+		}
+	}
+	return len(windows) == 0
+}
+
+// describeSchedule describes windows for error messages
+func describeSchedule(windows []AvailabilityWindow) string {
+	descriptions := make([]string, len(windows))
+	for i, w := range windows {
+		descriptions[i] = w.String()
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// AvailabilityRequest replaces the availability schedule of a menu item or
+// category. An empty list of windows makes it available at all times.
+type AvailabilityRequest struct {
+	Windows []AvailabilityWindow `json:"windows" validate:"max=20,dive"`
+}
+
+// check returns the problems the validate tags cannot express: date
+// ranges must not end before they start
+func (r AvailabilityRequest) check() []FieldError {
+	var fields []FieldError
+	for i, w := range r.Windows {
+		if w.StartDate != nil && w.EndDate != nil && *w.EndDate < *w.StartDate {
+			field := fmt.Sprintf("windows[%d].end_date", i)
+			fields = append(fields, FieldError{Field: field, Rule: "gtefield", Message: field + " must not be before start_date"})
+		}
+	}
+	return fields
+}
+
+// windows returns the windows of the request as they are stored, with
+// days in week order and times as HH:MM, missing ones at midnight
+func (r AvailabilityRequest) windows() []AvailabilityWindow {
+	windows := make([]AvailabilityWindow, len(r.Windows))
+	for i, w := range r.Windows {
+		days := Weekdays{}
+		for _, day := range weekdays {
+			for _, d := range w.Days {
+				if d == day {
+					days = append(days, day)
+				}
+			}
+		}
+		w.Days = days
+		start, end := w.minutes()
+		w.StartTime = fmt.Sprintf("%02d:%02d", start/60, start%60)
+		w.EndTime = fmt.Sprintf("%02d:%02d", end/60, end%60)
+		windows[i] = w
+	}
+	return windows
+}
+
+// restaurantLocation returns the location of a restaurant's time zone,
+// UTC if it cannot be loaded
+func restaurantLocation(timeZone string) *time.Location {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// menuClock evaluates the schedules of a restaurant's menu at a time
+type menuClock struct {
+	// At is in the time zone of the restaurant
+	At time.Time
+	// Categories holds the categories of the restaurant, with their
+	// schedules, by ID
+	Categories map[int]Category
+}
+
+// newMenuClock returns a menuClock for the menu of restaurant at t
+func newMenuClock(restaurant Restaurant, categories []Category, t time.Time) menuClock {
+	clock := menuClock{At: t.In(restaurantLocation(restaurant.TimeZone)), Categories: make(map[int]Category, len(categories))}
+	for _, c := range categories {
+		clock.Categories[c.ID] = c
+	}
+	return clock
+}
+
+// closedCategory returns the category keeping items of category id from
+// being ordered: the category itself or one of its parents whose schedule
+// is closed, or nil
+func (c menuClock) closedCategory(id *int) *Category {
+	seen := make(map[int]bool)
+	for id != nil && !seen[*id] {
+		category, ok := c.Categories[*id]
+		if !ok {
+			break
+		}
+		if !scheduleOpen(category.Availability, c.At) {
+			return &category
+		}
+		seen[*id] = true
+		id = category.ParentID
+	}
+	return nil
+}
+
+// orderable reports whether m can be ordered: it is available and its
+// schedule and those of its categories are open
+func (c menuClock) orderable(m MenuItem) bool {
+	return m.IsAvailable && scheduleOpen(m.Availability, c.At) && c.closedCategory(m.CategoryID) == nil
+}
+
+// bundleSlots returns a copy of slots with their items flagged with
+// whether they are orderable
+func (c menuClock) bundleSlots(slots []BundleSlot) []BundleSlot {
+	if slots == nil {
+		return nil
+	}
+	flagged := make([]BundleSlot, len(slots))
+	for i, slot := range slots {
+		slot.Items = append([]BundleSlotItem{}, slot.Items...)
+		for j := range slot.Items {
+			orderable := c.orderable(slot.Items[j].menuItem())
+			slot.Items[j].IsOrderable = &orderable
+		}
+		flagged[i] = slot
+	}
+	return flagged
+}
+
+// bundleOrderable reports whether every slot of m, if it is a bundle, has
+// an item that can be ordered
+func (c menuClock) bundleOrderable(m MenuItem) bool {
+	for _, slot := range m.BundleSlots {
+		orderable := false
+		for _, item := range slot.Items {
+			orderable = orderable || c.orderable(item.menuItem())
+		}
+		if !orderable {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduleProblem returns the problem with ordering m as field while its
+// schedule or that of one of its categories is closed
+func (c menuClock) scheduleProblem(field string, m MenuItem) FieldError {
+	at := c.At.Format("Mon 2006-01-02 15:04 MST")
+	message := fmt.Sprintf("%s is not available on %s; it can be ordered %s", field, at, describeSchedule(m.Availability))
+	if category := c.closedCategory(m.CategoryID); category != nil && scheduleOpen(m.Availability, c.At) {
+		message = fmt.Sprintf("%s is not available on %s; %s can be ordered %s", field, at, category.Name, describeSchedule(category.Availability))
+	}
+	return FieldError{Field: field, Rule: "schedule", Message: message}
+}
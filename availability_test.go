@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvailabilityWindowOpen(t *testing.T) {
+	date := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		window AvailabilityWindow
+		at     string // a local time
+		open   bool
+	}{
+		{name: "inside", window: AvailabilityWindow{StartTime: "07:00", EndTime: "11:00"}, at: "2024-06-03 07:00", open: true},
+		{name: "at the end", window: AvailabilityWindow{StartTime: "07:00", EndTime: "11:00"}, at: "2024-06-03 11:00"},
+		{name: "weekend on a saturday", window: AvailabilityWindow{Days: Weekdays{"sat", "sun"}}, at: "2024-06-01 20:00", open: true},
+		{name: "weekend on a monday", window: AvailabilityWindow{Days: Weekdays{"sat", "sun"}}, at: "2024-06-03 20:00"},
+		{name: "past midnight", window: AvailabilityWindow{Days: Weekdays{"fri"}, StartTime: "22:00", EndTime: "02:00"}, at: "2024-06-01 01:30", open: true},
+		{name: "past midnight, next night", window: AvailabilityWindow{Days: Weekdays{"fri"}, StartTime: "22:00", EndTime: "02:00"}, at: "2024-06-02 01:30"},
+		{name: "before the dates", window: AvailabilityWindow{StartDate: date("2024-12-01"), EndDate: date("2024-12-31")}, at: "2024-11-30 12:00"},
+		{name: "on the last date", window: AvailabilityWindow{StartDate: date("2024-12-01"), EndDate: date("2024-12-31")}, at: "2024-12-31 23:59", open: true},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse("2006-01-02 15:04", tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		window := AvailabilityRequest{Windows: []AvailabilityWindow{tt.window}}.windows()[0]
+		if got := window.open(at); got != tt.open {
+			t.Errorf("%s: got open %v, want %v", tt.name, got, tt.open)
+		}
+	}
+}
+
+// TestAvailability schedules a breakfast category and a weekend item and
+// checks the menu at several times and the orders placed now, for the items
+// and as the components of a bundle
+func TestAvailability(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Availability", TimeZone: "Europe/Paris"})
+		ctx := f.ctx
+		breakfast, err := store.CreateCategory(ctx, CategoryRequest{RestaurantID: f.restaurant.ID, Name: "Breakfast", IsVisible: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		weekdays := Weekdays{"mon", "tue", "wed", "thu", "fri"}
+		breakfast, err = store.UpdateCategoryAvailability(ctx, breakfast.ID, AvailabilityRequest{Windows: []AvailabilityWindow{{Days: weekdays, StartTime: "07:00", EndTime: "11:00"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(breakfast.Availability) != 1 || breakfast.Availability[0].String() != "mon, tue, wed, thu, fri 07:00-11:00" {
+			t.Fatalf("got %+v, want the weekday mornings", breakfast.Availability)
+		}
+		croissant := f.menuItem(CreateMenuItemRequest{Name: "Croissant", CategoryID: &breakfast.ID, IsAvailable: true})
+		roast := f.item("Roast")
+		roast, err = store.UpdateMenuItemAvailability(ctx, roast.ID, AvailabilityRequest{Windows: []AvailabilityWindow{{Days: Weekdays{"sun", "sat"}}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roast.Availability) != 1 || roast.Availability[0].StartTime != "00:00" || roast.Availability[0].Days[0] != "sat" {
+			t.Fatalf("got %+v, want saturdays and sundays all day", roast.Availability)
+		}
+		soup := f.item("Soup")
+		brunch := f.menuItem(CreateMenuItemRequest{Name: "Brunch", Price: 1500, Kind: "bundle", IsAvailable: true})
+		brunch, err = store.UpdateBundleSlots(ctx, brunch.ID, BundleSlotsRequest{Slots: []BundleSlotRequest{
+			{Name: "Main", Items: []BundleSlotItemRequest{{MenuItemID: croissant.ID}, {MenuItemID: soup.ID}}},
+			{Name: "Side", Items: []BundleSlotItemRequest{{MenuItemID: roast.ID}}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		main := brunch.BundleSlots[0].ID
+
+		tests := []struct {
+			at        string
+			breakfast bool
+			roast     bool
+		}{
+			{at: "2024-06-03T07:30:00+02:00", breakfast: true},
+			{at: "2024-06-03T09:30:00Z"}, // 11:30 in Paris
+			{at: "2024-06-01T08:00:00+02:00", roast: true},
+		}
+		for _, tt := range tests {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			menu, err := store.GetRestaurantMenu(ctx, f.restaurant.ID, false, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if menu.At.Location().String() != "Europe/Paris" || !menu.At.Equal(at) {
+				t.Errorf("%s: got menu at %s, want the same time in Paris", tt.at, menu.At)
+			}
+			category := menu.Categories[0]
+			if category.IsOrderable != tt.breakfast || category.Items[0].IsOrderable != tt.breakfast {
+				t.Errorf("%s: got breakfast %v and croissant %v, want %v", tt.at, category.IsOrderable, category.Items[0].IsOrderable, tt.breakfast)
+			}
+			entries := make(map[int]MenuEntry)
+			for _, e := range menu.Uncategorized {
+				entries[e.ID] = e
+			}
+			if entries[roast.ID].IsOrderable != tt.roast || !entries[soup.ID].IsOrderable {
+				t.Errorf("%s: got roast %v and soup %v, want %v and true", tt.at, entries[roast.ID].IsOrderable, entries[soup.ID].IsOrderable, tt.roast)
+			}
+			// The brunch needs the roast; its croissant follows breakfast
+			slots := entries[brunch.ID].BundleSlots
+			if got := slots[0].Items[0].IsOrderable; got == nil || *got != tt.breakfast || entries[brunch.ID].IsOrderable != tt.roast {
+				t.Errorf("%s: got brunch %v with croissant %v, want %v with %v", tt.at, entries[brunch.ID].IsOrderable, got, tt.roast, tt.breakfast)
+			}
+		}
+
+		// Orders are checked now: give the roast a window that ended yesterday
+		yesterday := time.Now().In(restaurantLocation("Europe/Paris")).AddDate(0, 0, -1).Format("2006-01-02")
+		if _, err := store.UpdateMenuItemAvailability(ctx, roast.ID, AvailabilityRequest{Windows: []AvailabilityWindow{{EndDate: &yesterday}}}); err != nil {
+			t.Fatal(err)
+		}
+		req := f.order(line(soup), line(roast))
+		if _, err := store.QuoteOrder(ctx, req); !isOrderRule(err, "items[1].menu_item_id", "schedule") {
+			t.Errorf("got %v, want rule schedule on the roast", err)
+		}
+		withSoup := CreateOrderItemRequest{MenuItemID: brunch.ID, Quantity: 1, BundleChoices: []BundleChoice{{SlotID: main, MenuItemID: soup.ID}}}
+		if _, err := store.QuoteOrder(ctx, f.order(withSoup)); !isOrderRule(err, "items[0].bundle_choices", "schedule") {
+			t.Errorf("brunch: got %v, want rule schedule on its roast", err)
+		}
+		if _, err := store.UpdateMenuItemAvailability(ctx, roast.ID, AvailabilityRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.QuoteOrder(ctx, req); err != nil {
+			t.Errorf("got %v, want the roast orderable without a schedule", err)
+		}
+
+		// A croissant chosen in the brunch follows the breakfast schedule
+		saturday := time.Now().In(restaurantLocation("Europe/Paris")).AddDate(0, 0, 1)
+		for saturday.Weekday() != time.Saturday {
+			saturday = saturday.AddDate(0, 0, 1)
+		}
+		saturday = time.Date(saturday.Year(), saturday.Month(), saturday.Day(), 8, 0, 0, 0, saturday.Location())
+		withCroissant := CreateOrderItemRequest{MenuItemID: brunch.ID, Quantity: 1, BundleChoices: []BundleChoice{{SlotID: main, MenuItemID: croissant.ID}}}
+		req = f.order(withCroissant)
+		req.ScheduledFor = &saturday
+		if _, err := store.QuoteOrder(ctx, req); !isOrderRule(err, "items[0].bundle_choices[0].menu_item_id", "schedule") {
+			t.Errorf("croissant on a saturday: got %v, want rule schedule on the choice", err)
+		}
+		req.Items = []CreateOrderItemRequest{withSoup}
+		if _, err := store.QuoteOrder(ctx, req); err != nil {
+			t.Errorf("soup on a saturday: got %v, want a quote", err)
+		}
+	})
+}
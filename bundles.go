@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MenuItemKinds lists the values allowed in menu_items.kind: an item is a
+// single dish and a bundle is a combo of items sold at its own price
+var MenuItemKinds = []string{"item", "bundle"}
+
+// BundleSlot is one part of a bundle, such as its main or its drink. Every
+// bundle ordered gets Quantity units of one of the slot's items.
+type BundleSlot struct {
+	ID       int              `json:"id" db:"id"`
+	BundleID int              `json:"bundle_id" db:"bundle_id"`
+	Name     string           `json:"name" db:"name"`
+	Quantity int              `json:"quantity" db:"quantity"`
+	Items    []BundleSlotItem `json:"items"`
+}
+
+// BundleSlotItem is a menu item that can fill a bundle slot. Its price delta
+// is added to the price of the bundle, e.g. for a premium side; its name,
+// availability and schedule are those of the menu item.
+type BundleSlotItem struct {
+	MenuItemID  int    `json:"menu_item_id" db:"menu_item_id"`
+	Name        string `json:"name"`
+	PriceDelta  Money  `json:"price_delta" db:"price_delta"`
+	IsAvailable bool   `json:"is_available"`
+	// IsOrderable is only set in restaurant menus: whether the item can
+	// fill the slot at the time of the menu
+	IsOrderable *bool `json:"is_orderable,omitempty"`
+
+	// availability and categoryID are those of the menu item, to check its
+	// schedule and those of its categories
+	availability []AvailabilityWindow
+	categoryID   *int
+}
+
+// menuItem returns the menu item filling the slot as far as the menu clock
+// needs it
+func (it BundleSlotItem) menuItem() MenuItem {
+	return MenuItem{ID: it.MenuItemID, IsAvailable: it.IsAvailable, Availability: it.availability, CategoryID: it.categoryID}
+}
+
+// BundleChoice picks the menu item filling a slot of an ordered bundle
+type BundleChoice struct {
+	SlotID     int `json:"slot_id" validate:"required,gt=0"`
+	MenuItemID int `json:"menu_item_id" validate:"required,gt=0"`
+}
+
+// bundleComponent is the menu item filling one slot of an ordered bundle
+type bundleComponent struct {
+	Slot BundleSlot
+	Item BundleSlotItem
+}
+
+// components returns the item filling each slot of bundle m, in slot
+// order, given the choices of an order line. A slot with a single item
+// needs no choice; slots without a valid choice are left out.
+func (m MenuItem) components(choices []BundleChoice) []bundleComponent {
+	var components []bundleComponent
+	for _, slot := range m.BundleSlots {
+		chosen := 0
+		if len(slot.Items) == 1 {
+			chosen = slot.Items[0].MenuItemID
+		}
+		for _, c := range choices {
+			if c.SlotID == slot.ID {
+				chosen = c.MenuItemID
+			}
+		}
+		for _, item := range slot.Items {
+			if item.MenuItemID == chosen {
+				components = append(components, bundleComponent{Slot: slot, Item: item})
+				break
+			}
+		}
+	}
+	return components
+}
+
+// bundleProblems returns the problems with the bundle choices of item i of
+// an order at the time of clock: bundles need an orderable item for each of
+// their slots, and
+// only bundles take choices
+func (m MenuItem) bundleProblems(i int, choices []BundleChoice, clock menuClock) []FieldError {
+	var fields []FieldError
+	field := fmt.Sprintf("items[%d].bundle_choices", i)
+	if m.Kind != "bundle" {
+		if len(choices) > 0 {
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "excluded",
+				Message: fmt.Sprintf("%s is only allowed for bundles", field),
+			})
+		}
+		return fields
+	}
+	if len(m.BundleSlots) == 0 {
+		return append(fields, FieldError{
+			Field:   fmt.Sprintf("items[%d].menu_item_id", i),
+			Rule:    "available",
+			Message: fmt.Sprintf("items[%d].menu_item_id is a bundle without slots", i),
+		})
+	}
+
+	chosen := make(map[int]bool)
+	for j, c := range choices {
+		var slot *BundleSlot
+		for k := range m.BundleSlots {
+			if m.BundleSlots[k].ID == c.SlotID {
+				slot = &m.BundleSlots[k]
+			}
+		}
+		if slot == nil {
+			fields = append(fields, FieldError{
+				Field:   fmt.Sprintf("%s[%d].slot_id", field, j),
+				Rule:    "exists",
+				Message: fmt.Sprintf("%s[%d].slot_id is not a slot of bundle %d", field, j, m.ID),
+			})
+			continue
+		}
+		if chosen[slot.ID] {
+			fields = append(fields, FieldError{
+				Field:   fmt.Sprintf("%s[%d].slot_id", field, j),
+				Rule:    "unique",
+				Message: fmt.Sprintf("%s[%d].slot_id chooses the %s again", field, j, slot.Name),
+			})
+			continue
+		}
+		chosen[slot.ID] = true
+
+		itemField := fmt.Sprintf("%s[%d].menu_item_id", field, j)
+		var item *BundleSlotItem
+		for k := range slot.Items {
+			if slot.Items[k].MenuItemID == c.MenuItemID {
+				item = &slot.Items[k]
+			}
+		}
+		switch {
+		case item == nil:
+			fields = append(fields, FieldError{
+				Field:   itemField,
+				Rule:    "exists",
+				Message: fmt.Sprintf("%s is not a choice for the %s", itemField, slot.Name),
+			})
+		case !item.IsAvailable:
+			fields = append(fields, FieldError{
+				Field:   itemField,
+				Rule:    "available",
+				Message: fmt.Sprintf("%s is not available", itemField),
+			})
+		case !clock.orderable(item.menuItem()):
+			fields = append(fields, clock.scheduleProblem(itemField, item.menuItem()))
+		}
+	}
+
+	for _, slot := range m.BundleSlots {
+		if chosen[slot.ID] {
+			continue
+		}
+		switch {
+		case len(slot.Items) != 1:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "required",
+				Message: fmt.Sprintf("%s must choose the %s", field, slot.Name),
+			})
+		case !slot.Items[0].IsAvailable:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "available",
+				Message: fmt.Sprintf("the %s of items[%d] is not available", slot.Name, i),
+			})
+		case !clock.orderable(slot.Items[0].menuItem()):
+			problem := clock.scheduleProblem(fmt.Sprintf("the %s of items[%d]", slot.Name, i), slot.Items[0].menuItem())
+			problem.Field = field
+			fields = append(fields, problem)
+		}
+	}
+	return fields
+}
+
+// findBundleSlot returns the slot of slots named name, ignoring case like
+// the column, or nil
+func findBundleSlot(slots []BundleSlot, name string) *BundleSlot {
+	for i := range slots {
+		if strings.EqualFold(slots[i].Name, name) {
+			return &slots[i]
+		}
+	}
+	return nil
+}
+
+// findBundleSlotRequest returns the slot of slots named name, ignoring case,
+// or nil
+func findBundleSlotRequest(slots []BundleSlotRequest, name string) *BundleSlotRequest {
+	for i := range slots {
+		if strings.EqualFold(slots[i].Name, name) {
+			return &slots[i]
+		}
+	}
+	return nil
+}
+
+// BundleSlotsRequest for replacing the slots of a bundle. Slots are matched
+// to the existing ones by name, so the ones kept keep their IDs.
+type BundleSlotsRequest struct {
+	Slots []BundleSlotRequest `json:"slots" validate:"max=10,dive"`
+}
+
+// BundleSlotRequest is one slot of a BundleSlotsRequest
+type BundleSlotRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Quantity defaults to 1
+	Quantity int                     `json:"quantity" validate:"gte=0,max=100"`
+	Items    []BundleSlotItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+// BundleSlotItemRequest is one item of a BundleSlotRequest
+type BundleSlotItemRequest struct {
+	MenuItemID int   `json:"menu_item_id" validate:"required,gt=0"`
+	PriceDelta Money `json:"price_delta" validate:"gte=-99999999.99,max=99999999.99"`
+}
+
+// check returns the problems the validate tags cannot express: slot names
+// are unique regardless of case, like the column, and a slot lists each
+// item once
+func (r BundleSlotsRequest) check() []FieldError {
+	var fields []FieldError
+	names := make(map[string]bool)
+	for i, slot := range r.Slots {
+		prefix := fmt.Sprintf("slots[%d]", i)
+		if names[strings.ToLower(slot.Name)] {
+			fields = append(fields, FieldError{Field: prefix + ".name", Rule: "unique", Message: prefix + ".name must not repeat another slot's name"})
+		}
+		names[strings.ToLower(slot.Name)] = true
+
+		items := make(map[int]bool)
+		for j, item := range slot.Items {
+			if items[item.MenuItemID] {
+				field := fmt.Sprintf("%s.items[%d].menu_item_id", prefix, j)
+				fields = append(fields, FieldError{Field: field, Rule: "unique", Message: field + " is already an item of the slot"})
+			}
+			items[item.MenuItemID] = true
+		}
+	}
+	return fields
+}
+
+// problems returns the problems with filling the slots of bundle with the
+// items of the request, given the menu items it references by ID: only
+// bundles have slots, and they are filled with single items from the
+// bundle's restaurant
+func (r BundleSlotsRequest) problems(bundle MenuItem, menuItems map[int]MenuItem) []FieldError {
+	if bundle.Kind != "bundle" {
+		return []FieldError{{
+			Field:   "slots",
+			Rule:    "bundle",
+			Message: fmt.Sprintf("menu item %d is not a bundle", bundle.ID),
+		}}
+	}
+	var fields []FieldError
+	for i, slot := range r.Slots {
+		for j, item := range slot.Items {
+			field := fmt.Sprintf("slots[%d].items[%d].menu_item_id", i, j)
+			m, ok := menuItems[item.MenuItemID]
+			switch {
+			case !ok:
+				fields = append(fields, FieldError{
+					Field:   field,
+					Rule:    "exists",
+					Message: fmt.Sprintf("%s refers to a menu item that does not exist", field),
+				})
+			case m.RestaurantID != bundle.RestaurantID:
+				fields = append(fields, FieldError{
+					Field:   field,
+					Rule:    "same_restaurant",
+					Message: fmt.Sprintf("%s is not on the menu of restaurant %d", field, bundle.RestaurantID),
+				})
+			case m.Kind != "item":
+				fields = append(fields, FieldError{
+					Field:   field,
+					Rule:    "item",
+					Message: fmt.Sprintf("%s is a bundle; bundles are made of single items", field),
+				})
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestBundles fills the slots of a bundle, orders it and checks that the
+// order gets one line for the bundle and one for each of its components
+func TestBundles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Bundles"})
+		ctx := f.ctx
+		burger, fries, cola, shake := f.item("Burger").ID, f.item("Fries").ID, f.item("Cola").ID, f.item("Shake").ID
+		juice := f.menuItem(CreateMenuItemRequest{Name: "Juice"}).ID
+
+		meal := f.menuItem(CreateMenuItemRequest{Name: "Burger Meal", Price: 1200, Kind: "bundle", IsAvailable: true})
+		if _, err := store.UpdateBundleSlots(ctx, burger, BundleSlotsRequest{Slots: []BundleSlotRequest{{Name: "Side", Items: []BundleSlotItemRequest{{MenuItemID: fries}}}}}); !isBundleRule(err, "bundle") {
+			t.Errorf("slots on an item: got %v, want rule bundle", err)
+		}
+		if _, err := store.UpdateBundleSlots(ctx, meal.ID, BundleSlotsRequest{Slots: []BundleSlotRequest{{Name: "Main", Items: []BundleSlotItemRequest{{MenuItemID: meal.ID}}}}}); !isBundleRule(err, "item") {
+			t.Errorf("bundle in a bundle: got %v, want rule item", err)
+		}
+
+		meal, err := store.UpdateBundleSlots(ctx, meal.ID, BundleSlotsRequest{Slots: []BundleSlotRequest{
+			{Name: "Main", Items: []BundleSlotItemRequest{{MenuItemID: burger}}},
+			{Name: "Side", Quantity: 2, Items: []BundleSlotItemRequest{{MenuItemID: fries}}},
+			{Name: "Drink", Items: []BundleSlotItemRequest{{MenuItemID: cola}, {MenuItemID: shake, PriceDelta: 150}, {MenuItemID: juice}}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meal.Kind != "bundle" || len(meal.BundleSlots) != 3 || meal.BundleSlots[1].Quantity != 2 || meal.BundleSlots[2].Items[1].Name != "Shake" {
+			t.Fatalf("got %+v, want a bundle with 3 slots", meal)
+		}
+		drink := meal.BundleSlots[2].ID
+
+		tests := []struct {
+			name    string
+			choices []BundleChoice
+			price   Money
+			rule    string
+		}{
+			{name: "cola", choices: []BundleChoice{{SlotID: drink, MenuItemID: cola}}, price: 1200},
+			{name: "shake", choices: []BundleChoice{{SlotID: drink, MenuItemID: shake}}, price: 1350},
+			{name: "no drink", rule: "required"},
+			{name: "juice", choices: []BundleChoice{{SlotID: drink, MenuItemID: juice}}, rule: "available"},
+			{name: "fries as drink", choices: []BundleChoice{{SlotID: drink, MenuItemID: fries}}, rule: "exists"},
+		}
+		for _, tt := range tests {
+			quote, err := store.QuoteOrder(ctx, f.order(CreateOrderItemRequest{MenuItemID: meal.ID, Quantity: 1, BundleChoices: tt.choices}))
+			if tt.rule != "" {
+				if !isOrderRule(err, "", tt.rule) {
+					t.Errorf("%s: got %v, want rule %s", tt.name, err, tt.rule)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if quote.Items[0].UnitPrice != tt.price {
+				t.Errorf("%s: got unit price %s, want %s", tt.name, quote.Items[0].UnitPrice, tt.price)
+			}
+		}
+
+		order, err := store.CreateOrder(ctx, f.order(CreateOrderItemRequest{MenuItemID: meal.ID, Quantity: 2, BundleChoices: []BundleChoice{{SlotID: drink, MenuItemID: shake}}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Pricing.Subtotal != 2700 || len(order.Items) != 4 {
+			t.Fatalf("got subtotal %s and %d lines, want 27.00 and 4 lines", order.Pricing.Subtotal, len(order.Items))
+		}
+		want := []struct {
+			menuItemID, quantity int
+			slot                 string
+		}{{meal.ID, 2, ""}, {burger, 2, "Main"}, {fries, 4, "Side"}, {shake, 2, "Drink"}}
+		for i, w := range want {
+			line := order.Items[i]
+			bundleLine := i > 0 && line.BundleItemID != nil && *line.BundleItemID == order.Items[0].ID
+			if line.MenuItemID != w.menuItemID || line.Quantity != w.quantity || line.BundleSlot != w.slot || bundleLine != (i > 0) {
+				t.Errorf("line %d: got %+v, want %+v", i, line, w)
+			}
+		}
+	})
+}
+
+// isBundleRule reports whether err is a *BundleError whose first problem
+// breaks rule
+func isBundleRule(err error, rule string) bool {
+	var berr *BundleError
+	return errors.As(err, &berr) && berr.Fields[0].Rule == rule
+}
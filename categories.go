@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Category groups the menu items of a restaurant for display. Categories
+// nest under an optional parent and are shown by position, then by name.
+type Category struct {
+	ID           int `json:"id" db:"id"`
+	RestaurantID int `json:"restaurant_id" db:"restaurant_id"`
+	// ParentID is null for top-level categories
+	ParentID    *int      `json:"parent_id" db:"parent_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Position    int       `json:"position" db:"position"`
+	IsVisible   bool      `json:"is_visible" db:"is_visible"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// Availability lists the windows the items of the category and its
+	// subcategories can be ordered in, on top of their own
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
+}
+
+// CategoryRequest for creating or updating a category. The restaurant of a
+// category cannot change.
+type CategoryRequest struct {
+	RestaurantID int    `json:"restaurant_id" validate:"required,gt=0"`
+	ParentID     *int   `json:"parent_id" validate:"omitempty,gt=0"`
+	Name         string `json:"name" validate:"required,max=100"`
+	Description  string `json:"description" validate:"max=1000"`
+	Position     int    `json:"position" validate:"gte=0,max=10000"`
+	IsVisible    bool   `json:"is_visible"`
+}
+
+// problems returns the problems with storing the request over current, or
+// as a new category when current is nil, given the categories of its
+// restaurant and its parent: the parent must be a category of the same
+// restaurant, and a category cannot be moved under itself
+func (r CategoryRequest) problems(current *Category, categories map[int]Category) []FieldError {
+	var fields []FieldError
+	if current != nil && current.RestaurantID != r.RestaurantID {
+		fields = append(fields, FieldError{
+			Field:   "restaurant_id",
+			Rule:    "immutable",
+			Message: fmt.Sprintf("restaurant_id cannot be changed from %d", current.RestaurantID),
+		})
+	}
+	if r.ParentID == nil {
+		return fields
+	}
+
+	parent, ok := categories[*r.ParentID]
+	switch {
+	case !ok:
+		return append(fields, FieldError{
+			Field:   "parent_id",
+			Rule:    "exists",
+			Message: "parent_id refers to a category that does not exist",
+		})
+	case parent.RestaurantID != r.RestaurantID:
+		return append(fields, FieldError{
+			Field:   "parent_id",
+			Rule:    "same_restaurant",
+			Message: fmt.Sprintf("parent_id is not a category of restaurant %d", r.RestaurantID),
+		})
+	}
+	if current == nil {
+		return fields
+	}
+	// Walk up from the new parent; reaching the category means the parent
+	// is the category itself or one of its subcategories
+	seen := make(map[int]bool)
+	for p := parent; !seen[p.ID]; {
+		if p.ID == current.ID {
+			return append(fields, FieldError{
+				Field:   "parent_id",
+				Rule:    "cycle",
+				Message: "parent_id must not be the category or one of its subcategories",
+			})
+		}
+		seen[p.ID] = true
+		if p.ParentID == nil {
+			break
+		}
+		if p, ok = categories[*p.ParentID]; !ok {
+			break
+		}
+	}
+	return fields
+}
+
+// findCategory returns the category of categories named name, ignoring case
+// and surrounding spaces like the migration of the old category names, or
+// nil
+func findCategory(categories []Category, name string) *Category {
+	for i := range categories {
+		if strings.EqualFold(categories[i].Name, strings.TrimSpace(name)) {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// nextCategoryPosition returns the position after the last of categories
+func nextCategoryPosition(categories []Category) int {
+	position := 0
+	for _, c := range categories {
+		if c.Position >= position {
+			position = c.Position + 1
+		}
+	}
+	return position
+}
+
+// categoryOfMenuItem returns the problems with filing a menu item of
+// restaurantID under category
+func categoryOfMenuItem(restaurantID int, category Category) []FieldError {
+	if category.RestaurantID == restaurantID {
+		return nil
+	}
+	return []FieldError{{
+		Field:   "category_id",
+		Rule:    "same_restaurant",
+		Message: fmt.Sprintf("category_id is not a category of restaurant %d", restaurantID),
+	}}
+}
+
+// Menu is the full menu of a restaurant: its categories in display order,
+// each with its menu items and subcategories, as it stands at a time
+type Menu struct {
+	RestaurantID int    `json:"restaurant_id"`
+	Currency     string `json:"currency"`
+	TimeZone     string `json:"time_zone"`
+	// At is the time the schedules were evaluated at, in the time zone of
+	// the restaurant
+	At         time.Time      `json:"at"`
+	Categories []MenuCategory `json:"categories"`
+	// Uncategorized lists the menu items without a category
+	Uncategorized []MenuEntry `json:"uncategorized"`
+}
+
+// MenuCategory is a category of a Menu with its menu items, by name, and
+// its subcategories. IsOrderable is false while the schedule of the
+// category or one of its parents is closed.
+type MenuCategory struct {
+	Category
+	IsOrderable   bool           `json:"is_orderable"`
+	Items         []MenuEntry    `json:"items"`
+	Subcategories []MenuCategory `json:"subcategories"`
+}
+
+// MenuEntry is a menu item of a Menu. IsOrderable is whether it can be
+// ordered at the time of the menu: it is available, its schedule and those
+// of its categories are open and, for a bundle, every slot has an item that
+// is orderable in the same way, which its slot items are flagged with.
+type MenuEntry struct {
+	MenuItem
+	IsOrderable bool `json:"is_orderable"`
+}
+
+// buildMenu arranges the categories and menu items of a restaurant into its
+// menu at time at. Hidden categories are left out with their subcategories
+// and items unless includeHidden is set.
+func buildMenu(restaurant Restaurant, categories []Category, menuItems []MenuItem, includeHidden bool, at time.Time) *Menu {
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+	sort.Slice(menuItems, func(i, j int) bool {
+		a, b := menuItems[i], menuItems[j]
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+	clock := newMenuClock(restaurant, categories, at)
+
+	children := make(map[int][]Category) // by parent ID, 0 for the top level
+	for _, c := range categories {
+		parentID := 0
+		if c.ParentID != nil {
+			parentID = *c.ParentID
+		}
+		children[parentID] = append(children[parentID], c)
+	}
+	items := make(map[int][]MenuEntry) // by category ID, 0 for none
+	for _, m := range menuItems {
+		categoryID := 0
+		if m.CategoryID != nil {
+			categoryID = *m.CategoryID
+		}
+		m.BundleSlots = clock.bundleSlots(m.BundleSlots)
+		items[categoryID] = append(items[categoryID], MenuEntry{MenuItem: m, IsOrderable: clock.orderable(m) && clock.bundleOrderable(m)})
+	}
+
+	var tree func(parentID int) []MenuCategory
+	tree = func(parentID int) []MenuCategory {
+		nodes := []MenuCategory{}
+		for _, c := range children[parentID] {
+			if !c.IsVisible && !includeHidden {
+				continue
+			}
+			id := c.ID
+			node := MenuCategory{Category: c, IsOrderable: clock.closedCategory(&id) == nil, Items: items[c.ID], Subcategories: tree(c.ID)}
+			if node.Items == nil {
+				node.Items = []MenuEntry{}
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+
+	menu := &Menu{
+		RestaurantID:  restaurant.ID,
+		Currency:      restaurant.Currency,
+		TimeZone:      restaurant.TimeZone,
+		At:            clock.At,
+		Categories:    tree(0),
+		Uncategorized: items[0],
+	}
+	if menu.Uncategorized == nil {
+		menu.Uncategorized = []MenuEntry{}
+	}
+	return menu
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCategories files menu items under nested categories and checks the
+// menu tree, the rules on parents and what renaming and deleting a
+// category does to its items and subcategories
+func TestCategories(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Categories"})
+		ctx, restaurant := f.ctx, f.restaurant
+		other := newFixture(t, store, CreateRestaurantRequest{Name: "Other"}).restaurant
+		category := func(req CategoryRequest) *Category {
+			c, err := store.CreateCategory(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return c
+		}
+
+		drinks := category(CategoryRequest{RestaurantID: restaurant.ID, Name: "Drinks", Position: 2, IsVisible: true})
+		soft := category(CategoryRequest{RestaurantID: restaurant.ID, ParentID: &drinks.ID, Name: "Soft drinks", IsVisible: true})
+		secret := category(CategoryRequest{RestaurantID: restaurant.ID, Name: "Secret menu", Position: 1})
+		elsewhere := category(CategoryRequest{RestaurantID: other.ID, Name: "Elsewhere", IsVisible: true})
+
+		// Names without an ID share a category regardless of case, created at the end
+		burger := f.menuItem(CreateMenuItemRequest{Name: "Burger", Category: "Mains"})
+		steak := f.menuItem(CreateMenuItemRequest{Name: "Steak", Category: " mains"})
+		if burger.CategoryID == nil || steak.CategoryID == nil || *steak.CategoryID != *burger.CategoryID || steak.Category != "Mains" {
+			t.Fatalf("got categories %v %q and %v %q, want one Mains category", burger.CategoryID, burger.Category, steak.CategoryID, steak.Category)
+		}
+		cola := f.menuItem(CreateMenuItemRequest{Name: "Cola", CategoryID: &soft.ID})
+		f.menuItem(CreateMenuItemRequest{Name: "Water"})
+		f.menuItem(CreateMenuItemRequest{Name: "Special", CategoryID: &secret.ID})
+
+		if _, err := store.CreateMenuItem(ctx, CreateMenuItemRequest{RestaurantID: restaurant.ID, Name: "Lost", Price: 100, CategoryID: &elsewhere.ID}); !isCategoryRule(err, "same_restaurant") {
+			t.Errorf("category of another restaurant: got %v, want rule same_restaurant", err)
+		}
+		if _, err := store.UpdateCategory(ctx, drinks.ID, CategoryRequest{RestaurantID: restaurant.ID, ParentID: &soft.ID, Name: "Drinks"}); !isCategoryRule(err, "cycle") {
+			t.Errorf("drinks under soft drinks: got %v, want rule cycle", err)
+		}
+		if _, err := store.CreateCategory(ctx, CategoryRequest{RestaurantID: restaurant.ID, ParentID: &elsewhere.ID, Name: "Moved"}); !isCategoryRule(err, "same_restaurant") {
+			t.Errorf("parent of another restaurant: got %v, want rule same_restaurant", err)
+		}
+		var cerr *ConstraintError
+		if _, err := store.CreateCategory(ctx, CategoryRequest{RestaurantID: restaurant.ID, Name: "DRINKS"}); !errors.As(err, &cerr) || cerr.Kind != UniqueViolation {
+			t.Errorf("duplicate name: got %v, want a unique violation", err)
+		}
+
+		menu, err := store.GetRestaurantMenu(ctx, restaurant.ID, false, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(menu.Categories) != 2 || menu.Categories[0].Name != "Drinks" || menu.Categories[1].Name != "Mains" {
+			t.Fatalf("got %+v, want the visible Drinks then Mains", menu.Categories)
+		}
+		mains := menu.Categories[1]
+		if len(mains.Items) != 2 || mains.Items[0].Name != "Burger" || mains.Items[1].Name != "Steak" {
+			t.Errorf("got mains %+v, want Burger and Steak", mains.Items)
+		}
+		subcategories := menu.Categories[0].Subcategories
+		if len(subcategories) != 1 || subcategories[0].ID != soft.ID || len(subcategories[0].Items) != 1 || subcategories[0].Items[0].ID != cola.ID {
+			t.Errorf("got drinks %+v, want Soft drinks with Cola", menu.Categories[0])
+		}
+		if len(menu.Uncategorized) != 1 || menu.Uncategorized[0].Name != "Water" {
+			t.Errorf("got uncategorized %+v, want Water", menu.Uncategorized)
+		}
+		menu, err = store.GetRestaurantMenu(ctx, restaurant.ID, true, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(menu.Categories) != 3 || menu.Categories[0].ID != secret.ID {
+			t.Errorf("got %+v, want the hidden Secret menu first", menu.Categories)
+		}
+
+		// Renaming carries over to the items; deleting moves subcategories up
+		if _, err := store.UpdateCategory(ctx, soft.ID, CategoryRequest{RestaurantID: restaurant.ID, ParentID: &drinks.ID, Name: "Sodas", IsVisible: true}); err != nil {
+			t.Fatal(err)
+		}
+		if m, err := store.GetMenuItem(ctx, cola.ID); err != nil || m.Category != "Sodas" {
+			t.Errorf("got %+v, %v; want Cola in Sodas", m, err)
+		}
+		if err := store.DeleteCategory(ctx, drinks.ID); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := store.GetCategory(ctx, soft.ID); err != nil || c.ParentID != nil {
+			t.Errorf("got %+v, %v; want Sodas at the top level", c, err)
+		}
+		if err := store.DeleteCategory(ctx, soft.ID); err != nil {
+			t.Fatal(err)
+		}
+		if m, err := store.GetMenuItem(ctx, cola.ID); err != nil || m.CategoryID != nil || m.Category != "" {
+			t.Errorf("got %+v, %v; want Cola uncategorized", m, err)
+		}
+	})
+}
+
+// isCategoryRule reports whether err is a *CategoryError whose first
+// problem breaks rule
+func isCategoryRule(err error, rule string) bool {
+	var cerr *CategoryError
+	return errors.As(err, &cerr) && cerr.Fields[0].Rule == rule
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CategoryHandler handles menu category requests and restaurant menus
+type CategoryHandler struct {
+	store CategoryStore
+	pager Paginator
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(store CategoryStore, pager Paginator) *CategoryHandler {
+	return &CategoryHandler{store: store, pager: pager}
+}
+
+// bindCategory binds and validates a category request
+func bindCategory(c echo.Context) (CategoryRequest, error) {
+	var req CategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return req, BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// CreateCategory creates a new category
+func (h *CategoryHandler) CreateCategory(c echo.Context) error {
+	req, err := bindCategory(c)
+	if err != nil {
+		return err
+	}
+
+	category, err := h.store.CreateCategory(c.Request().Context(), req)
+	if err != nil {
+		return storeError("Failed to create category", err)
+	}
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+// GetCategories retrieves a page of categories
+func (h *CategoryHandler) GetCategories(c echo.Context) error {
+	opts, err := h.pager.Parse(c, categoryListing)
+	if err != nil {
+		return err
+	}
+
+	categories, info, err := h.store.ListCategories(c.Request().Context(), opts)
+	if err != nil {
+		return Internal("Failed to fetch categories", err)
+	}
+
+	return h.pager.Respond(c, categoryListing, opts, categories, info)
+}
+
+// GetCategory retrieves a category by ID
+func (h *CategoryHandler) GetCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid category ID")
+	}
+
+	category, err := h.store.GetCategory(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("category")
+		}
+		return Internal("Failed to fetch category", err)
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory updates a category
+func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid category ID")
+	}
+
+	req, err := bindCategory(c)
+	if err != nil {
+		return err
+	}
+
+	category, err := h.store.UpdateCategory(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("category")
+		}
+		return storeError("Failed to update category", err)
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes a category
+func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid category ID")
+	}
+
+	if err := h.store.DeleteCategory(c.Request().Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("category")
+		}
+		return storeError("Failed to delete category", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Category deleted successfully"})
+}
+
+// UpdateCategoryAvailability replaces the availability windows of a
+// category
+func (h *CategoryHandler) UpdateCategoryAvailability(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid category ID")
+	}
+
+	req, err := bindAvailability(c)
+	if err != nil {
+		return err
+	}
+
+	category, err := h.store.UpdateCategoryAvailability(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("category")
+		}
+		return storeError("Failed to update category availability", err)
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+// GetRestaurantMenu retrieves the nested menu of a restaurant as it stands
+// now, or at the RFC 3339 time given with ?at=. Hidden categories are
+// included with ?include_hidden=true.
+func (h *CategoryHandler) GetRestaurantMenu(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid restaurant ID")
+	}
+	includeHidden := false
+	if value := c.QueryParam("include_hidden"); value != "" {
+		if includeHidden, err = strconv.ParseBool(value); err != nil {
+			return BadRequest("invalid_parameter", "include_hidden must be true or false")
+		}
+	}
+	at := time.Now()
+	if value := c.QueryParam("at"); value != "" {
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			return BadRequest("invalid_parameter", "at must be an RFC 3339 time such as 2024-06-01T08:30:00+02:00")
+		}
+	}
+
+	menu, err := h.store.GetRestaurantMenu(c.Request().Context(), id, includeHidden, at)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return Internal("Failed to fetch menu", err)
+	}
+
+	return c.JSON(http.StatusOK, menu)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/labstack/gommon/log"
+	"gopkg.in/yaml.v3"
+)
+
+// logLevels maps the accepted log_level values to Echo logger levels
+var logLevels = map[string]log.Lvl{
+	"debug": log.DEBUG,
+	"info":  log.INFO,
+	"warn":  log.WARN,
+	"error": log.ERROR,
+	"off":   log.OFF,
+}
+
+// Config holds the runtime configuration of the API server.
+//
+// Values are resolved in the following order, later sources overriding
+// earlier ones:
+//
+//  1. built-in defaults (see DefaultConfig)
+//  2. the optional YAML config file (-config flag or CONFIG_FILE)
+//  3. environment variables
+//  4. command-line flags
+type Config struct {
+	Store       string            `yaml:"store"`
+	Database    DatabaseConfig    `yaml:"database"`
+	SQLite      SQLiteConfig      `yaml:"sqlite"`
+	Server      ServerConfig      `yaml:"server"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Pagination  PaginationConfig  `yaml:"pagination"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Scheduler   SchedulerConfig   `yaml:"scheduler"`
+	LogLevel    string            `yaml:"log_level"`
+	// MigrateOnStart applies pending migrations before the server starts
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+}
+
+// DatabaseConfig holds the MySQL connection settings
+type DatabaseConfig struct {
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	Params          string        `yaml:"params"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+}
+
+// SQLiteConfig holds the SQLite settings used with store: sqlite
+type SQLiteConfig struct {
+	Path        string        `yaml:"path"`
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// CORSConfig holds the cross-origin settings
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+// PaginationConfig holds the page sizes of list endpoints
+type PaginationConfig struct {
+	DefaultPageSize int `yaml:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size"`
+}
+
+// IdempotencyConfig holds the settings of Idempotency-Key handling
+type IdempotencyConfig struct {
+	// Window is how long the response to a request is kept for retries
+	Window time.Duration `yaml:"window"`
+}
+
+// SchedulerConfig holds the settings of the background job that releases
+// scheduled orders to the kitchen
+type SchedulerConfig struct {
+	// Interval is how often due orders are released; 0 turns the job off
+	// on this instance
+	Interval time.Duration `yaml:"interval"`
+	// BatchSize is how many orders are released per transaction
+	BatchSize int `yaml:"batch_size"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Store: "mysql",
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            3306,
+			User:            "root",
+			Name:            "restuarant",
+			Params:          "charset=utf8mb4&parseTime=true&loc=Local&clientFoundRows=true",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnectTimeout:  5 * time.Second,
+		},
+		SQLite: SQLiteConfig{
+			Path:        "restaurant.db",
+			BusyTimeout: 5 * time.Second,
+		},
+		Server: ServerConfig{
+			Addr:            ":3644",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+		},
+		Pagination: PaginationConfig{
+			DefaultPageSize: 20,
+			MaxPageSize:     100,
+		},
+		Idempotency: IdempotencyConfig{
+			Window: 24 * time.Hour,
+		},
+		Scheduler: SchedulerConfig{
+			Interval:  30 * time.Second,
+			BatchSize: 100,
+		},
+		LogLevel: "info",
+	}
+}
+
+// configOption binds one setting to its environment variable and flag
+type configOption struct {
+	flag  string
+	env   string
+	usage string
+	field func(cfg *Config) interface{}
+}
+
+var configOptions = []configOption{
+	{"store", "STORE", "storage backend: mysql, sqlite or memory (seeded demo data, nothing persisted)", func(c *Config) interface{} { return &c.Store }},
+	{"db-host", "DB_HOST", "MySQL host", func(c *Config) interface{} { return &c.Database.Host }},
+	{"db-port", "DB_PORT", "MySQL port", func(c *Config) interface{} { return &c.Database.Port }},
+	{"db-user", "DB_USER", "MySQL user", func(c *Config) interface{} { return &c.Database.User }},
+	{"db-password", "DB_PASSWORD", "MySQL password", func(c *Config) interface{} { return &c.Database.Password }},
+	{"db-name", "DB_NAME", "MySQL database name", func(c *Config) interface{} { return &c.Database.Name }},
+	{"db-params", "DB_PARAMS", "extra DSN parameters, URL query encoded", func(c *Config) interface{} { return &c.Database.Params }},
+	{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "maximum open connections (0 = unlimited)", func(c *Config) interface{} { return &c.Database.MaxOpenConns }},
+	{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "maximum idle connections", func(c *Config) interface{} { return &c.Database.MaxIdleConns }},
+	{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "maximum connection lifetime (0 = forever)", func(c *Config) interface{} { return &c.Database.ConnMaxLifetime }},
+	{"db-connect-timeout", "DB_CONNECT_TIMEOUT", "timeout for establishing a connection", func(c *Config) interface{} { return &c.Database.ConnectTimeout }},
+	{"sqlite-path", "SQLITE_PATH", "SQLite database file", func(c *Config) interface{} { return &c.SQLite.Path }},
+	{"sqlite-busy-timeout", "SQLITE_BUSY_TIMEOUT", "how long SQLite waits for a lock", func(c *Config) interface{} { return &c.SQLite.BusyTimeout }},
+	{"addr", "HTTP_ADDR", "HTTP listen address", func(c *Config) interface{} { return &c.Server.Addr }},
+	{"read-timeout", "HTTP_READ_TIMEOUT", "HTTP read timeout", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"write-timeout", "HTTP_WRITE_TIMEOUT", "HTTP write timeout", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"shutdown-timeout", "HTTP_SHUTDOWN_TIMEOUT", "grace period for in-flight requests on shutdown", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"cors-origins", "CORS_ALLOW_ORIGINS", "comma-separated list of allowed CORS origins", func(c *Config) interface{} { return &c.CORS.AllowOrigins }},
+	{"page-size", "DEFAULT_PAGE_SIZE", "page size of list endpoints when the request sets none", func(c *Config) interface{} { return &c.Pagination.DefaultPageSize }},
+	{"max-page-size", "MAX_PAGE_SIZE", "largest page size a request may ask for", func(c *Config) interface{} { return &c.Pagination.MaxPageSize }},
+	{"idempotency-window", "IDEMPOTENCY_WINDOW", "how long responses to requests with an Idempotency-Key are kept for retries", func(c *Config) interface{} { return &c.Idempotency.Window }},
+	{"scheduler-interval", "SCHEDULER_INTERVAL", "how often scheduled orders are released to the kitchen (0 = not on this instance)", func(c *Config) interface{} { return &c.Scheduler.Interval }},
+	{"scheduler-batch-size", "SCHEDULER_BATCH_SIZE", "scheduled orders released per transaction", func(c *Config) interface{} { return &c.Scheduler.BatchSize }},
+	{"log-level", "LOG_LEVEL", "log level: debug, info, warn, error or off", func(c *Config) interface{} { return &c.LogLevel }},
+	{"migrate", "MIGRATE_ON_START", "apply pending migrations before serving", func(c *Config) interface{} { return &c.MigrateOnStart }},
+}
+
+// optionFlag records the raw command-line value of a config option so it
+// can be parsed by setConfigField like an environment variable
+type optionFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *optionFlag) String() string     { return f.value }
+func (f *optionFlag) Set(v string) error { f.value = v; return nil }
+func (f *optionFlag) IsBoolFlag() bool   { return f.isBool }
+
+// LoadConfig resolves the configuration from defaults, the optional config
+// file, the environment and the given command-line arguments, then validates
+// it. Flags must come before the command; the command and its arguments are
+// returned as they were given.
+func LoadConfig(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("restaurant-api", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] [command]\n\n", fs.Name())
+		fmt.Fprintln(fs.Output(), "Commands:")
+		fmt.Fprintln(fs.Output(), "  serve (default)            start the API server")
+		fmt.Fprintln(fs.Output(), "  migrate up                 apply all pending migrations")
+		fmt.Fprintln(fs.Output(), "  migrate down N             roll back the last N migrations")
+		fmt.Fprintln(fs.Output(), "  migrate to VERSION         migrate up or down to VERSION")
+		fmt.Fprintln(fs.Output(), "  migrate status             list migrations")
+		fmt.Fprintln(fs.Output(), "  seed [-file PATH]          load fixtures, the demo data by default")
+		fmt.Fprintln(fs.Output(), "  seed -restaurants N -customers N -orders N")
+		fmt.Fprintln(fs.Output(), "                             generate synthetic data for load testing")
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "", "path to a YAML config file (env CONFIG_FILE)")
+	flagValues := make(map[string]*optionFlag, len(configOptions))
+	for _, opt := range configOptions {
+		_, isBool := opt.field(&Config{}).(*bool)
+		flagValues[opt.flag] = &optionFlag{isBool: isBool}
+		fs.Var(flagValues[opt.flag], opt.flag, fmt.Sprintf("%s (env %s)", opt.usage, opt.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := DefaultConfig()
+
+	path := os.Getenv("CONFIG_FILE")
+	if *configFile != "" {
+		path = *configFile
+	}
+	if path != "" {
+		if err := loadConfigFile(&cfg, path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, opt := range configOptions {
+		if raw, ok := os.LookupEnv(opt.env); ok {
+			if err := setConfigField(opt.field(&cfg), raw); err != nil {
+				return nil, nil, fmt.Errorf("invalid value for %s: %v", opt.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range configOptions {
+			if opt.flag == f.Name && flagErr == nil {
+				if err := setConfigField(opt.field(&cfg), flagValues[opt.flag].value); err != nil {
+					flagErr = fmt.Errorf("invalid value for -%s: %v", opt.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, fs.Args(), nil
+}
+
+// loadConfigFile overlays the YAML file at path onto cfg. Other formats
+// such as TOML are rejected by extension rather than failing with a
+// confusing YAML syntax error.
+func loadConfigFile(cfg *Config, path string) error {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("config file %s must be YAML with a .yaml or .yml extension", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// setConfigField parses raw into the field pointed to by ptr
+func setConfigField(ptr interface{}, raw string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = raw
+	case *int:
+		raw = strings.TrimSpace(raw)
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*p = b
+	case *time.Duration:
+		raw = strings.TrimSpace(raw)
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 5s, 1m)", raw)
+		}
+		*p = d
+	case *[]string:
+		var values []string
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*p = values
+	default:
+		return fmt.Errorf("unsupported config field type %T", ptr)
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports every problem at once
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Store {
+	case "mysql":
+		problems = append(problems, c.Database.validate()...)
+	case "sqlite":
+		if c.SQLite.Path == "" {
+			add("sqlite.path must not be empty")
+		}
+		if c.SQLite.BusyTimeout < 0 {
+			add("sqlite.busy_timeout must not be negative")
+		}
+	case "memory":
+	default:
+		add("store must be mysql, sqlite or memory, got %q", c.Store)
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		add("server.addr %q is not a host:port address", c.Server.Addr)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		add("server.addr %q has an invalid port", c.Server.Addr)
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.ShutdownTimeout < 0 {
+		add("server timeouts must not be negative")
+	}
+
+	if c.Pagination.DefaultPageSize < 1 {
+		add("pagination.default_page_size must be at least 1")
+	}
+	if c.Pagination.MaxPageSize < c.Pagination.DefaultPageSize {
+		add("pagination.max_page_size must be at least pagination.default_page_size")
+	}
+
+	if c.Idempotency.Window <= 0 {
+		add("idempotency.window must be positive")
+	}
+
+	if c.Scheduler.Interval < 0 {
+		add("scheduler.interval must not be negative")
+	}
+	if c.Scheduler.BatchSize < 1 {
+		add("scheduler.batch_size must be at least 1")
+	}
+
+	if len(c.CORS.AllowOrigins) == 0 {
+		add("cors.allow_origins must list at least one origin (use * to allow all)")
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("cors.allow_origins entry %q must be * or an http(s) origin", origin)
+		}
+	}
+
+	if _, ok := logLevels[strings.ToLower(c.LogLevel)]; !ok {
+		add("log_level must be one of debug, info, warn, error, off; got %q", c.LogLevel)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// validate checks the MySQL settings and returns one message per problem
+func (db DatabaseConfig) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if db.Host == "" {
+		add("database.host must not be empty")
+	}
+	if db.Port < 1 || db.Port > 65535 {
+		add("database.port must be between 1 and 65535, got %d", db.Port)
+	}
+	if db.User == "" {
+		add("database.user must not be empty")
+	}
+	if db.Name == "" {
+		add("database.name must not be empty")
+	}
+	if _, err := url.ParseQuery(db.Params); err != nil {
+		add("database.params is not a valid query string: %v", err)
+	}
+	if db.MaxOpenConns < 0 {
+		add("database.max_open_conns must not be negative")
+	}
+	if db.MaxIdleConns < 0 {
+		add("database.max_idle_conns must not be negative")
+	}
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		add("database.max_idle_conns (%d) must not exceed max_open_conns (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	}
+	if db.ConnMaxLifetime < 0 {
+		add("database.conn_max_lifetime must not be negative")
+	}
+	if db.ConnectTimeout < 0 {
+		add("database.connect_timeout must not be negative")
+	}
+
+	return problems
+}
+
+// DSN builds the go-sql-driver/mysql data source name. The store needs
+// parseTime to scan timestamps and clientFoundRows so that an update
+// leaving a row unchanged still counts it as found, so both are set
+// whatever Params holds.
+func (c DatabaseConfig) DSN() (string, error) {
+	mc, err := mysql.ParseDSN("/" + c.Name + "?" + c.Params)
+	if err != nil {
+		return "", fmt.Errorf("invalid database params: %v", err)
+	}
+	mc.ParseTime = true
+	mc.ClientFoundRows = true
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	mc.Timeout = c.ConnectTimeout
+	return mc.FormatDSN(), nil
+}
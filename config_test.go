@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TestLoadConfigPrecedence sets the same settings in the config file, the
+// environment and flags and checks that the later source wins
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "database:\n  port: 3307\n  name: from_file\n  user: file_user\nserver:\n  addr: \":4000\"\n"
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("DB_PORT", "3308")
+	t.Setenv("DB_NAME", "from_env")
+	t.Setenv("CORS_ALLOW_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	cfg, args, err := LoadConfig([]string{"--db-port=3309", "-read-timeout", "1m", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.Database.Host, "localhost"},
+		{"file", cfg.Database.User, "file_user"},
+		{"file", cfg.Server.Addr, ":4000"},
+		{"env over file", cfg.Database.Name, "from_env"},
+		{"flag over env", cfg.Database.Port, 3309},
+		{"flag over default", cfg.Server.ReadTimeout, time.Minute},
+		{"list from env", strings.Join(cfg.CORS.AllowOrigins, " "), "https://a.example.com https://b.example.com"},
+		{"command", strings.Join(args, " "), "migrate up"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	toml := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(toml, []byte("[database]\nport = 3307\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "env not an integer", env: map[string]string{"DB_PORT": "abc"}, want: "invalid value for DB_PORT"},
+		{name: "flag not a duration", args: []string{"--read-timeout=5"}, want: "invalid value for -read-timeout"},
+		{name: "flag not a boolean", args: []string{"--migrate=maybe"}, want: "invalid value for -migrate"},
+		{name: "invalid after merging", env: map[string]string{"STORE": "postgres"}, want: "store must be mysql, sqlite or memory"},
+		{name: "missing config file", env: map[string]string{"CONFIG_FILE": "/does/not/exist.yaml"}, want: "error reading config file"},
+		{name: "not a YAML file", args: []string{"--config=" + toml}, want: "must be YAML with a .yaml or .yml extension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, _, err := LoadConfig(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string // empty for a valid configuration
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{name: "memory without mysql settings", change: func(c *Config) { c.Store, c.Database = "memory", DatabaseConfig{} }},
+		{name: "unknown store", change: func(c *Config) { c.Store = "postgres" }, want: "store must be mysql, sqlite or memory"},
+		{name: "sqlite path", change: func(c *Config) { c.Store, c.SQLite.Path = "sqlite", "" }, want: "sqlite.path must not be empty"},
+		{name: "sqlite busy timeout", change: func(c *Config) { c.Store, c.SQLite.BusyTimeout = "sqlite", -time.Second }, want: "sqlite.busy_timeout must not be negative"},
+		{name: "mysql port", change: func(c *Config) { c.Database.Port = 70000 }, want: "database.port must be between 1 and 65535"},
+		{name: "idle above open", change: func(c *Config) { c.Database.MaxIdleConns = 30 }, want: "must not exceed max_open_conns"},
+		{name: "address", change: func(c *Config) { c.Server.Addr = "3644" }, want: "is not a host:port address"},
+		{name: "negative timeout", change: func(c *Config) { c.Server.ReadTimeout = -time.Second }, want: "server timeouts must not be negative"},
+		{name: "cors origin", change: func(c *Config) { c.CORS.AllowOrigins = []string{"example.com"} }, want: "must be * or an http(s) origin"},
+		{name: "default page size", change: func(c *Config) { c.Pagination.DefaultPageSize = 0 }, want: "default_page_size must be at least 1"},
+		{name: "page sizes", change: func(c *Config) { c.Pagination.MaxPageSize = 10 }, want: "max_page_size must be at least"},
+		{name: "idempotency window", change: func(c *Config) { c.Idempotency.Window = 0 }, want: "idempotency.window must be positive"},
+		{name: "scheduler interval", change: func(c *Config) { c.Scheduler.Interval = -time.Second }, want: "scheduler.interval must not be negative"},
+		{name: "scheduler batch", change: func(c *Config) { c.Scheduler.BatchSize = 0 }, want: "scheduler.batch_size must be at least 1"},
+		{name: "log level", change: func(c *Config) { c.LogLevel = "verbose" }, want: "log_level must be one of"},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		tt.change(&cfg)
+		err := cfg.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: got %v, want a valid configuration", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Every problem is reported at once
+	cfg := DefaultConfig()
+	cfg.Database.Host, cfg.LogLevel = "", "verbose"
+	if err := cfg.Validate(); err == nil || strings.Count(err.Error(), "\n  - ") != 2 {
+		t.Errorf("got %v, want two problems", err)
+	}
+}
+
+// TestDSNKeepsRequiredParams checks that custom params cannot turn off the
+// settings the store relies on
+func TestDSNKeepsRequiredParams(t *testing.T) {
+	db := DefaultConfig().Database
+	db.Params = "charset=utf8mb4&parseTime=false&tls=preferred"
+	dsn, err := db.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mc.ClientFoundRows || !mc.ParseTime || mc.TLSConfig != "preferred" || mc.Addr != "localhost:3306" {
+		t.Errorf("got %+v, want clientFoundRows and parseTime on and the other params kept", mc)
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,113 +10,87 @@ import (
 
 // CustomerHandler handles customer-related requests
 type CustomerHandler struct {
-	db *Database
+	store CustomerStore
+	pager Paginator
 }
 
 // NewCustomerHandler creates a new customer handler
-func NewCustomerHandler(db *Database) *CustomerHandler {
-	return &CustomerHandler{db: db}
+func NewCustomerHandler(store CustomerStore, pager Paginator) *CustomerHandler {
+	return &CustomerHandler{store: store, pager: pager}
 }
 
 // CreateCustomer creates a new customer
 func (h *CustomerHandler) CreateCustomer(c echo.Context) error {
 	var req CreateCustomerRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
 	}
-
-	query := `INSERT INTO customers (name, email, phone, address) VALUES (?, ?, ?, ?)`
-	result, err := h.db.Exec(query, req.Name, req.Email, req.Phone, req.Address)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create customer"})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	id, _ := result.LastInsertId()
-	customer, err := h.GetCustomerByID(int(id))
+	customer, err := h.store.CreateCustomer(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch created customer"})
+		return storeError("Failed to create customer", err)
 	}
 
 	return c.JSON(http.StatusCreated, customer)
 }
 
-// GetCustomers retrieves all customers
+// GetCustomers retrieves a page of customers
 func (h *CustomerHandler) GetCustomers(c echo.Context) error {
-	query := `SELECT id, name, email, phone, address, created_at, updated_at FROM customers ORDER BY created_at DESC`
-	rows, err := h.db.Query(query)
+	opts, err := h.pager.Parse(c, customerListing)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch customers"})
+		return err
 	}
-	defer rows.Close()
-
-	var customers []Customer
-	for rows.Next() {
-		var customer Customer
-		err := rows.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.Address, &customer.CreatedAt, &customer.UpdatedAt)
-		if err != nil {
-			continue
-		}
-		customers = append(customers, customer)
+
+	customers, info, err := h.store.ListCustomers(c.Request().Context(), opts)
+	if err != nil {
+		return Internal("Failed to fetch customers", err)
 	}
 
-	return c.JSON(http.StatusOK, customers)
+	return h.pager.Respond(c, customerListing, opts, customers, info)
 }
 
 // GetCustomer retrieves a customer by ID
 func (h *CustomerHandler) GetCustomer(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid customer ID"})
+		return BadRequest("invalid_id", "Invalid customer ID")
 	}
 
-	customer, err := h.GetCustomerByID(id)
+	customer, err := h.store.GetCustomer(c.Request().Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Customer not found"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("customer")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch customer"})
+		return Internal("Failed to fetch customer", err)
 	}
 
 	return c.JSON(http.StatusOK, customer)
 }
 
-// GetCustomerByID helper method
-func (h *CustomerHandler) GetCustomerByID(id int) (*Customer, error) {
-	query := `SELECT id, name, email, phone, address, created_at, updated_at FROM customers WHERE id = ?`
-	var customer Customer
-	err := h.db.QueryRow(query, id).Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.Address, &customer.CreatedAt, &customer.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return &customer, nil
-}
-
 // UpdateCustomer updates a customer
 func (h *CustomerHandler) UpdateCustomer(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid customer ID"})
+		return BadRequest("invalid_id", "Invalid customer ID")
 	}
 
 	var req CreateCustomerRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
 	}
-
-	query := `UPDATE customers SET name = ?, email = ?, phone = ?, address = ? WHERE id = ?`
-	result, err := h.db.Exec(query, req.Name, req.Email, req.Phone, req.Address, id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update customer"})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Customer not found"})
-	}
-
-	customer, err := h.GetCustomerByID(id)
+	customer, err := h.store.UpdateCustomer(c.Request().Context(), id, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch updated customer"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("customer")
+		}
+		return storeError("Failed to update customer", err)
 	}
 
 	return c.JSON(http.StatusOK, customer)
@@ -126,18 +100,14 @@ func (h *CustomerHandler) UpdateCustomer(c echo.Context) error {
 func (h *CustomerHandler) DeleteCustomer(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid customer ID"})
+		return BadRequest("invalid_id", "Invalid customer ID")
 	}
 
-	query := `DELETE FROM customers WHERE id = ?`
-	result, err := h.db.Exec(query, id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete customer"})
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Customer not found"})
+	if err := h.store.DeleteCustomer(c.Request().Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("customer")
+		}
+		return storeError("Failed to delete customer", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Customer deleted successfully"})
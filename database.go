@@ -4,32 +4,74 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
 // Database holds the database connection
 type Database struct {
 	*sql.DB
+	// Driver is the database/sql driver name, "mysql" or "sqlite"
+	Driver string
 }
 
-// NewDatabase creates a new database connection
-func NewDatabase() (*Database, error) {
-	// For demo purposes, using a simple connection string
-	// In production, these should be environment variables
-	dsn := "root:Pw@#$234@tcp(localhost:3306)/restuarant?charset=utf8mb4&parseTime=True&loc=Local"
+// NewDatabase creates a new database connection from the given settings
+func NewDatabase(cfg DatabaseConfig) (*Database, error) {
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
 
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("error connecting to database: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("error connecting to database %s@%s:%d/%s: %v", cfg.User, cfg.Host, cfg.Port, cfg.Name, err)
 	}
 
 	log.Println("Successfully connected to database")
-	return &Database{db}, nil
+	return &Database{DB: db, Driver: "mysql"}, nil
+}
+
+// NewSQLiteDatabase opens the SQLite database file, creating it if needed.
+// The schema is created by the migrations.
+func NewSQLiteDatabase(cfg SQLiteConfig) (*Database, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	// Take the write lock when a transaction starts, like MySQL row locks
+	// would, instead of failing with SQLITE_BUSY when upgrading later
+	params.Set("_txlock", "immediate")
+
+	// The path is escaped so that ?, # and % in it are not taken for the
+	// start of the parameters, a fragment or an escape
+	path := (&url.URL{Path: cfg.Path}).EscapedPath()
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+
+	// SQLite allows a single writer; one connection keeps ":memory:"
+	// databases shared and avoids lock contention between connections
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening database %s: %v", cfg.Path, err)
+	}
+
+	log.Printf("Using SQLite database %s", cfg.Path)
+	return &Database{DB: db, Driver: "sqlite"}, nil
 }
 
 // Close closes the database connection
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSQLitePathIsEscaped opens a database whose path holds characters
+// with a meaning in URIs and checks that the file is created where it was
+// asked for with the connection settings applied
+func TestSQLitePathIsEscaped(t *testing.T) {
+	for _, name := range []string{"plain.db", "what?.db", "a#b.db", "100%25.db", "with space.db"} {
+		path := filepath.Join(t.TempDir(), name)
+		db, err := NewSQLiteDatabase(SQLiteConfig{Path: path, BusyTimeout: time.Second})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		var foreignKeys int
+		if err := db.QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil || foreignKeys != 1 {
+			t.Errorf("%s: got foreign_keys %d, %v; want the pragma applied", name, foreignKeys, err)
+		}
+		db.Close()
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
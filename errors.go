@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorKind classifies an Error and decides its HTTP status
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindBadRequest
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindForbidden
+)
+
+// Status returns the HTTP status code for the kind
+func (k ErrorKind) Status() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is the error type returned by handlers. Code is a stable,
+// machine-readable identifier clients can switch on; Message is shown to
+// users; Err is the underlying cause, logged but never sent to the client.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Relation names the records that block a request, e.g. "orders"
+	Relation string
+	// AllowedTransitions lists the statuses a record could move to instead
+	AllowedTransitions []string
+	Err                error
+
+	// status overrides the status of Kind, for errors raised by Echo itself
+	status int
+}
+
+// Status returns the HTTP status code of the error
+func (e *Error) Status() int {
+	if e.status != 0 {
+		return e.status
+	}
+	return e.Kind.Status()
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if len(e.Fields) > 0 {
+		messages := make([]string, len(e.Fields))
+		for i, f := range e.Fields {
+			messages[i] = f.Message
+		}
+		msg += ": " + strings.Join(messages, "; ")
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCause records the underlying error
+func (e *Error) WithCause(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// NotFound reports a missing resource, e.g. NotFound("menu item") has the
+// code menu_item_not_found and the message "Menu item not found"
+func NotFound(resource string) *Error {
+	return &Error{
+		Kind:    KindNotFound,
+		Code:    strings.ReplaceAll(resource, " ", "_") + "_not_found",
+		Message: strings.ToUpper(resource[:1]) + resource[1:] + " not found",
+	}
+}
+
+// Conflict reports a request that clashes with the current state
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// Validation reports request fields that failed validation
+func Validation(fields []FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "Validation failed", Fields: fields}
+}
+
+// Forbidden reports a request the caller is not allowed to make
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+// BadRequest reports a malformed request
+func BadRequest(code, message string) *Error {
+	return &Error{Kind: KindBadRequest, Code: code, Message: message}
+}
+
+// Internal reports a server-side failure caused by err
+func Internal(message string, err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: message, Err: err}
+}
+
+// resourceNames maps table names to the names used in error messages
+var resourceNames = map[string]string{
+	"restaurants":            "restaurant",
+	"menu_items":             "menu item",
+	"customers":              "customer",
+	"orders":                 "order",
+	"order_items":            "order item",
+	"order_status_events":    "order status event",
+	"restaurant_pricing":     "restaurant pricing",
+	"restaurant_taxes":       "tax",
+	"delivery_fee_bands":     "delivery fee band",
+	"promotions":             "promotion",
+	"promotion_redemptions":  "promotion redemption",
+	"option_groups":          "option group",
+	"menu_options":           "option",
+	"order_item_options":     "order item option",
+	"bundle_slots":           "bundle slot",
+	"bundle_slot_items":      "bundle slot item",
+	"categories":             "category",
+	"menu_item_availability": "availability window",
+	"category_availability":  "availability window",
+	"opening_hours":          "opening shift",
+	"restaurant_closures":    "closure",
+}
+
+func resourceName(table string) string {
+	if name, ok := resourceNames[table]; ok {
+		return name
+	}
+	return strings.ReplaceAll(table, "_", " ")
+}
+
+// storeError converts an error from a store write into an Error. Constraint
+// violations become 409 Conflict for duplicates and records still in use,
+// and 422 for references to records that do not exist. Orders that cannot
+// be placed are 422 and status changes the order lifecycle does not allow
+// are 409 Conflict. Anything else is an internal error reported with message.
+func storeError(message string, err error) *Error {
+	var terr *TransitionError
+	if errors.As(err, &terr) {
+		e := Conflict("invalid_transition", fmt.Sprintf("Cannot change order status from %s to %s", terr.From, terr.To))
+		e.AllowedTransitions = nextOrderStatuses(terr.From)
+		if len(e.AllowedTransitions) == 0 {
+			e.Message = fmt.Sprintf("Cannot change the status of a %s order", terr.From)
+		}
+		e.Err = err
+		return e
+	}
+
+	var oerr *OrderError
+	if errors.As(err, &oerr) {
+		return &Error{Kind: KindValidation, Code: "invalid_order", Message: "The order cannot be placed", Fields: oerr.Fields, Err: err}
+	}
+
+	var caterr *CategoryError
+	if errors.As(err, &caterr) {
+		return &Error{Kind: KindValidation, Code: "invalid_category", Message: "The category cannot be used", Fields: caterr.Fields, Err: err}
+	}
+
+	var berr *BundleError
+	if errors.As(err, &berr) {
+		return &Error{Kind: KindValidation, Code: "invalid_bundle", Message: "The bundle slots cannot be set", Fields: berr.Fields, Err: err}
+	}
+
+	var cerr *ConstraintError
+	if !errors.As(err, &cerr) {
+		return Internal(message, err)
+	}
+
+	var e *Error
+	switch cerr.Kind {
+	case UniqueViolation:
+		e = Conflict("duplicate_value", fmt.Sprintf("Another %s already has this %s", resourceName(cerr.Table), cerr.Column))
+		e.Fields = []FieldError{{
+			Field:   cerr.Column,
+			Rule:    "unique",
+			Message: fmt.Sprintf("%s is already in use", cerr.Column),
+		}}
+	case MissingReference:
+		e = &Error{Kind: KindValidation, Code: "invalid_reference", Message: "A referenced record does not exist"}
+		if cerr.Column != "" {
+			e.Message = fmt.Sprintf("Referenced %s does not exist", resourceName(cerr.RefTable))
+			e.Fields = []FieldError{{
+				Field:   cerr.Column,
+				Rule:    "exists",
+				Message: fmt.Sprintf("%s refers to a %s that does not exist", cerr.Column, resourceName(cerr.RefTable)),
+			}}
+		}
+	case StillReferenced:
+		name := resourceName(cerr.Table)
+		e = Conflict("still_referenced", fmt.Sprintf("%s%s is still referenced by other records", strings.ToUpper(name[:1]), name[1:]))
+		if cerr.RefTable != "" {
+			e.Message = fmt.Sprintf("%s%s is still referenced by %ss", strings.ToUpper(name[:1]), name[1:], resourceName(cerr.RefTable))
+			e.Relation = cerr.RefTable
+		}
+	default:
+		e = &Error{Kind: KindValidation, Code: "constraint_violation", Message: fmt.Sprintf("Value not allowed for %s", resourceName(cerr.Table))}
+		if cerr.Column != "" {
+			e.Fields = []FieldError{{
+				Field:   cerr.Column,
+				Rule:    "check",
+				Message: fmt.Sprintf("%s is not allowed", cerr.Column),
+			}}
+		}
+	}
+	e.Err = err
+	return e
+}
+
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Relation  string       `json:"relation,omitempty"`
+	// AllowedTransitions is omitted when the record cannot change status at all
+	AllowedTransitions []string `json:"allowed_transitions,omitempty"`
+}
+
+// MIMEApplicationProblemJSON is the media type of Problem responses
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// asError converts anything a handler or middleware returned into an Error
+func asError(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message := http.StatusText(httpErr.Code)
+		if m, ok := httpErr.Message.(string); ok {
+			message = m
+		}
+		kind := KindBadRequest
+		switch {
+		case httpErr.Code == http.StatusNotFound:
+			kind = KindNotFound
+		case httpErr.Code == http.StatusForbidden:
+			kind = KindForbidden
+		case httpErr.Code == http.StatusConflict:
+			kind = KindConflict
+		case httpErr.Code >= http.StatusInternalServerError:
+			kind = KindInternal
+		}
+		return &Error{Kind: kind, Code: httpErrorCode(httpErr.Code), Message: message, Err: httpErr.Internal, status: httpErr.Code}
+	}
+
+	return Internal("Internal server error", err)
+}
+
+// httpErrorCode derives a code from a status, e.g. 405 -> method_not_allowed
+func httpErrorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "http_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// NewHTTPErrorHandler returns an echo.HTTPErrorHandler that writes every
+// error as application/problem+json and logs the underlying cause
+func NewHTTPErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		appErr := asError(err)
+		status := appErr.Status()
+		requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+
+		if status >= http.StatusInternalServerError {
+			e.Logger.Errorf("request_id=%s %s %s: %v", requestID, c.Request().Method, c.Request().URL.Path, appErr)
+		} else if appErr.Err != nil {
+			e.Logger.Debugf("request_id=%s %s %s: %v", requestID, c.Request().Method, c.Request().URL.Path, appErr)
+		}
+
+		problem := Problem{
+			Type:               "about:blank",
+			Title:              http.StatusText(status),
+			Status:             status,
+			Detail:             appErr.Message,
+			Instance:           c.Request().URL.Path,
+			Code:               appErr.Code,
+			RequestID:          requestID,
+			Errors:             appErr.Fields,
+			Relation:           appErr.Relation,
+			AllowedTransitions: appErr.AllowedTransitions,
+		}
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(status)
+		} else {
+			err = writeProblem(c, problem)
+		}
+		if err != nil {
+			e.Logger.Error(err)
+		}
+	}
+}
+
+// writeProblem sends problem with the problem+json media type
+func writeProblem(c echo.Context, problem Problem) error {
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	c.Response().WriteHeader(problem.Status)
+	if err := c.Echo().JSONSerializer.Serialize(c, problem, ""); err != nil {
+		return fmt.Errorf("error writing problem response: %v", err)
+	}
+	return nil
+}
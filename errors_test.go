@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyConstraintMySQL(t *testing.T) {
+	const fk = "a foreign key constraint fails (`restaurant_db`.`orders`, CONSTRAINT `orders_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`))"
+	tests := []struct {
+		name     string
+		err      error
+		table    string
+		deleting bool
+		want     *ConstraintError // nil if err is not a constraint violation
+	}{
+		{
+			name:  "duplicate entry",
+			err:   &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'customers.email'"},
+			table: "customers",
+			want:  &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email"},
+		},
+		{
+			name:  "duplicate entry before MySQL 8",
+			err:   &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'email'"},
+			table: "customers",
+			want:  &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email"},
+		},
+		{
+			name:  "duplicate primary key",
+			err:   &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '7' for key 'PRIMARY'"},
+			table: "orders",
+			want:  &ConstraintError{Kind: UniqueViolation, Table: "orders", Column: "id"},
+		},
+		{
+			name:  "no referenced row",
+			err:   &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: " + fk},
+			table: "orders",
+			want:  &ConstraintError{Kind: MissingReference, Table: "orders", Column: "customer_id", RefTable: "customers"},
+		},
+		{
+			name:     "row is referenced",
+			err:      &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: " + fk},
+			table:    "customers",
+			deleting: true,
+			want:     &ConstraintError{Kind: StillReferenced, Table: "customers", RefTable: "orders"},
+		},
+		{
+			name:  "wrapped",
+			err:   fmt.Errorf("error creating order: %w", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: " + fk}),
+			table: "orders",
+			want:  &ConstraintError{Kind: MissingReference, Table: "orders", Column: "customer_id", RefTable: "customers"},
+		},
+		{
+			name:  "check constraint",
+			err:   &mysql.MySQLError{Number: 3819, Message: "Check constraint 'order_items_chk_1' is violated."},
+			table: "order_items",
+			want:  &ConstraintError{Kind: CheckViolation, Table: "order_items"},
+		},
+		{
+			name:  "other error",
+			err:   &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"},
+			table: "orders",
+		},
+		{
+			name:  "not a driver error",
+			err:   errors.New("connection refused"),
+			table: "orders",
+		},
+	}
+	for _, tt := range tests {
+		checkConstraint(t, tt.name, classifyConstraint(tt.err, tt.table, tt.deleting), tt.want)
+	}
+}
+
+// TestClassifyConstraintSQLite classifies the errors SQLite returns for
+// statements breaking the constraints of the migrated schema
+func TestClassifyConstraintSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := newMigrationDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO restaurants (id, name) VALUES (1, 'Bistro')`,
+		`INSERT INTO customers (id, name, email) VALUES (1, 'Ann', 'ann@example.com')`,
+		`INSERT INTO menu_items (id, restaurant_id, name, price) VALUES (1, 1, 'Soup', 5)`,
+		`INSERT INTO orders (id, customer_id, restaurant_id, total_amount) VALUES (1, 1, 1, 0)`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		stmt     string
+		table    string
+		deleting bool
+		want     *ConstraintError
+	}{
+		{
+			name:  "unique",
+			stmt:  `INSERT INTO customers (name, email) VALUES ('Ann', 'ANN@example.com')`,
+			table: "customers",
+			want:  &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email"},
+		},
+		{
+			name:  "primary key",
+			stmt:  `INSERT INTO customers (id, name) VALUES (1, 'Bob')`,
+			table: "customers",
+			want:  &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "id"},
+		},
+		{
+			name:  "missing reference",
+			stmt:  `INSERT INTO orders (customer_id, restaurant_id, total_amount) VALUES (99, 1, 0)`,
+			table: "orders",
+			want:  &ConstraintError{Kind: MissingReference, Table: "orders"},
+		},
+		{
+			name:     "still referenced",
+			stmt:     `DELETE FROM customers WHERE id = 1`,
+			table:    "customers",
+			deleting: true,
+			want:     &ConstraintError{Kind: StillReferenced, Table: "customers"},
+		},
+		{
+			name:  "check",
+			stmt:  `INSERT INTO order_items (order_id, menu_item_id, quantity, unit_price) VALUES (1, 1, 0, 5)`,
+			table: "order_items",
+			want:  &ConstraintError{Kind: CheckViolation, Table: "order_items"},
+		},
+		{
+			name:  "not null",
+			stmt:  `INSERT INTO customers (email) VALUES ('bob@example.com')`,
+			table: "customers",
+			want:  &ConstraintError{Kind: CheckViolation, Table: "customers", Column: "name"},
+		},
+		{
+			name:  "not a constraint",
+			stmt:  `INSERT INTO no_such_table (id) VALUES (1)`,
+			table: "no_such_table",
+		},
+	}
+	for _, tt := range tests {
+		_, err := db.ExecContext(ctx, tt.stmt)
+		if err == nil {
+			t.Errorf("%s: statement succeeded", tt.name)
+			continue
+		}
+		checkConstraint(t, tt.name, classifyConstraint(err, tt.table, tt.deleting), tt.want)
+	}
+}
+
+func checkConstraint(t *testing.T, name string, got, want *ConstraintError) {
+	t.Helper()
+	switch {
+	case want == nil && got != nil:
+		t.Errorf("%s: got %+v, want nil", name, got)
+	case want == nil:
+	case got == nil:
+		t.Errorf("%s: got nil, want %+v", name, want)
+	case got.Kind != want.Kind || got.Table != want.Table || got.Column != want.Column || got.RefTable != want.RefTable:
+		t.Errorf("%s: got %v %s.%s -> %s, want %v %s.%s -> %s", name,
+			got.Kind, got.Table, got.Column, got.RefTable, want.Kind, want.Table, want.Column, want.RefTable)
+	case got.Err == nil:
+		t.Errorf("%s: the driver error is not kept", name)
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	driverErr := &mysql.MySQLError{Number: 1062}
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		code     string
+		field    string
+		relation string
+	}{
+		{"duplicate", &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email", Err: driverErr}, http.StatusConflict, "duplicate_value", "email", ""},
+		{"missing reference", &ConstraintError{Kind: MissingReference, Table: "orders", Column: "customer_id", RefTable: "customers"}, http.StatusUnprocessableEntity, "invalid_reference", "customer_id", ""},
+		{"unknown missing reference", &ConstraintError{Kind: MissingReference, Table: "orders"}, http.StatusUnprocessableEntity, "invalid_reference", "", ""},
+		{"still referenced", &ConstraintError{Kind: StillReferenced, Table: "customers", RefTable: "orders"}, http.StatusConflict, "still_referenced", "", "orders"},
+		{"check", &ConstraintError{Kind: CheckViolation, Table: "order_items", Column: "quantity"}, http.StatusUnprocessableEntity, "constraint_violation", "quantity", ""},
+		{"transition", &TransitionError{From: "delivered", To: "pending"}, http.StatusConflict, "invalid_transition", "", ""},
+		{"wrapped", fmt.Errorf("error deleting customer: %w", &ConstraintError{Kind: StillReferenced, Table: "customers", RefTable: "orders"}), http.StatusConflict, "still_referenced", "", "orders"},
+		{"anything else", errors.New("connection refused"), http.StatusInternalServerError, "internal_error", "", ""},
+	}
+	for _, tt := range tests {
+		e := storeError("Failed to save", tt.err)
+		field := ""
+		if len(e.Fields) > 0 {
+			field = e.Fields[0].Field
+		}
+		if e.Status() != tt.status || e.Code != tt.code || field != tt.field || e.Relation != tt.relation {
+			t.Errorf("%s: got %d %s field %q relation %q, want %d %s field %q relation %q", tt.name,
+				e.Status(), e.Code, field, e.Relation, tt.status, tt.code, tt.field, tt.relation)
+		}
+		if !errors.Is(e, tt.err) {
+			t.Errorf("%s: the store error is not kept", tt.name)
+		}
+	}
+}
+
+// TestConstraintErrorsSQLite checks the responses to requests the SQLite
+// schema rejects
+func TestConstraintErrorsSQLite(t *testing.T) {
+	store := newSQLiteStore(t)
+	f := newFixture(t, store, CreateRestaurantRequest{Name: "Constraints"})
+	if _, err := store.CreateOrder(f.ctx, f.order(line(f.item("Soup")))); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestServer(store)
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		status       int
+		code         string
+		field        string
+		relation     string
+	}{
+		{
+			name:   "duplicate email",
+			method: http.MethodPost, path: "/api/v1/customers",
+			body:   fmt.Sprintf(`{"name": "Copy", "email": %q}`, f.customer.Email),
+			status: http.StatusConflict, code: "duplicate_value", field: "email",
+		},
+		{
+			name:   "missing restaurant",
+			method: http.MethodPost, path: "/api/v1/menu-items",
+			body:   `{"restaurant_id": 9999, "name": "Soup", "price": 5}`,
+			status: http.StatusUnprocessableEntity, code: "invalid_reference", field: "restaurant_id",
+		},
+		{
+			name:   "customer with orders",
+			method: http.MethodDelete, path: fmt.Sprintf("/api/v1/customers/%d", f.customer.ID),
+			status: http.StatusConflict, code: "still_referenced", relation: "orders",
+		},
+	}
+	for _, tt := range tests {
+		rec := serveJSON(e, tt.method, tt.path, tt.body, nil)
+		p := problemOf(t, rec)
+		field := ""
+		if len(p.Errors) > 0 {
+			field = p.Errors[0].Field
+		}
+		if rec.Code != tt.status || p.Code != tt.code || field != tt.field || p.Relation != tt.relation {
+			t.Errorf("%s: got %d %s field %q relation %q, want %d %s field %q relation %q", tt.name,
+				rec.Code, p.Code, field, p.Relation, tt.status, tt.code, tt.field, tt.relation)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterOp is a comparison a list filter applies to a field
+type FilterOp string
+
+const (
+	OpEq     FilterOp = "eq"
+	OpIn     FilterOp = "in"
+	OpGt     FilterOp = "gt"
+	OpGte    FilterOp = "gte"
+	OpLt     FilterOp = "lt"
+	OpLte    FilterOp = "lte"
+	OpPrefix FilterOp = "prefix"
+)
+
+// Filter restricts a list to records whose field matches Values. OpIn
+// takes any number of values, every other operator exactly one.
+type Filter struct {
+	Field  string
+	Op     FilterOp
+	Values []interface{}
+}
+
+// Operator sets for the field whitelists in listing.go
+var (
+	idOps     = []FilterOp{OpEq, OpIn}
+	textOps   = []FilterOp{OpEq, OpIn, OpPrefix}
+	numberOps = []FilterOp{OpEq, OpIn, OpGt, OpGte, OpLt, OpLte}
+	timeOps   = []FilterOp{OpGt, OpGte, OpLt, OpLte}
+	boolOps   = []FilterOp{OpEq}
+)
+
+// parseValue converts a query string value to the Go type of the field
+func (k fieldKind) parseValue(s string) (interface{}, error) {
+	switch k {
+	case intField:
+		return strconv.Atoi(s)
+	case moneyField:
+		return ParseMoney(s)
+	case timeField:
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02", s)
+	case boolField:
+		return strconv.ParseBool(s)
+	default:
+		return s, nil
+	}
+}
+
+func (k fieldKind) describe() string {
+	switch k {
+	case intField:
+		return "an integer"
+	case moneyField:
+		return "an amount such as 12.50"
+	case timeField:
+		return "an RFC 3339 timestamp or a YYYY-MM-DD date"
+	case boolField:
+		return "true or false"
+	default:
+		return "text"
+	}
+}
+
+// allows reports whether op can be used on the field
+func (f listField) allows(op FilterOp) bool {
+	for _, o := range f.ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilter builds a filter on res from a query parameter. name is either
+// field[op] or a bare field, which matches any of the comma-separated
+// values (or equals the value for fields without the in operator).
+func parseFilter(res listResource, name string, raw string) (*Filter, error) {
+	fieldName, op := name, FilterOp("")
+	if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") {
+		fieldName, op = name[:i], FilterOp(name[i+1:len(name)-1])
+	}
+	f, ok := res.field(fieldName)
+	if !ok || len(f.ops) == 0 {
+		return nil, fmt.Errorf("%s cannot be filtered on", fieldName)
+	}
+	if op == "" {
+		op = OpEq
+		if f.allows(OpIn) {
+			op = OpIn
+		}
+	}
+	if !f.allows(op) {
+		return nil, fmt.Errorf("%s does not support the %s operator", fieldName, op)
+	}
+
+	raws := []string{raw}
+	if op == OpIn {
+		raws = strings.Split(raw, ",")
+	}
+	filter := &Filter{Field: f.name, Op: op}
+	for _, r := range raws {
+		r = strings.TrimSpace(r)
+		v, err := f.kind.parseValue(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be %s", name, f.kind.describe())
+		}
+		if len(f.values) > 0 && !containsString(f.values, r) {
+			return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(f.values, ", "))
+		}
+		filter.Values = append(filter.Values, v)
+	}
+	if op == OpIn && len(filter.Values) == 1 {
+		filter.Op = OpEq
+	}
+	return filter, nil
+}
+
+// parseSort parses a sort parameter such as "-order_date,total_amount"
+func parseSort(res listResource, raw string) ([]SortField, error) {
+	var sortFields []SortField
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		sf := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		f, ok := res.field(sf.Field)
+		if !ok || !f.sortable {
+			return nil, fmt.Errorf("cannot sort by %q; sortable fields are %s", sf.Field, strings.Join(res.sortableFields(), ", "))
+		}
+		for _, existing := range sortFields {
+			if existing.Field == sf.Field {
+				return nil, fmt.Errorf("%s appears twice in sort", sf.Field)
+			}
+		}
+		sortFields = append(sortFields, sf)
+	}
+	return sortFields, nil
+}
+
+func (r listResource) sortableFields() []string {
+	var names []string
+	for _, f := range r.fields {
+		if f.sortable {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholders returns n comma-separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// escapeLike escapes the LIKE wildcards in s, using ! as the escape
+// character since backslashes are treated differently by MySQL and SQLite
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// filterConditions compiles filters into SQL conditions. Columns come from
+// the field whitelist of res, values are always passed as arguments.
+func filterConditions(res listResource, filters []Filter, arg func(interface{}) interface{}) ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, filter := range filters {
+		f, _ := res.field(filter.Field)
+		switch filter.Op {
+		case OpIn:
+			conds = append(conds, f.column+` IN (`+placeholders(len(filter.Values))+`)`)
+			for _, v := range filter.Values {
+				args = append(args, arg(v))
+			}
+			continue
+		case OpPrefix:
+			conds = append(conds, f.column+` LIKE ? ESCAPE '!'`)
+			args = append(args, escapeLike(filter.Values[0].(string))+"%")
+			continue
+		}
+		op := map[FilterOp]string{OpEq: "=", OpGt: ">", OpGte: ">=", OpLt: "<", OpLte: "<="}[filter.Op]
+		conds = append(conds, f.column+" "+op+" ?")
+		args = append(args, arg(filter.Values[0]))
+	}
+	return conds, args
+}
+
+// matchFilters reports whether a record held in memory passes every filter,
+// comparing the way filterConditions does in SQL
+func matchFilters(filters []Filter, record interface{}) bool {
+	for _, filter := range filters {
+		v := fieldValue(record, filter.Field)
+		var ok bool
+		switch filter.Op {
+		case OpIn:
+			for _, want := range filter.Values {
+				if compareValues(v, want) == 0 {
+					ok = true
+					break
+				}
+			}
+		case OpPrefix:
+			ok = strings.HasPrefix(strings.ToLower(v.(string)), strings.ToLower(filter.Values[0].(string)))
+		default:
+			c := compareValues(v, filter.Values[0])
+			switch filter.Op {
+			case OpEq:
+				ok = c == 0
+			case OpGt:
+				ok = c > 0
+			case OpGte:
+				ok = c >= 0
+			case OpLt:
+				ok = c < 0
+			case OpLte:
+				ok = c <= 0
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFilters(t *testing.T) {
+	p := Paginator{DefaultPageSize: 20, MaxPageSize: 100}
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		res   listResource
+		query string
+		want  []Filter
+	}{
+		{orderListing, "status=pending", []Filter{{"status", OpEq, []interface{}{"pending"}}}},
+		{orderListing, "status=pending,%20ready", []Filter{{"status", OpIn, []interface{}{"pending", "ready"}}}},
+		{orderListing, "customer_id[in]=1,2&restaurant_id=3", []Filter{
+			{"customer_id", OpIn, []interface{}{1, 2}},
+			{"restaurant_id", OpEq, []interface{}{3}},
+		}},
+		{orderListing, "total_amount[gte]=10.5&total_amount[lt]=20", []Filter{
+			{"total_amount", OpGte, []interface{}{Money(1050)}},
+			{"total_amount", OpLt, []interface{}{Money(2000)}},
+		}},
+		{orderListing, "order_date[gt]=2024-01-02", []Filter{{"order_date", OpGt, []interface{}{day}}}},
+		{orderListing, "order_date[lte]=2024-01-02T00:00:00Z", []Filter{{"order_date", OpLte, []interface{}{day}}}},
+		{restaurantListing, "name[prefix]=Pi", []Filter{{"name", OpPrefix, []interface{}{"Pi"}}}},
+		{menuItemListing, "is_available=false", []Filter{{"is_available", OpEq, []interface{}{false}}}},
+		// Parameters that are not fields are left to the handlers
+		{menuItemListing, "q=soup&limit=5", nil},
+	}
+	for _, tt := range tests {
+		opts, err := parseList(p, tt.res, tt.query)
+		if err != nil {
+			t.Errorf("%s?%s: %v", tt.res.table, tt.query, err)
+			continue
+		}
+		if !sameFilters(opts.Filters, tt.want) {
+			t.Errorf("%s?%s: got %+v, want %+v", tt.res.table, tt.query, opts.Filters, tt.want)
+		}
+	}
+}
+
+func sameFilters(got, want []Filter) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].Field != want[i].Field || got[i].Op != want[i].Op || len(got[i].Values) != len(want[i].Values) {
+			return false
+		}
+		for j, v := range got[i].Values {
+			if t, ok := v.(time.Time); ok {
+				if !t.Equal(want[i].Values[j].(time.Time)) {
+					return false
+				}
+			} else if v != want[i].Values[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestParseFiltersRejects(t *testing.T) {
+	p := Paginator{DefaultPageSize: 20, MaxPageSize: 100}
+	tests := []struct {
+		name  string
+		res   listResource
+		query string
+		want  string
+	}{
+		{"unknown field", orderListing, "discount[eq]=1", "discount cannot be filtered on"},
+		{"unknown operator", orderListing, "status[like]=pend", "status does not support the like operator"},
+		{"operator not allowed for the field", orderListing, "order_date[eq]=2024-01-02", "order_date does not support the eq operator"},
+		{"prefix on a number", orderListing, "total_amount[prefix]=1", "does not support the prefix operator"},
+		{"integer", orderListing, "customer_id=abc", "customer_id must be an integer"},
+		{"one bad value in a list", orderListing, "customer_id=1,two", "customer_id must be an integer"},
+		{"amount", orderListing, "total_amount[gt]=12.345", "total_amount[gt] must be an amount"},
+		{"timestamp", orderListing, "order_date[gt]=yesterday", "order_date[gt] must be an RFC 3339 timestamp"},
+		{"boolean", menuItemListing, "is_available=maybe", "is_available must be true or false"},
+		{"enumerated value", orderListing, "status=lost", "status must be one of"},
+		{"unknown sort field", orderListing, "sort=-discount", `cannot sort by "discount"`},
+		{"sort field twice", orderListing, "sort=total_amount,-total_amount", "total_amount appears twice in sort"},
+		{"empty sort field", orderListing, "sort=order_date,", `cannot sort by ""`},
+		{"include", customerListing, "include=orders", "customers have no related records to include"},
+	}
+	for _, tt := range tests {
+		_, err := parseList(p, tt.res, tt.query)
+		var e *Error
+		if !errors.As(err, &e) || e.Status() != http.StatusBadRequest || !strings.Contains(e.Message, tt.want) {
+			t.Errorf("%s: got %v, want a 400 error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []SortField
+		orderBy string
+	}{
+		{"total_amount", []SortField{{Field: "total_amount"}}, " ORDER BY total_amount ASC, id ASC"},
+		{"-order_date", []SortField{{Field: "order_date", Desc: true}}, " ORDER BY order_date DESC, id DESC"},
+		{"-status, total_amount", []SortField{{Field: "status", Desc: true}, {Field: "total_amount"}}, " ORDER BY status DESC, total_amount ASC, id ASC"},
+		{"customer_id,-order_date", []SortField{{Field: "customer_id"}, {Field: "order_date", Desc: true}}, " ORDER BY customer_id ASC, order_date DESC, id DESC"},
+	}
+	for _, tt := range tests {
+		got, err := parseSort(orderListing, tt.raw)
+		if err != nil {
+			t.Errorf("%q: %v", tt.raw, err)
+			continue
+		}
+		if sortKey(got) != sortKey(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.raw, sortKey(got), sortKey(tt.want))
+		}
+		if orderBy := orderByClause(orderListing, got); orderBy != tt.orderBy {
+			t.Errorf("%q: got %q, want %q", tt.raw, orderBy, tt.orderBy)
+		}
+	}
+
+	// Without a sort parameter the resource's default applies
+	opts, err := parseList(Paginator{DefaultPageSize: 20, MaxPageSize: 100}, menuItemListing, "")
+	if err != nil || sortKey(menuItemListing.sortFields(opts)) != "restaurant_id,category,name" {
+		t.Errorf("got %v, %v; want the default sort", opts.Sort, err)
+	}
+}
+
+// sqlVocabulary matches everything list queries may contain besides columns
+var sqlVocabulary = regexp.MustCompile(`\b(?:ORDER BY|AND|OR|IN|LIKE|ESCAPE|ASC|DESC|id)\b|'!'|[?(),=<>\s]`)
+
+// TestListSQLUsesWhitelistedColumns compiles requests trying to smuggle SQL
+// in and checks that the query text holds only the columns of the
+// resource, keywords and placeholders, every value being an argument
+func TestListSQLUsesWhitelistedColumns(t *testing.T) {
+	p := Paginator{DefaultPageSize: 20, MaxPageSize: 100}
+	hostile := []string{
+		"name[prefix]=" + url.QueryEscape("x' OR 1=1 --"),
+		"email=" + url.QueryEscape("a@example.com'); DROP TABLE customers; --"),
+		"id=" + url.QueryEscape("1,2) OR (1=1"),
+		url.QueryEscape("name;DROP TABLE customers[eq]") + "=x",
+		url.QueryEscape("name[eq) OR (1=1]") + "=x",
+		"sort=" + url.QueryEscape("name; DROP TABLE customers"),
+		"sort=" + url.QueryEscape("(SELECT 1)"),
+		"sort=-created_at&created_at[gt]=" + url.QueryEscape("2024-01-01' OR '1'='1"),
+		"sort=-email,name&name[prefix]=" + url.QueryEscape("%_!") + "&created_at[gte]=2024-01-01&id[in]=1,2,3",
+	}
+	compiled := 0
+	for _, query := range hostile {
+		opts, err := parseList(p, customerListing, query)
+		if err != nil {
+			continue
+		}
+		compiled++
+		sortFields := customerListing.sortFields(opts)
+		arg := func(v interface{}) interface{} { return v }
+		conds, args := filterConditions(customerListing, opts.Filters, arg)
+		cond, cursorArgs := keysetCondition(customerListing, sortFields, cursorFor(customerListing, opts, &Customer{}), arg)
+		text := strings.Join(append(conds, cond), " AND ") + orderByClause(customerListing, sortFields)
+
+		rest := text
+		for _, f := range customerListing.fields {
+			rest = strings.ReplaceAll(rest, f.column, "")
+		}
+		if rest = sqlVocabulary.ReplaceAllString(rest, ""); rest != "" {
+			t.Errorf("%s: %q is left in %q", query, rest, text)
+		}
+		if want := strings.Count(text, "?"); len(args)+len(cursorArgs) != want {
+			t.Errorf("%s: got %d arguments for %d placeholders", query, len(args)+len(cursorArgs), want)
+		}
+	}
+	if compiled == 0 {
+		t.Error("every query was rejected, nothing was compiled")
+	}
+
+	// Wildcards in a prefix are matched literally
+	store := newSQLiteStore(t)
+	if _, err := store.CreateCustomer(context.Background(), CreateCustomerRequest{Name: "Ann", Email: "ann@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"name[prefix]=" + url.QueryEscape("%"), "email[prefix]=" + url.QueryEscape("_")} {
+		opts, err := parseList(p, customerListing, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		customers, info, err := store.ListCustomers(context.Background(), opts)
+		if err != nil || info.Total != 0 || len(customers) != 0 {
+			t.Errorf("%s: got %d customers, %v; want none", query, info.Total, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newSQLiteStore creates an empty store on a migrated SQLite database
+func newSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	db := newMigrationDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLStore(db)
+}
+
+// forEachStore runs test as a subtest against an in-memory store and a
+// migrated SQLite store, which must behave the same
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("Memory", func(t *testing.T) { test(t, NewMemoryStore()) })
+	t.Run("SQLite", func(t *testing.T) { test(t, newSQLiteStore(t)) })
+}
+
+// fixture is a restaurant with a customer to order from it, and helpers
+// that fail the test when setting up more records fails
+type fixture struct {
+	t          *testing.T
+	ctx        context.Context
+	store      Store
+	restaurant *Restaurant
+	customer   *Customer
+}
+
+// newFixture creates the restaurant req describes and a customer named
+// after it
+func newFixture(t *testing.T, store Store, req CreateRestaurantRequest) *fixture {
+	t.Helper()
+	f := &fixture{t: t, ctx: context.Background(), store: store}
+	var err error
+	if f.restaurant, err = store.CreateRestaurant(f.ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	email := fmt.Sprintf("customer%d@example.com", f.restaurant.ID)
+	if f.customer, err = store.CreateCustomer(f.ctx, CreateCustomerRequest{Name: req.Name, Email: email}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// menuItem creates a menu item of the restaurant, priced 5.00 unless req
+// sets a price
+func (f *fixture) menuItem(req CreateMenuItemRequest) *MenuItem {
+	f.t.Helper()
+	req.RestaurantID = f.restaurant.ID
+	if req.Price == 0 {
+		req.Price = 500
+	}
+	m, err := f.store.CreateMenuItem(f.ctx, req)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return m
+}
+
+// item creates an available menu item priced 5.00
+func (f *fixture) item(name string) *MenuItem {
+	f.t.Helper()
+	return f.menuItem(CreateMenuItemRequest{Name: name, IsAvailable: true})
+}
+
+// order returns a request for the customer to order items from the
+// restaurant
+func (f *fixture) order(items ...CreateOrderItemRequest) CreateOrderRequest {
+	return CreateOrderRequest{CustomerID: f.customer.ID, RestaurantID: f.restaurant.ID, Items: items}
+}
+
+// line returns an order line for one of menuItem
+func line(menuItem *MenuItem) CreateOrderItemRequest {
+	return CreateOrderItemRequest{MenuItemID: menuItem.ID, Quantity: 1}
+}
+
+// isOrderRule reports whether err is an *OrderError whose first problem
+// breaks rule, on field unless it is empty
+func isOrderRule(err error, field, rule string) bool {
+	var oerr *OrderError
+	return errors.As(err, &oerr) && oerr.Fields[0].Rule == rule && (field == "" || oerr.Fields[0].Field == field)
+}
+
+// newTestServer serves the API from store with the default configuration
+func newTestServer(store Store) *echo.Echo {
+	cfg := DefaultConfig()
+	return newServer(&cfg, store)
+}
+
+// serveJSON sends a request with a JSON body, which is left out when empty,
+// and returns the response
+func serveJSON(e *echo.Echo, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// problemOf decodes the problem document of an error response
+func problemOf(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return p
+}
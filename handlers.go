@@ -1,7 +1,7 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,134 +10,222 @@ import (
 
 // RestaurantHandler handles restaurant-related requests
 type RestaurantHandler struct {
-	db *Database
+	store RestaurantStore
+	pager Paginator
 }
 
 // NewRestaurantHandler creates a new restaurant handler
-// func NewRestaurantNaja(db *Database) *RestaurantHandler {
-// 	return &RestaurantHandler{db: db}
+// func NewRestaurantNaja(store RestaurantStore, pager Paginator) *RestaurantHandler {
+// 	return &RestaurantHandler{store: store, pager: pager}
 // }
 
 // CreateRestaurant creates a new restaurant
 func (h *RestaurantHandler) CreateRestaurant(c echo.Context) error {
 	var req CreateRestaurantRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
 	}
-
-	query := `INSERT INTO restaurants (name, address, phone, email, cuisine_type) VALUES (?, ?, ?, ?, ?)`
-	result, err := h.db.Exec(query, req.Name, req.Address, req.Phone, req.Email, req.CuisineType)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create restaurant"})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	id, _ := result.LastInsertId()
-	restaurant, err := h.GetRestaurantByID(int(id))
+	restaurant, err := h.store.CreateRestaurant(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch created restaurant"})
+		return storeError("Failed to create restaurant", err)
 	}
 
 	return c.JSON(http.StatusCreated, restaurant)
 }
 
-// GetRestaurants retrieves all restaurants
+// GetRestaurants retrieves a page of restaurants
 func (h *RestaurantHandler) GetRestaurants(c echo.Context) error {
-	query := `SELECT id, name, address, phone, email, cuisine_type, created_at, updated_at FROM restaurants ORDER BY created_at DESC`
-	rows, err := h.db.Query(query)
+	opts, err := h.pager.Parse(c, restaurantListing)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch restaurants"})
+		return err
 	}
-	defer rows.Close()
 
-	var restaurants []Restaurant
-	for rows.Next() {
-		var r Restaurant
-		err := rows.Scan(&r.ID, &r.Name, &r.Address, &r.Phone, &r.Email, &r.CuisineType, &r.CreatedAt, &r.UpdatedAt)
-		if err != nil {
-			continue
-		}
-		restaurants = append(restaurants, r)
+	restaurants, info, err := h.store.ListRestaurants(c.Request().Context(), opts)
+	if err != nil {
+		return Internal("Failed to fetch restaurants", err)
 	}
 
-	return c.JSON(http.StatusOK, restaurants)
+	return h.pager.Respond(c, restaurantListing, opts, restaurants, info)
 }
 
 // GetRestaurant retrieves a restaurant by ID
 func (h *RestaurantHandler) GetRestaurant(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid restaurant ID"})
+		return BadRequest("invalid_id", "Invalid restaurant ID")
 	}
 
-	restaurant, err := h.GetRestaurantByID(id)
+	restaurant, err := h.store.GetRestaurant(c.Request().Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Restaurant not found"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch restaurant"})
+		return Internal("Failed to fetch restaurant", err)
 	}
 
 	return c.JSON(http.StatusOK, restaurant)
 }
 
-// GetRestaurantByID helper method
-func (h *RestaurantHandler) GetRestaurantByID(id int) (*Restaurant, error) {
-	query := `SELECT id, name, address, phone, email, cuisine_type, created_at, updated_at FROM restaurants WHERE id = ?`
-	var r Restaurant
-	err := h.db.QueryRow(query, id).Scan(&r.ID, &r.Name, &r.Address, &r.Phone, &r.Email, &r.CuisineType, &r.CreatedAt, &r.UpdatedAt)
+// UpdateRestaurant updates a restaurant
+func (h *RestaurantHandler) UpdateRestaurant(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return nil, err
+		return BadRequest("invalid_id", "Invalid restaurant ID")
 	}
-	return &r, nil
+
+	var req CreateRestaurantRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	restaurant, err := h.store.UpdateRestaurant(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return storeError("Failed to update restaurant", err)
+	}
+
+	return c.JSON(http.StatusOK, restaurant)
 }
 
-// UpdateRestaurant updates a restaurant
-func (h *RestaurantHandler) UpdateRestaurant(c echo.Context) error {
+// GetRestaurantPricing returns the taxes, service charge and delivery fees
+// of a restaurant
+func (h *RestaurantHandler) GetRestaurantPricing(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid restaurant ID"})
+		return BadRequest("invalid_id", "Invalid restaurant ID")
 	}
 
-	var req CreateRestaurantRequest
+	pricing, err := h.store.GetRestaurantPricing(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return Internal("Failed to fetch restaurant pricing", err)
+	}
+
+	return c.JSON(http.StatusOK, pricing)
+}
+
+// UpdateRestaurantPricing replaces the pricing of a restaurant
+func (h *RestaurantHandler) UpdateRestaurantPricing(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid restaurant ID")
+	}
+
+	var req RestaurantPricingRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	query := `UPDATE restaurants SET name = ?, address = ?, phone = ?, email = ?, cuisine_type = ? WHERE id = ?`
-	result, err := h.db.Exec(query, req.Name, req.Address, req.Phone, req.Email, req.CuisineType, id)
+	pricing, err := h.store.UpdateRestaurantPricing(c.Request().Context(), id, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update restaurant"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return storeError("Failed to update restaurant pricing", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Restaurant not found"})
+	return c.JSON(http.StatusOK, pricing)
+}
+
+// GetOpeningHours retrieves the shifts and closures of a restaurant
+func (h *RestaurantHandler) GetOpeningHours(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid restaurant ID")
 	}
 
-	restaurant, err := h.GetRestaurantByID(id)
+	hours, err := h.store.GetOpeningHours(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch updated restaurant"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return Internal("Failed to fetch opening hours", err)
 	}
 
-	return c.JSON(http.StatusOK, restaurant)
+	return c.JSON(http.StatusOK, hours)
 }
 
-// DeleteRestaurant deletes a restaurant
-func (h *RestaurantHandler) DeleteRestaurant(c echo.Context) error {
+// UpdateOpeningHours replaces the shifts and closures of a restaurant
+func (h *RestaurantHandler) UpdateOpeningHours(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid restaurant ID")
+	}
+
+	var req OpeningHoursRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+	if fields := req.check(); len(fields) > 0 {
+		return Validation(fields)
+	}
+
+	hours, err := h.store.UpdateOpeningHours(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return storeError("Failed to update opening hours", err)
+	}
+
+	return c.JSON(http.StatusOK, hours)
+}
+
+// UpdateOrdering pauses or resumes ordering at a restaurant
+func (h *RestaurantHandler) UpdateOrdering(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid restaurant ID"})
+		return BadRequest("invalid_id", "Invalid restaurant ID")
+	}
+
+	var req OrderingRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	restaurant, err := h.store.SetOrderingPaused(c.Request().Context(), id, *req.Paused)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return storeError("Failed to update ordering", err)
 	}
 
-	query := `DELETE FROM restaurants WHERE id = ?`
-	result, err := h.db.Exec(query, id)
+	return c.JSON(http.StatusOK, restaurant)
+}
+
+// DeleteRestaurant deletes a restaurant
+func (h *RestaurantHandler) DeleteRestaurant(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete restaurant"})
+		return BadRequest("invalid_id", "Invalid restaurant ID")
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Restaurant not found"})
+	if err := h.store.DeleteRestaurant(c.Request().Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("restaurant")
+		}
+		return storeError("Failed to delete restaurant", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Restaurant deleted successfully"})
@@ -145,124 +233,184 @@ func (h *RestaurantHandler) DeleteRestaurant(c echo.Context) error {
 
 // MenuItemHandler handles menu item-related requests
 type MenuItemHandler struct {
-	db *Database
+	store MenuItemStore
+	pager Paginator
 }
 
 // NewMenuItemHandler creates a new menu item handler
-func NewMenuItemHandler(db *Database) *MenuItemHandler {
-	return &MenuItemHandler{db: db}
+func NewMenuItemHandler(store MenuItemStore, pager Paginator) *MenuItemHandler {
+	return &MenuItemHandler{store: store, pager: pager}
 }
 
 // CreateMenuItem creates a new menu item
 func (h *MenuItemHandler) CreateMenuItem(c echo.Context) error {
 	var req CreateMenuItemRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
 	}
-
-	query := `INSERT INTO menu_items (restaurant_id, name, description, price, category, is_available) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := h.db.Exec(query, req.RestaurantID, req.Name, req.Description, req.Price, req.Category, req.IsAvailable)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create menu item"})
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	id, _ := result.LastInsertId()
-	menuItem, err := h.GetMenuItemByID(int(id))
+	menuItem, err := h.store.CreateMenuItem(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch created menu item"})
+		return storeError("Failed to create menu item", err)
 	}
 
 	return c.JSON(http.StatusCreated, menuItem)
 }
 
-// GetMenuItems retrieves menu items (optionally filtered by restaurant)
+// GetMenuItems retrieves a filtered page of menu items
 func (h *MenuItemHandler) GetMenuItems(c echo.Context) error {
-	restaurantID := c.QueryParam("restaurant_id")
-
-	var query string
-	var args []interface{}
+	opts, err := h.pager.Parse(c, menuItemListing)
+	if err != nil {
+		return err
+	}
 
-	if restaurantID != "" {
-		query = `SELECT id, restaurant_id, name, description, price, category, is_available, created_at, updated_at FROM menu_items WHERE restaurant_id = ? ORDER BY category, name`
-		args = append(args, restaurantID)
-	} else {
-		query = `SELECT id, restaurant_id, name, description, price, category, is_available, created_at, updated_at FROM menu_items ORDER BY restaurant_id, category, name`
+	menuItems, info, err := h.store.ListMenuItems(c.Request().Context(), opts)
+	if err != nil {
+		return Internal("Failed to fetch menu items", err)
 	}
 
-	rows, err := h.db.Query(query, args...)
+	return h.pager.Respond(c, menuItemListing, opts, menuItems, info)
+}
+
+// GetMenuItem retrieves a menu item by ID
+func (h *MenuItemHandler) GetMenuItem(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch menu items"})
+		return BadRequest("invalid_id", "Invalid menu item ID")
 	}
-	defer rows.Close()
 
-	var menuItems []MenuItem
-	for rows.Next() {
-		var m MenuItem
-		err := rows.Scan(&m.ID, &m.RestaurantID, &m.Name, &m.Description, &m.Price, &m.Category, &m.IsAvailable, &m.CreatedAt, &m.UpdatedAt)
-		if err != nil {
-			continue
+	menuItem, err := h.store.GetMenuItem(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
 		}
-		menuItems = append(menuItems, m)
+		return Internal("Failed to fetch menu item", err)
 	}
 
-	return c.JSON(http.StatusOK, menuItems)
+	return c.JSON(http.StatusOK, menuItem)
 }
 
-// GetMenuItem retrieves a menu item by ID
-func (h *MenuItemHandler) GetMenuItem(c echo.Context) error {
+// UpdateMenuItem updates a menu item
+func (h *MenuItemHandler) UpdateMenuItem(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid menu item ID"})
+		return BadRequest("invalid_id", "Invalid menu item ID")
+	}
+
+	var req CreateMenuItemRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
 
-	menuItem, err := h.GetMenuItemByID(id)
+	menuItem, err := h.store.UpdateMenuItem(c.Request().Context(), id, req)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Menu item not found"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch menu item"})
+		return storeError("Failed to update menu item", err)
 	}
 
 	return c.JSON(http.StatusOK, menuItem)
 }
 
-// GetMenuItemByID helper method
-func (h *MenuItemHandler) GetMenuItemByID(id int) (*MenuItem, error) {
-	query := `SELECT id, restaurant_id, name, description, price, category, is_available, created_at, updated_at FROM menu_items WHERE id = ?`
-	var m MenuItem
-	err := h.db.QueryRow(query, id).Scan(&m.ID, &m.RestaurantID, &m.Name, &m.Description, &m.Price, &m.Category, &m.IsAvailable, &m.CreatedAt, &m.UpdatedAt)
+// UpdateMenuItemOptions replaces the option groups of a menu item
+func (h *MenuItemHandler) UpdateMenuItemOptions(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return nil, err
+		return BadRequest("invalid_id", "Invalid menu item ID")
+	}
+
+	var req MenuItemOptionsRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
 	}
-	return &m, nil
+	if fields := req.check(); len(fields) > 0 {
+		return Validation(fields)
+	}
+
+	menuItem, err := h.store.UpdateMenuItemOptions(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
+		}
+		return storeError("Failed to update menu item options", err)
+	}
+
+	return c.JSON(http.StatusOK, menuItem)
 }
 
-// UpdateMenuItem updates a menu item
-func (h *MenuItemHandler) UpdateMenuItem(c echo.Context) error {
+// UpdateBundleSlots replaces the slots of a bundle
+func (h *MenuItemHandler) UpdateBundleSlots(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid menu item ID"})
+		return BadRequest("invalid_id", "Invalid menu item ID")
 	}
 
-	var req CreateMenuItemRequest
+	var req BundleSlotsRequest
+	if err := c.Bind(&req); err != nil {
+		return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+	if fields := req.check(); len(fields) > 0 {
+		return Validation(fields)
+	}
+
+	menuItem, err := h.store.UpdateBundleSlots(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
+		}
+		return storeError("Failed to update bundle slots", err)
+	}
+
+	return c.JSON(http.StatusOK, menuItem)
+}
+
+// bindAvailability binds and validates an availability request
+func bindAvailability(c echo.Context) (AvailabilityRequest, error) {
+	var req AvailabilityRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return req, BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return req, err
+	}
+	if fields := req.check(); len(fields) > 0 {
+		return req, Validation(fields)
 	}
+	return req, nil
+}
 
-	query := `UPDATE menu_items SET restaurant_id = ?, name = ?, description = ?, price = ?, category = ?, is_available = ? WHERE id = ?`
-	result, err := h.db.Exec(query, req.RestaurantID, req.Name, req.Description, req.Price, req.Category, req.IsAvailable, id)
+// UpdateMenuItemAvailability replaces the availability windows of a menu
+// item
+func (h *MenuItemHandler) UpdateMenuItemAvailability(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update menu item"})
+		return BadRequest("invalid_id", "Invalid menu item ID")
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Menu item not found"})
+	req, err := bindAvailability(c)
+	if err != nil {
+		return err
 	}
 
-	menuItem, err := h.GetMenuItemByID(id)
+	menuItem, err := h.store.UpdateMenuItemAvailability(c.Request().Context(), id, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch updated menu item"})
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
+		}
+		return storeError("Failed to update menu item availability", err)
 	}
 
 	return c.JSON(http.StatusOK, menuItem)
@@ -272,18 +420,14 @@ func (h *MenuItemHandler) UpdateMenuItem(c echo.Context) error {
 func (h *MenuItemHandler) DeleteMenuItem(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid menu item ID"})
+		return BadRequest("invalid_id", "Invalid menu item ID")
 	}
 
-	query := `DELETE FROM menu_items WHERE id = ?`
-	result, err := h.db.Exec(query, id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete menu item"})
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Menu item not found"})
+	if err := h.store.DeleteMenuItem(c.Request().Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("menu item")
+		}
+		return storeError("Failed to delete menu item", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Menu item deleted successfully"})
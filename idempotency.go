@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key of a request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored one
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// idempotencyLockTimeout is how long a key stays claimed by a request
+	// that has not finished, e.g. because its server stopped; after that a
+	// retry may take the key over
+	idempotencyLockTimeout = time.Minute
+	// idempotencyMaxWait bounds how long a duplicate waits for the request
+	// holding its key before giving up with a conflict
+	idempotencyMaxWait = 10 * time.Second
+	// idempotencyPollInterval is how often a waiting duplicate checks the key
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. Keys are unique within a scope, the method and route of
+// the request. StatusCode is 0 while the first request is in progress.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// completed reports whether the response of the request has been stored
+func (r *IdempotencyRecord) completed() bool {
+	return r.StatusCode != 0
+}
+
+// heldBy reports whether the record is still the in-progress claim of
+// claim, which a retry taking the key over would have replaced
+func (r *IdempotencyRecord) heldBy(claim IdempotencyRecord) bool {
+	return !r.completed() && r.RequestHash == claim.RequestHash && r.CreatedAt.Equal(claim.CreatedAt)
+}
+
+// live reports whether the record still holds its key at now: it has not
+// expired, and it either has a response or its request is still running
+func (r *IdempotencyRecord) live(now time.Time) bool {
+	return r.ExpiresAt.After(now) && (r.completed() || r.CreatedAt.After(now.Add(-idempotencyLockTimeout)))
+}
+
+// ErrIdempotencyKeyLost is returned when a request completes or releases an
+// Idempotency-Key that another request has taken over in the meantime
+var ErrIdempotencyKeyLost = errors.New("idempotency key was claimed by another request")
+
+// idempotency holds the state of the Idempotency middleware
+type idempotency struct {
+	store   IdempotencyStore
+	window  time.Duration
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+// Idempotency makes a route safe to retry. The first request with a given
+// Idempotency-Key header runs normally and its response is stored for the
+// configured window; a retry with the same key and body gets that response
+// again, a retry with a different body is rejected, and a retry arriving
+// while the first request still runs waits for it. Requests without the
+// header are not affected.
+//
+// Responses with a 5xx status are not stored, so the request can be
+// retried with the same key.
+func Idempotency(store IdempotencyStore, cfg IdempotencyConfig) echo.MiddlewareFunc {
+	return newIdempotency(store, cfg).middleware
+}
+
+func newIdempotency(store IdempotencyStore, cfg IdempotencyConfig) *idempotency {
+	return &idempotency{store: store, window: cfg.Window, maxWait: idempotencyMaxWait, now: time.Now}
+}
+
+func (m *idempotency) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return next(c)
+		}
+		if !validIdempotencyKey(key) {
+			return BadRequest("invalid_idempotency_key", "The Idempotency-Key header must be at most 255 printable ASCII characters")
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return BadRequest("invalid_body", "Invalid request body").WithCause(err)
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := IdempotencyRecord{
+			Scope:       c.Request().Method + " " + c.Path(),
+			Key:         key,
+			RequestHash: hashRequestBody(body),
+		}
+		stored, err := m.claim(c.Request().Context(), &rec)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			c.Response().Header().Set(IdempotentReplayedHeader, "true")
+			return c.Blob(stored.StatusCode, stored.ContentType, stored.Body)
+		}
+
+		// The key is ours until the response is stored or the key is
+		// released; neither may be skipped because the client went away
+		ctx := context.WithoutCancel(c.Request().Context())
+		done := false
+		defer func() {
+			if !done {
+				if err := m.store.ReleaseIdempotencyKey(ctx, rec); err != nil && !errors.Is(err, ErrIdempotencyKeyLost) {
+					c.Logger().Error(err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		c.Response().Writer = recorder.ResponseWriter
+
+		res := c.Response()
+		if !res.Committed || res.Status >= http.StatusInternalServerError {
+			return nil
+		}
+		rec.StatusCode = res.Status
+		rec.ContentType = res.Header().Get(echo.HeaderContentType)
+		rec.Body = recorder.body.Bytes()
+		err = m.store.CompleteIdempotencyKey(ctx, rec)
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyLost):
+			// The request ran past the lock timeout and a retry took the
+			// key over; the retry's response is the one kept
+			c.Logger().Warnf("Idempotency-Key %q was taken over before the response of %s was stored", rec.Key, rec.Scope)
+		case err != nil:
+			c.Logger().Error(err)
+			return nil
+		}
+		done = true
+		return nil
+	}
+}
+
+// claim claims the key of rec for the current request and returns nil, or
+// returns the stored response of an earlier request with the same key and
+// body. While another request holds the key it waits for that request to
+// finish.
+func (m *idempotency) claim(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	deadline := m.now().Add(m.maxWait)
+	for {
+		// Whole seconds, the precision of the created_at column, so the
+		// claim can be recognised when its response is stored
+		now := m.now().Truncate(time.Second)
+		rec.CreatedAt, rec.ExpiresAt = now, now.Add(m.window)
+		stored, err := m.store.ClaimIdempotencyKey(ctx, *rec)
+		if err != nil {
+			return nil, Internal("Failed to check the idempotency key", err)
+		}
+		switch {
+		case stored == nil:
+			return nil, nil
+		case stored.RequestHash != rec.RequestHash:
+			return nil, &Error{
+				Kind:    KindValidation,
+				Code:    "idempotency_key_reused",
+				Message: "This Idempotency-Key was already used with a different request body",
+			}
+		case stored.completed():
+			return stored, nil
+		case !m.now().Before(deadline):
+			return nil, Conflict("idempotency_key_in_use", "A request with this Idempotency-Key is still being processed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// validIdempotencyKey reports whether key is at most 255 printable ASCII
+// characters, the size of the idempotency_key column
+func validIdempotencyKey(key string) bool {
+	if len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// hashRequestBody returns the SHA-256 of a request body. JSON bodies are
+// hashed in a canonical form, so retries that only differ in whitespace or
+// key order count as the same request.
+func hashRequestBody(body []byte) string {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
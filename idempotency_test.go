@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// idempotencyServer serves POST /things through the Idempotency middleware.
+// The handler counts its calls and, while gate is set, waits for it to be
+// closed after signalling on entered.
+type idempotencyServer struct {
+	e       *echo.Echo
+	m       *idempotency
+	calls   atomic.Int32
+	gate    chan struct{}
+	entered chan struct{}
+	offset  time.Duration // added to the middleware's clock
+}
+
+func newIdempotencyServer(store IdempotencyStore) *idempotencyServer {
+	s := &idempotencyServer{e: echo.New()}
+	s.e.HTTPErrorHandler = NewHTTPErrorHandler(s.e)
+	s.m = newIdempotency(store, IdempotencyConfig{Window: time.Hour})
+	s.m.now = func() time.Time { return time.Now().Add(s.offset) }
+	s.e.POST("/things", func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		n := s.calls.Add(1)
+		if s.gate != nil {
+			s.entered <- struct{}{}
+			<-s.gate
+		}
+		return c.JSON(http.StatusCreated, map[string]interface{}{"call": n, "body": string(body)})
+	}, s.m.middleware)
+	return s
+}
+
+func (s *idempotencyServer) post(key, body string) (int, string, bool) {
+	header := http.Header{}
+	if key != "" {
+		header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := serveJSON(s.e, http.MethodPost, "/things", body, header)
+	return rec.Code, rec.Body.String(), rec.Header().Get(IdempotentReplayedHeader) == "true"
+}
+
+func TestIdempotency(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		s := newIdempotencyServer(store)
+
+		// A retry gets the stored response; bodies are compared as JSON
+		status, first, _ := s.post("replay", `{"name": "a", "size": 1}`)
+		if status != http.StatusCreated {
+			t.Fatalf("got %d %s, want 201", status, first)
+		}
+		status, again, replayed := s.post("replay", `{ "size": 1, "name": "a" }`)
+		if status != http.StatusCreated || again != first || !replayed || s.calls.Load() != 1 {
+			t.Errorf("retry: got %d %s replayed %v after %d calls, want the first response replayed", status, again, replayed, s.calls.Load())
+		}
+
+		// A different body is rejected
+		rec := serveJSON(s.e, http.MethodPost, "/things", `{"name": "b"}`, http.Header{IdempotencyKeyHeader: {"replay"}})
+		if p := problemOf(t, rec); rec.Code != http.StatusUnprocessableEntity || p.Code != "idempotency_key_reused" {
+			t.Errorf("different body: got %d %s, want 422 idempotency_key_reused", rec.Code, p.Code)
+		}
+
+		// Requests without a key always run
+		s.post("", `{}`)
+		s.post("", `{}`)
+		if s.calls.Load() != 3 {
+			t.Errorf("without a key: got %d calls, want 3", s.calls.Load())
+		}
+	})
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		s := newIdempotencyServer(store)
+		s.gate, s.entered = make(chan struct{}), make(chan struct{}, 1)
+		type response struct {
+			status   int
+			body     string
+			replayed bool
+		}
+		firstDone := make(chan response)
+		go func() {
+			status, body, replayed := s.post("busy", `{"name": "a"}`)
+			firstDone <- response{status, body, replayed}
+		}()
+		<-s.entered
+
+		// A duplicate gives up once it has waited too long
+		s.m.maxWait = 2 * idempotencyPollInterval
+		rec := serveJSON(s.e, http.MethodPost, "/things", `{"name": "a"}`, http.Header{IdempotencyKeyHeader: {"busy"}})
+		if p := problemOf(t, rec); rec.Code != http.StatusConflict || p.Code != "idempotency_key_in_use" {
+			t.Errorf("while running: got %d %s, want 409 idempotency_key_in_use", rec.Code, p.Code)
+		}
+
+		// and otherwise gets the response once the first request finishes
+		s.m.maxWait = idempotencyMaxWait
+		waitingDone := make(chan response)
+		go func() {
+			status, body, replayed := s.post("busy", `{"name": "a"}`)
+			waitingDone <- response{status, body, replayed}
+		}()
+		time.Sleep(3 * idempotencyPollInterval)
+		close(s.gate)
+		first, waiting := <-firstDone, <-waitingDone
+		if first.status != http.StatusCreated || first.replayed {
+			t.Errorf("first: got %+v, want 201", first)
+		}
+		if waiting.body != first.body || !waiting.replayed || s.calls.Load() != 1 {
+			t.Errorf("waiting: got %+v after %d calls, want the first response replayed", waiting, s.calls.Load())
+		}
+	})
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		s := newIdempotencyServer(store)
+
+		// A key whose request was abandoned is taken over after the lock
+		// timeout, and the abandoned request cannot store its response
+		started := time.Now().Add(-idempotencyLockTimeout - time.Second).Truncate(time.Second)
+		abandoned := IdempotencyRecord{Scope: "POST /things", Key: "abandoned", RequestHash: hashRequestBody([]byte(`{}`)), CreatedAt: started, ExpiresAt: started.Add(time.Hour)}
+		if stored, err := store.ClaimIdempotencyKey(ctx, abandoned); err != nil || stored != nil {
+			t.Fatalf("got %v, %v; want the key claimed", stored, err)
+		}
+		if status, _, replayed := s.post("abandoned", `{}`); status != http.StatusCreated || replayed || s.calls.Load() != 1 {
+			t.Errorf("after the lock timeout: got %d replayed %v after %d calls, want the request run", status, replayed, s.calls.Load())
+		}
+		abandoned.StatusCode = http.StatusCreated
+		if err := store.CompleteIdempotencyKey(ctx, abandoned); !errors.Is(err, ErrIdempotencyKeyLost) {
+			t.Errorf("completing the abandoned request: got %v, want ErrIdempotencyKeyLost", err)
+		}
+		if _, body, replayed := s.post("abandoned", `{}`); !replayed || body != "{\"body\":\"{}\",\"call\":1}\n" {
+			t.Errorf("got %s replayed %v, want the response of the request that took over", body, replayed)
+		}
+
+		// A response is only kept for the window
+		s.post("window", `{}`)
+		s.offset = time.Hour + time.Minute
+		if status, _, replayed := s.post("window", `{}`); status != http.StatusCreated || replayed || s.calls.Load() != 3 {
+			t.Errorf("after the window: got %d replayed %v after %d calls, want the request run again", status, replayed, s.calls.Load())
+		}
+	})
+}
+
+// TestIdempotencyKeyOwnership checks that only the claim holding a key can
+// complete or release it
+func TestIdempotencyKeyOwnership(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now().Truncate(time.Second)
+		claim := func(hash string, at time.Time) IdempotencyRecord {
+			return IdempotencyRecord{Scope: "POST /things", Key: "key", RequestHash: hash, CreatedAt: at, ExpiresAt: at.Add(time.Hour), StatusCode: http.StatusCreated}
+		}
+		first := claim("first", now)
+		if _, err := store.ClaimIdempotencyKey(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		for _, other := range []IdempotencyRecord{claim("other", now), claim("first", now.Add(time.Second))} {
+			if err := store.CompleteIdempotencyKey(ctx, other); !errors.Is(err, ErrIdempotencyKeyLost) {
+				t.Errorf("completing %s at %s: got %v, want ErrIdempotencyKeyLost", other.RequestHash, other.CreatedAt, err)
+			}
+		}
+
+		takeover := claim("first", now.Add(idempotencyLockTimeout+time.Second))
+		if stored, err := store.ClaimIdempotencyKey(ctx, takeover); err != nil || stored != nil {
+			t.Fatalf("taking over: got %v, %v; want the key claimed", stored, err)
+		}
+		if err := store.CompleteIdempotencyKey(ctx, first); !errors.Is(err, ErrIdempotencyKeyLost) {
+			t.Errorf("completing the first claim: got %v, want ErrIdempotencyKeyLost", err)
+		}
+		if err := store.ReleaseIdempotencyKey(ctx, first); !errors.Is(err, ErrIdempotencyKeyLost) {
+			t.Errorf("releasing the first claim: got %v, want ErrIdempotencyKeyLost", err)
+		}
+		stored, err := store.ClaimIdempotencyKey(ctx, claim("first", takeover.CreatedAt.Add(time.Second)))
+		if err != nil || stored == nil || stored.completed() {
+			t.Fatalf("got %+v, %v; want the takeover still in progress", stored, err)
+		}
+
+		if err := store.CompleteIdempotencyKey(ctx, takeover); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CompleteIdempotencyKey(ctx, takeover); !errors.Is(err, ErrIdempotencyKeyLost) {
+			t.Errorf("completing twice: got %v, want ErrIdempotencyKeyLost", err)
+		}
+		if err := store.ReleaseIdempotencyKey(ctx, takeover); !errors.Is(err, ErrIdempotencyKeyLost) {
+			t.Errorf("releasing a completed key: got %v, want ErrIdempotencyKeyLost", err)
+		}
+	})
+}
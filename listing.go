@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+// or was issued for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField orders a list by one field
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions selects a page of a list. Records matching every filter are
+// ordered by Sort, then by ID. A zero Limit returns every record.
+type ListOptions struct {
+	Filters []Filter
+	Sort    []SortField
+	// Include names the related records to load with each record, e.g.
+	// "items.menu_item"
+	Include []string
+	Limit   int
+	Offset  int
+	// After continues a list after the last record of the previous page
+	After *Cursor
+}
+
+// includes reports whether the related records named by path are loaded.
+// Including a nested path such as "items.menu_item" includes its parents.
+func (o ListOptions) includes(path string) bool {
+	for _, inc := range o.Include {
+		if inc == path || strings.HasPrefix(inc, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// PageInfo describes the page returned by a list query
+type PageInfo struct {
+	// Total counts every record matching the query, across all pages
+	Total int
+	// HasMore reports whether records follow the returned page
+	HasMore bool
+}
+
+// fieldKind is the type of a listable field
+type fieldKind int
+
+const (
+	intField fieldKind = iota
+	moneyField
+	stringField
+	timeField
+	boolField
+)
+
+// listField is a field list endpoints can sort and filter by. name is the
+// JSON name used in the API and by the models.
+type listField struct {
+	name     string
+	column   string
+	kind     fieldKind
+	sortable bool
+	// ops are the filter operators allowed on the field; none means it
+	// cannot be filtered on
+	ops []FilterOp
+	// values, if set, lists every value the field may be filtered by
+	values []string
+}
+
+// listResource describes a listable table. Only the fields listed here can
+// be used in a query, which keeps user input out of the SQL text.
+type listResource struct {
+	table       string
+	fields      []listField
+	defaultSort []SortField
+	// includes lists the related records that can be requested with
+	// include, and defaultInclude those loaded when it is absent
+	includes       []string
+	defaultInclude []string
+}
+
+func (r listResource) field(name string) (listField, bool) {
+	for _, f := range r.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return listField{}, false
+}
+
+// sortFields returns the sort order of opts, or the default order
+func (r listResource) sortFields(opts ListOptions) []SortField {
+	if len(opts.Sort) > 0 {
+		return opts.Sort
+	}
+	return r.defaultSort
+}
+
+var restaurantListing = listResource{
+	table: "restaurants",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		{name: "name", column: "name", kind: stringField, sortable: true, ops: textOps},
+		{name: "cuisine_type", column: "cuisine_type", kind: stringField, sortable: true, ops: textOps},
+		{name: "currency", column: "currency", kind: stringField, sortable: true, ops: idOps},
+		{name: "created_at", column: "created_at", kind: timeField, sortable: true, ops: timeOps},
+		{name: "updated_at", column: "updated_at", kind: timeField, sortable: true, ops: timeOps},
+	},
+	defaultSort: []SortField{{Field: "created_at", Desc: true}},
+}
+
+var menuItemListing = listResource{
+	table: "menu_items",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		{name: "restaurant_id", column: "restaurant_id", kind: intField, sortable: true, ops: idOps},
+		{name: "name", column: "name", kind: stringField, sortable: true, ops: textOps},
+		// Uncategorized items have a NULL category_id, listed as 0
+		{name: "category_id", column: "COALESCE(category_id, 0)", kind: intField, sortable: true, ops: idOps},
+		{name: "category", column: "category", kind: stringField, sortable: true, ops: textOps},
+		{name: "kind", column: "kind", kind: stringField, sortable: true, ops: idOps, values: MenuItemKinds},
+		{name: "price", column: "price", kind: moneyField, sortable: true, ops: numberOps},
+		{name: "is_available", column: "is_available", kind: boolField, sortable: true, ops: boolOps},
+		{name: "created_at", column: "created_at", kind: timeField, sortable: true, ops: timeOps},
+	},
+	defaultSort: []SortField{{Field: "restaurant_id"}, {Field: "category"}, {Field: "name"}},
+}
+
+var categoryListing = listResource{
+	table: "categories",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		{name: "restaurant_id", column: "restaurant_id", kind: intField, sortable: true, ops: idOps},
+		// Top-level categories have a NULL parent_id, listed as 0
+		{name: "parent_id", column: "COALESCE(parent_id, 0)", kind: intField, sortable: true, ops: idOps},
+		{name: "name", column: "name", kind: stringField, sortable: true, ops: textOps},
+		{name: "position", column: "position", kind: intField, sortable: true, ops: numberOps},
+		{name: "is_visible", column: "is_visible", kind: boolField, sortable: true, ops: boolOps},
+		{name: "created_at", column: "created_at", kind: timeField, sortable: true, ops: timeOps},
+	},
+	defaultSort: []SortField{{Field: "restaurant_id"}, {Field: "position"}, {Field: "name"}},
+}
+
+var customerListing = listResource{
+	table: "customers",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		{name: "name", column: "name", kind: stringField, sortable: true, ops: textOps},
+		{name: "email", column: "email", kind: stringField, sortable: true, ops: textOps},
+		{name: "created_at", column: "created_at", kind: timeField, sortable: true, ops: timeOps},
+	},
+	defaultSort: []SortField{{Field: "created_at", Desc: true}},
+}
+
+var orderListing = listResource{
+	table: "orders",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		{name: "customer_id", column: "customer_id", kind: intField, sortable: true, ops: idOps},
+		{name: "restaurant_id", column: "restaurant_id", kind: intField, sortable: true, ops: idOps},
+		{name: "status", column: "status", kind: stringField, sortable: true, ops: idOps, values: OrderStatuses},
+		{name: "order_date", column: "order_date", kind: timeField, sortable: true, ops: timeOps},
+		{name: "total_amount", column: "total_amount", kind: moneyField, sortable: true, ops: numberOps},
+		{name: "currency", column: "currency", kind: stringField, sortable: true, ops: idOps},
+	},
+	defaultSort:    []SortField{{Field: "order_date", Desc: true}},
+	includes:       []string{"items", "items.menu_item", "customer", "restaurant"},
+	defaultInclude: []string{"items", "items.menu_item"},
+}
+
+var promotionListing = listResource{
+	table: "promotions",
+	fields: []listField{
+		{name: "id", column: "id", kind: intField, sortable: true, ops: idOps},
+		// Automatic promotions have a NULL code, listed as ""
+		{name: "code", column: "COALESCE(code, '')", kind: stringField, sortable: true, ops: textOps},
+		{name: "name", column: "name", kind: stringField, sortable: true, ops: textOps},
+		{name: "kind", column: "kind", kind: stringField, sortable: true, ops: idOps, values: PromotionKinds},
+		{name: "currency", column: "currency", kind: stringField, sortable: true, ops: idOps},
+		{name: "is_active", column: "is_active", kind: boolField, sortable: true, ops: boolOps},
+		{name: "created_at", column: "created_at", kind: timeField, sortable: true, ops: timeOps},
+	},
+	defaultSort: []SortField{{Field: "created_at", Desc: true}},
+}
+
+// Cursor is the position of a record in a sorted list: its sort values
+// and its ID
+type Cursor struct {
+	Values []interface{}
+	ID     int
+}
+
+type cursorJSON struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	ID     int           `json:"id"`
+}
+
+// sortKey renders a sort order as it appears in a query, e.g. "-order_date"
+func sortKey(sortFields []SortField) string {
+	parts := make([]string, len(sortFields))
+	for i, f := range sortFields {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursorFor returns the cursor positioned at record
+func cursorFor(res listResource, opts ListOptions, record interface{}) *Cursor {
+	sortFields := res.sortFields(opts)
+	c := &Cursor{Values: make([]interface{}, len(sortFields))}
+	for i, f := range sortFields {
+		c.Values[i] = fieldValue(record, f.Field)
+	}
+	c.ID, _ = fieldValue(record, "id").(int)
+	return c
+}
+
+// encodeCursor turns a cursor into the opaque token sent to clients
+func encodeCursor(res listResource, opts ListOptions, c *Cursor) string {
+	data, _ := json.Marshal(cursorJSON{Sort: sortKey(res.sortFields(opts)), Values: c.Values, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token from encodeCursor. The token must have been
+// issued for the same sort order.
+func decodeCursor(res listResource, opts ListOptions, token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cj cursorJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cj); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	sortFields := res.sortFields(opts)
+	if cj.Sort != sortKey(sortFields) || len(cj.Values) != len(sortFields) {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{Values: make([]interface{}, len(sortFields)), ID: cj.ID}
+	for i, sf := range sortFields {
+		f, ok := res.field(sf.Field)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		if c.Values[i], err = f.kind.fromJSON(cj.Values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// fromJSON converts a value decoded with json.Decoder.UseNumber to the Go
+// type of the field
+func (k fieldKind) fromJSON(v interface{}) (interface{}, error) {
+	switch k {
+	case intField:
+		if n, ok := v.(json.Number); ok {
+			i, err := n.Int64()
+			return int(i), err
+		}
+	case moneyField:
+		if n, ok := v.(json.Number); ok {
+			return ParseMoney(n.String())
+		}
+	case stringField:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case timeField:
+		if s, ok := v.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case boolField:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v", v)
+}
+
+// fieldValue returns the field of a model struct with the given JSON name.
+// Null IDs are returned as 0, like the COALESCE of their columns.
+func fieldValue(record interface{}, name string) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.SplitN(t.Field(i).Tag.Get("json"), ",", 2)[0] == name {
+			if id, ok := v.Field(i).Interface().(*int); ok {
+				if id == nil {
+					return 0
+				}
+				return *id
+			}
+			return v.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+// compareValues orders two values of the same field. Strings compare
+// case-insensitively, like the NOCASE and _ci collations of the schema.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case Money:
+		b := b.(Money)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case string:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b.(string)))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case bool:
+		if a != b.(bool) {
+			if a {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// compareRecords orders two records by sortFields and then by ID, the ID
+// following the direction of the last sort field
+func compareRecords(sortFields []SortField, a, b interface{}) int {
+	for _, f := range sortFields {
+		if c := compareValues(fieldValue(a, f.Field), fieldValue(b, f.Field)); c != 0 {
+			if f.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	c := compareValues(fieldValue(a, "id"), fieldValue(b, "id"))
+	if len(sortFields) > 0 && sortFields[len(sortFields)-1].Desc {
+		return -c
+	}
+	return c
+}
+
+// afterCursor reports whether record sorts after the cursor position
+func afterCursor(sortFields []SortField, record interface{}, c *Cursor) bool {
+	for i, f := range sortFields {
+		if cmp := compareValues(fieldValue(record, f.Field), c.Values[i]); cmp != 0 {
+			return (cmp > 0) != f.Desc
+		}
+	}
+	cmp := compareValues(fieldValue(record, "id"), c.ID)
+	if len(sortFields) > 0 && sortFields[len(sortFields)-1].Desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// pageRecords filters, sorts and pages records held in memory the same way
+// SQLStore pages a table
+func pageRecords[T any](res listResource, records []T, opts ListOptions) ([]T, PageInfo) {
+	if len(opts.Filters) > 0 {
+		matched := records[:0]
+		for i := range records {
+			if matchFilters(opts.Filters, &records[i]) {
+				matched = append(matched, records[i])
+			}
+		}
+		records = matched
+	}
+
+	sortFields := res.sortFields(opts)
+	sort.Slice(records, func(i, j int) bool {
+		return compareRecords(sortFields, &records[i], &records[j]) < 0
+	})
+	info := PageInfo{Total: len(records)}
+
+	if opts.After != nil {
+		start := sort.Search(len(records), func(i int) bool {
+			return afterCursor(sortFields, &records[i], opts.After)
+		})
+		records = records[start:]
+	}
+	if opts.Offset > 0 {
+		if opts.Offset >= len(records) {
+			return []T{}, info
+		}
+		records = records[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(records) > opts.Limit {
+		records = records[:opts.Limit]
+		info.HasMore = true
+	}
+	return records, info
+}
+
+// orderByClause renders the ORDER BY clause for sortFields
+func orderByClause(res listResource, sortFields []SortField) string {
+	parts := make([]string, 0, len(sortFields)+1)
+	idDir := "ASC"
+	for _, sf := range sortFields {
+		f, _ := res.field(sf.Field)
+		dir := "ASC"
+		if sf.Desc {
+			dir = "DESC"
+		}
+		parts = append(parts, f.column+" "+dir)
+		idDir = dir
+	}
+	parts = append(parts, "id "+idDir)
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// keysetCondition renders the WHERE condition selecting the records after
+// the cursor: (a > ?) OR (a = ? AND b > ?) OR ... OR (a = ? AND ... AND id > ?)
+func keysetCondition(res listResource, sortFields []SortField, c *Cursor, arg func(interface{}) interface{}) (string, []interface{}) {
+	var terms []string
+	var args []interface{}
+	var equal []string
+	var equalArgs []interface{}
+
+	op := func(desc bool) string {
+		if desc {
+			return "<"
+		}
+		return ">"
+	}
+	for i, sf := range sortFields {
+		f, _ := res.field(sf.Field)
+		term := append(append([]string{}, equal...), f.column+" "+op(sf.Desc)+" ?")
+		terms = append(terms, "("+strings.Join(term, " AND ")+")")
+		args = append(append(args, equalArgs...), arg(c.Values[i]))
+		equal = append(equal, f.column+" = ?")
+		equalArgs = append(equalArgs, arg(c.Values[i]))
+	}
+	idDesc := len(sortFields) > 0 && sortFields[len(sortFields)-1].Desc
+	term := append(equal, "id "+op(idDesc)+" ?")
+	terms = append(terms, "("+strings.Join(term, " AND ")+")")
+	args = append(append(args, equalArgs...), c.ID)
+
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
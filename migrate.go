@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockName is the MySQL named lock held while migrating so that
+// replicas starting at the same time do not run migrations concurrently
+const migrationLockName = "restaurant_api_schema_migrations"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Missing is set for versions recorded in the database that this
+	// binary does not know about, e.g. after a rollback to an older release
+	Missing bool
+}
+
+// Migrator applies the embedded migrations for the database's driver and
+// records them in the schema_migrations table
+type Migrator struct {
+	db          *Database
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// NewMigrator loads the migrations embedded for db.Driver
+func NewMigrator(db *Database) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", db.Driver))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, LockTimeout: time.Minute}, nil
+}
+
+// loadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %s in %s", entry.Name(), dir)
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the highest known migration version
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration, oldest first. Versions applied by a
+// newer release are left alone with a warning, so an older replica still
+// running during a rolling deploy does not undo them; rolling back takes
+// an explicit Down or To.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn sqlConn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, version := range sortedVersions(applied) {
+			if m.find(version) == nil {
+				log.Printf("Warning: migration %d is applied but not known to this binary, leaving it in place", version)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the n most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of migrations to roll back must be at least 1")
+	}
+	return m.withLock(ctx, func(conn sqlConn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := sortedVersions(applied)
+		if n > len(versions) {
+			n = len(versions)
+		}
+		for i := len(versions) - 1; i >= len(versions)-n; i-- {
+			if err := m.rollback(ctx, conn, versions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until exactly the migrations up to version are applied
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func(conn sqlConn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Roll back everything above the target, newest first
+		versions := sortedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > version; i-- {
+			if err := m.rollback(ctx, conn, versions[i]); err != nil {
+				return err
+			}
+		}
+
+		// Apply everything pending up to the target, oldest first
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn sqlConn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		for _, version := range sortedVersions(applied) {
+			if m.find(version) == nil {
+				appliedAt := applied[version]
+				statuses = append(statuses, MigrationStatus{Version: version, AppliedAt: &appliedAt, Missing: true})
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// sqlConn is the subset of *sql.Conn and *sql.Tx the migrator uses
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withLock runs fn while holding the migration lock. On MySQL fn runs on a
+// dedicated connection holding a named lock. On SQLite fn runs inside one
+// immediate transaction, which locks out other writers and makes the whole
+// run atomic since SQLite DDL is transactional.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn sqlConn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.db.Driver == "sqlite" {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := m.run(ctx, tx, fn); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLockName, int(m.LockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %v", err)
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("timed out after %s waiting for another instance to finish migrating", m.LockTimeout)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLockName)
+
+	return m.run(ctx, conn, fn)
+}
+
+// run makes sure schema_migrations exists, then calls fn
+func (m *Migrator) run(ctx context.Context, conn sqlConn, fn func(conn sqlConn) error) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	return fn(conn)
+}
+
+// applied returns the applied versions and when they were applied
+func (m *Migrator) applied(ctx context.Context, conn sqlConn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn sqlConn, migration Migration) error {
+	err := m.exec(ctx, conn, migration.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, migration.Version, migration.Name)
+	if err != nil {
+		return fmt.Errorf("error applying migration %04d_%s: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn sqlConn, version int) error {
+	migration := m.find(version)
+	if migration == nil {
+		return fmt.Errorf("cannot roll back migration %d: not known to this binary", version)
+	}
+	err := m.exec(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = ?`, version)
+	if err != nil {
+		return fmt.Errorf("error rolling back migration %04d_%s: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// exec runs a migration script followed by the bookkeeping statement.
+// SQLite runs the script as a whole. MySQL needs one statement per call and
+// commits DDL implicitly, so a version is only recorded once its script
+// succeeded.
+func (m *Migrator) exec(ctx context.Context, conn sqlConn, script, record string, args ...interface{}) error {
+	if m.db.Driver == "sqlite" {
+		if _, err := conn.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	_, err := conn.ExecContext(ctx, record, args...)
+	return err
+}
+
+// splitStatements splits a script on semicolons outside of quotes and
+// drops comment-only fragments. It does not understand stored program
+// bodies, which the MySQL migrations do not use.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	lines := strings.Split(script, "\n")
+	for _, line := range lines {
+		if quote == 0 && strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		for _, r := range line {
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"' || r == '`':
+				quote = r
+			case r == ';':
+				if stmt := strings.TrimSpace(current.String()); stmt != "" {
+					statements = append(statements, stmt)
+				}
+				current.Reset()
+				continue
+			}
+			current.WriteRune(r)
+		}
+		current.WriteByte('\n')
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+func sortedVersions(applied map[int]time.Time) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// runMigrateCommand implements `migrate up|down N|status|to VERSION`
+func runMigrateCommand(ctx context.Context, db *Database, args []string, out io.Writer) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	usage := errors.New("usage: migrate up | down N | status | to VERSION")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return usage
+		}
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) != 2 {
+			return usage
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usage
+		}
+		if err := migrator.Down(ctx, n); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 2 {
+			return usage
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usage
+		}
+		if err := migrator.To(ctx, version); err != nil {
+			return err
+		}
+	case "status":
+		if len(args) != 1 {
+			return usage
+		}
+	default:
+		return usage
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, s := range statuses {
+		state := "pending"
+		if s.Missing {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339) + " (unknown to this binary)"
+		} else if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, state)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// newMigrationDB opens an empty SQLite database
+func newMigrationDB(t *testing.T) *Database {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "migrate.db")
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db := &Database{DB: sqlDB, Driver: "sqlite"}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// appliedVersions returns the versions recorded in schema_migrations
+func appliedVersions(t *testing.T, m *Migrator) []int {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+// TestMigratorUpKeepsNewerVersions records a version a newer release
+// applied and checks that up applies the pending migrations around it
+// without rolling it back, while down still can
+func TestMigratorUpKeepsNewerVersions(t *testing.T) {
+	ctx := context.Background()
+	db := newMigrationDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrator.Latest()
+	if err := migrator.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, 9999, "from_a_newer_release"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("got %v, want the pending migrations applied", err)
+	}
+	versions := appliedVersions(t, migrator)
+	if len(versions) != latest+1 || versions[latest-1] != latest || versions[latest] != 9999 {
+		t.Errorf("got applied versions %v, want 1 to %d and 9999", versions, latest)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Errorf("again: got %v, want nothing to do", err)
+	}
+
+	// Rolling back stays explicit and refuses versions it cannot undo
+	if err := migrator.Down(ctx, 1); err == nil {
+		t.Error("rolling back 9999: got nil, want an error")
+	}
+	if err := migrator.To(ctx, latest-1); err == nil {
+		t.Error("migrating to an older version past 9999: got nil, want an error")
+	}
+}
+
+func TestMigratorDownAndTo(t *testing.T) {
+	ctx := context.Background()
+	migrator, err := NewMigrator(newMigrationDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrator.Latest()
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, migrator); len(got) != latest-1 {
+		t.Errorf("after down 1: got %v, want %d versions", got, latest-1)
+	}
+	if err := migrator.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, migrator); len(got) != 0 {
+		t.Errorf("after to 0: got %v, want none", got)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, migrator); len(got) != latest {
+		t.Errorf("after up: got %v, want %d versions", got, latest)
+	}
+}
@@ -0,0 +1,18 @@
+-- Order status history. Every status change writes one row in the same
+-- transaction as the change; the row for a new order has no from_status.
+
+CREATE TABLE order_status_events (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor VARCHAR(255),
+    reason VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    INDEX idx_order_status_events_order_id (order_id, id)
+);
+
+-- Existing orders only have their current status, recorded as of the order date
+INSERT INTO order_status_events (order_id, to_status, reason, created_at)
+SELECT id, status, 'Recorded when status history was introduced', order_date FROM orders;
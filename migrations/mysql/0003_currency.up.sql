@@ -0,0 +1,6 @@
+-- Amounts are in the restaurant's currency, an ISO 4217 code. Orders keep
+-- the currency they were placed in.
+
+ALTER TABLE restaurants ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER cuisine_type;
+
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER total_amount;
@@ -0,0 +1,18 @@
+-- Responses to requests sent with an Idempotency-Key header. A row without
+-- a status_code belongs to a request that is still running. The timestamps
+-- have explicit defaults so that servers without
+-- explicit_defaults_for_timestamp do not add ON UPDATE CURRENT_TIMESTAMP to
+-- created_at, which identifies the claim on a key.
+
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code SMALLINT,
+    content_type VARCHAR(255),
+    response_body MEDIUMBLOB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, idempotency_key),
+    INDEX idx_idempotency_keys_expires_at (expires_at)
+);
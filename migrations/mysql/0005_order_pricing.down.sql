@@ -0,0 +1,14 @@
+ALTER TABLE orders
+    DROP COLUMN delivery_distance_km,
+    DROP COLUMN tax_lines,
+    DROP COLUMN tax_inclusive,
+    DROP COLUMN tax_amount,
+    DROP COLUMN tip_amount,
+    DROP COLUMN delivery_fee,
+    DROP COLUMN service_charge,
+    DROP COLUMN discount_amount,
+    DROP COLUMN subtotal;
+
+DROP TABLE IF EXISTS delivery_fee_bands;
+DROP TABLE IF EXISTS restaurant_taxes;
+DROP TABLE IF EXISTS restaurant_pricing;
@@ -0,0 +1,44 @@
+-- Per-restaurant pricing: taxes, a service charge and delivery fees by
+-- distance band. Restaurants without a restaurant_pricing row charge menu
+-- prices only.
+
+CREATE TABLE restaurant_pricing (
+    restaurant_id INT PRIMARY KEY,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    service_charge_rate DECIMAL(6,3) NOT NULL DEFAULT 0,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE restaurant_taxes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    rate DECIMAL(6,3) NOT NULL,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_restaurant_taxes_name (restaurant_id, name)
+);
+
+CREATE TABLE delivery_fee_bands (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    max_distance_km DECIMAL(7,3) NOT NULL,
+    fee DECIMAL(10,2) NOT NULL,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_delivery_fee_bands_distance (restaurant_id, max_distance_km)
+);
+
+-- The price breakdown of each order, as priced when it was placed;
+-- total_amount stays the grand total
+ALTER TABLE orders
+    ADD COLUMN subtotal DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER restaurant_id,
+    ADD COLUMN discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER subtotal,
+    ADD COLUMN service_charge DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER discount_amount,
+    ADD COLUMN delivery_fee DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER service_charge,
+    ADD COLUMN tip_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER delivery_fee,
+    ADD COLUMN tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER tip_amount,
+    ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE AFTER tax_amount,
+    ADD COLUMN tax_lines JSON AFTER tax_inclusive,
+    ADD COLUMN delivery_distance_km DECIMAL(7,3) AFTER delivery_address;
+
+-- Existing orders were priced from their items only
+UPDATE orders SET subtotal = total_amount;
@@ -0,0 +1,45 @@
+-- Promotions and the orders that redeemed them. A promotion without a code
+-- applies automatically. Redemptions of cancelled orders are deleted, so
+-- promotion_redemptions only holds those that count against usage limits.
+
+CREATE TABLE promotions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(50) UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    restaurant_id INT,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    kind ENUM('percentage', 'fixed', 'buy_x_get_y') NOT NULL,
+    percent_off DECIMAL(6,3) NOT NULL DEFAULT 0,
+    amount_off DECIMAL(10,2) NOT NULL DEFAULT 0,
+    menu_item_id INT,
+    category VARCHAR(100),
+    buy_quantity INT NOT NULL DEFAULT 0,
+    get_quantity INT NOT NULL DEFAULT 0,
+    min_subtotal DECIMAL(10,2) NOT NULL DEFAULT 0,
+    max_redemptions INT NOT NULL DEFAULT 0,
+    max_redemptions_per_customer INT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP NULL,
+    ends_at TIMESTAMP NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    INDEX idx_promotions_restaurant_id (restaurant_id)
+);
+
+CREATE TABLE promotion_redemptions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    promotion_id INT NOT NULL,
+    order_id INT NOT NULL,
+    customer_id INT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE RESTRICT,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    INDEX idx_promotion_redemptions_customer (promotion_id, customer_id)
+);
+
+-- The discounts of each order as a JSON array, next to discount_amount
+ALTER TABLE orders ADD COLUMN discount_lines JSON AFTER discount_amount;
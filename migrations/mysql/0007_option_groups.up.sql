@@ -0,0 +1,37 @@
+-- Option groups of menu items (size, toppings, ...) and the options chosen
+-- for each order item. order_item_options copies the names and price of
+-- the chosen options, so orders keep them when the menu changes.
+
+CREATE TABLE option_groups (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    menu_item_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    min_selections INT NOT NULL DEFAULT 0,
+    max_selections INT NOT NULL DEFAULT 1,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_option_groups_name (menu_item_id, name),
+    CHECK (min_selections >= 0 AND max_selections >= 1 AND max_selections >= min_selections)
+);
+
+CREATE TABLE menu_options (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    option_group_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (option_group_id) REFERENCES option_groups(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_menu_options_name (option_group_id, name)
+);
+
+CREATE TABLE order_item_options (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_item_id INT NOT NULL,
+    option_id INT,
+    group_name VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES menu_options(id) ON DELETE SET NULL
+);
@@ -0,0 +1,33 @@
+-- Bundles are menu items of kind 'bundle', made of slots filled from sets
+-- of single items. An ordered bundle is stored as its own line plus one
+-- component line per slot, so kitchens and inventory see the real items.
+
+ALTER TABLE menu_items ADD COLUMN kind ENUM('item', 'bundle') NOT NULL DEFAULT 'item' AFTER category;
+
+CREATE TABLE bundle_slots (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    bundle_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    quantity INT NOT NULL DEFAULT 1,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (bundle_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_bundle_slots_name (bundle_id, name),
+    CHECK (quantity > 0)
+);
+
+CREATE TABLE bundle_slot_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    slot_id INT NOT NULL,
+    menu_item_id INT NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (slot_id) REFERENCES bundle_slots(id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE KEY uq_bundle_slot_items (slot_id, menu_item_id)
+);
+
+-- Component lines point at the bundle's line of the same order. Both go
+-- with the order, so bundle_item_id needs no foreign key.
+ALTER TABLE order_items
+    ADD COLUMN bundle_item_id INT NULL,
+    ADD COLUMN bundle_slot VARCHAR(100) NOT NULL DEFAULT '';
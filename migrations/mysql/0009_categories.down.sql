@@ -0,0 +1,5 @@
+ALTER TABLE menu_items
+    DROP FOREIGN KEY fk_menu_items_category,
+    DROP COLUMN category_id;
+
+DROP TABLE IF EXISTS categories;
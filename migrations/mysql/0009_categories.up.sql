@@ -0,0 +1,41 @@
+-- Per-restaurant menu categories with a display position, an optional
+-- parent and a visibility switch. Menu items reference them by ID and keep
+-- the category's name in menu_items.category, which the list filters and
+-- category promotions use.
+
+CREATE TABLE categories (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    parent_id INT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    position INT NOT NULL DEFAULT 0,
+    is_visible BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL,
+    UNIQUE KEY uq_categories_name (restaurant_id, name),
+    CHECK (position >= 0)
+);
+
+ALTER TABLE menu_items
+    ADD COLUMN category_id INT NULL AFTER category,
+    ADD CONSTRAINT fk_menu_items_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL;
+
+-- One category per distinct name of each restaurant, ignoring case and
+-- surrounding spaces, in the alphabetical order the menu was listed in
+INSERT INTO categories (restaurant_id, name, position)
+SELECT restaurant_id, name, ROW_NUMBER() OVER (PARTITION BY restaurant_id ORDER BY name) - 1
+FROM (
+    SELECT restaurant_id, MIN(TRIM(category)) AS name
+    FROM menu_items
+    WHERE TRIM(category) <> ''
+    GROUP BY restaurant_id, TRIM(category)
+) names;
+
+UPDATE menu_items m
+JOIN categories c ON c.restaurant_id = m.restaurant_id AND c.name = TRIM(m.category)
+SET m.category_id = c.id, m.category = c.name, m.updated_at = m.updated_at;
+
+UPDATE menu_items SET category = '', updated_at = updated_at WHERE category_id IS NULL AND category <> '';
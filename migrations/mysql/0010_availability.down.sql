@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS category_availability;
+DROP TABLE IF EXISTS menu_item_availability;
+
+ALTER TABLE restaurants DROP COLUMN time_zone;
@@ -0,0 +1,33 @@
+-- Menu schedules: restaurants read them in their time zone, and menu items
+-- and categories can only be ordered within their availability windows,
+-- if they have any. days is a JSON array of day names, empty for every
+-- day; times are HH:MM and an end at or before the start runs past
+-- midnight.
+
+ALTER TABLE restaurants ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC' AFTER currency;
+
+CREATE TABLE menu_item_availability (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    menu_item_id INT NOT NULL,
+    days JSON NOT NULL,
+    start_time CHAR(5) NOT NULL DEFAULT '00:00',
+    end_time CHAR(5) NOT NULL DEFAULT '00:00',
+    start_date DATE NULL,
+    end_date DATE NULL,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+
+CREATE TABLE category_availability (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    category_id INT NOT NULL,
+    days JSON NOT NULL,
+    start_time CHAR(5) NOT NULL DEFAULT '00:00',
+    end_time CHAR(5) NOT NULL DEFAULT '00:00',
+    start_date DATE NULL,
+    end_date DATE NULL,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+    CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
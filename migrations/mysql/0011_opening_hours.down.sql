@@ -0,0 +1,6 @@
+ALTER TABLE orders DROP COLUMN scheduled_for;
+
+DROP TABLE IF EXISTS restaurant_closures;
+DROP TABLE IF EXISTS opening_hours;
+
+ALTER TABLE restaurants DROP COLUMN ordering_paused;
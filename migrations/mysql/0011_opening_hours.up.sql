@@ -0,0 +1,29 @@
+-- Opening hours: restaurants take orders during their weekly shifts, read
+-- in their time zone, except on closure dates and while ordering is
+-- paused. day is mon..sun; times are HH:MM and a shift closing at or
+-- before it opens runs past midnight. Orders can be scheduled for a later
+-- time the restaurant is open.
+
+ALTER TABLE restaurants ADD COLUMN ordering_paused BOOLEAN NOT NULL DEFAULT FALSE AFTER time_zone;
+
+CREATE TABLE opening_hours (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    day ENUM('mon', 'tue', 'wed', 'thu', 'fri', 'sat', 'sun') NOT NULL,
+    opens_at CHAR(5) NOT NULL,
+    closes_at CHAR(5) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE restaurant_closures (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    restaurant_id INT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    CHECK (end_date >= start_date)
+);
+
+ALTER TABLE orders ADD COLUMN scheduled_for TIMESTAMP NULL AFTER notes;
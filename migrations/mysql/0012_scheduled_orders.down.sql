@@ -0,0 +1,8 @@
+UPDATE orders SET status = 'pending' WHERE status = 'scheduled';
+
+DROP INDEX idx_orders_release_at ON orders;
+ALTER TABLE orders DROP COLUMN release_at;
+
+ALTER TABLE orders MODIFY status ENUM('pending', 'confirmed', 'preparing', 'ready', 'delivered', 'cancelled') DEFAULT 'pending';
+
+ALTER TABLE restaurants DROP COLUMN prep_time_minutes;
@@ -0,0 +1,11 @@
+-- Scheduled orders: an order scheduled for later is held in the scheduled
+-- status until release_at, scheduled_for minus the restaurant's preparation
+-- time, when the scheduler moves it to pending.
+
+ALTER TABLE restaurants ADD COLUMN prep_time_minutes INT NOT NULL DEFAULT 20 AFTER ordering_paused;
+
+ALTER TABLE orders MODIFY status ENUM('scheduled', 'pending', 'confirmed', 'preparing', 'ready', 'delivered', 'cancelled') DEFAULT 'pending';
+
+ALTER TABLE orders ADD COLUMN release_at TIMESTAMP NULL AFTER scheduled_for;
+
+CREATE INDEX idx_orders_release_at ON orders(status, release_at);
@@ -0,0 +1,101 @@
+-- Initial schema, the SQLite version of migrations/mysql/0001.
+--
+-- Differences from MySQL:
+--   * AUTO_INCREMENT becomes INTEGER PRIMARY KEY AUTOINCREMENT so IDs are never reused
+--   * the status ENUM becomes a CHECK constraint
+--   * ON UPDATE CURRENT_TIMESTAMP is emulated with AFTER UPDATE triggers
+--   * DECIMAL(10,2) has no exact equivalent; amounts are rounded to cents by the API
+--   * text columns use NOCASE collation like MySQL's utf8mb4_unicode_ci
+--   * CURRENT_TIMESTAMP is stored in UTC
+
+CREATE TABLE IF NOT EXISTS restaurants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL COLLATE NOCASE,
+    address VARCHAR(500),
+    phone VARCHAR(20),
+    email VARCHAR(255) COLLATE NOCASE,
+    cuisine_type VARCHAR(100) COLLATE NOCASE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL COLLATE NOCASE,
+    email VARCHAR(255) UNIQUE COLLATE NOCASE,
+    phone VARCHAR(20),
+    address VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS menu_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL COLLATE NOCASE,
+    description TEXT,
+    price DECIMAL(10,2) NOT NULL,
+    category VARCHAR(100) COLLATE NOCASE,
+    is_available BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL,
+    restaurant_id INTEGER NOT NULL,
+    total_amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'preparing', 'ready', 'delivered', 'cancelled')),
+    order_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivery_address VARCHAR(500),
+    notes TEXT,
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE RESTRICT,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE RESTRICT
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    menu_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE RESTRICT
+);
+
+-- ON UPDATE CURRENT_TIMESTAMP: only bump updated_at when a column actually changed
+CREATE TRIGGER IF NOT EXISTS restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS customers_updated_at AFTER UPDATE ON customers
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.email IS NOT OLD.email OR NEW.phone IS NOT OLD.phone OR
+    NEW.address IS NOT OLD.address)
+BEGIN
+    UPDATE customers SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS menu_items_updated_at AFTER UPDATE ON menu_items
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.restaurant_id IS NOT OLD.restaurant_id OR NEW.name IS NOT OLD.name OR
+    NEW.description IS NOT OLD.description OR NEW.price IS NOT OLD.price OR
+    NEW.category IS NOT OLD.category OR NEW.is_available IS NOT OLD.is_available)
+BEGIN
+    UPDATE menu_items SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE INDEX IF NOT EXISTS idx_menu_items_restaurant_id ON menu_items(restaurant_id);
+CREATE INDEX IF NOT EXISTS idx_menu_items_category ON menu_items(category);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_restaurant_id ON orders(restaurant_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+CREATE INDEX IF NOT EXISTS idx_orders_date ON orders(order_date);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_menu_item_id ON order_items(menu_item_id);
@@ -0,0 +1,18 @@
+-- Order status history, the SQLite version of migrations/mysql/0002.
+
+CREATE TABLE order_status_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor VARCHAR(255),
+    reason VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_order_status_events_order_id ON order_status_events(order_id, id);
+
+-- Existing orders only have their current status, recorded as of the order date
+INSERT INTO order_status_events (order_id, to_status, reason, created_at)
+SELECT id, status, 'Recorded when status history was introduced', order_date FROM orders;
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE restaurants DROP COLUMN currency;
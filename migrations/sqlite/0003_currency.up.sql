@@ -0,0 +1,17 @@
+-- Amounts are in the restaurant's currency, an ISO 4217 code. Orders keep
+-- the currency they were placed in.
+
+ALTER TABLE restaurants ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Changing the currency bumps updated_at like the other columns
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,17 @@
+-- Responses to requests sent with an Idempotency-Key header. A row without
+-- a status_code belongs to a request that is still running. Keys are
+-- compared case-sensitively, so they keep the default BINARY collation.
+
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code SMALLINT,
+    content_type VARCHAR(255),
+    response_body BLOB,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
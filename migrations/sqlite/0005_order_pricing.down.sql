@@ -0,0 +1,13 @@
+ALTER TABLE orders DROP COLUMN delivery_distance_km;
+ALTER TABLE orders DROP COLUMN tax_lines;
+ALTER TABLE orders DROP COLUMN tax_inclusive;
+ALTER TABLE orders DROP COLUMN tax_amount;
+ALTER TABLE orders DROP COLUMN tip_amount;
+ALTER TABLE orders DROP COLUMN delivery_fee;
+ALTER TABLE orders DROP COLUMN service_charge;
+ALTER TABLE orders DROP COLUMN discount_amount;
+ALTER TABLE orders DROP COLUMN subtotal;
+
+DROP TABLE IF EXISTS delivery_fee_bands;
+DROP TABLE IF EXISTS restaurant_taxes;
+DROP TABLE IF EXISTS restaurant_pricing;
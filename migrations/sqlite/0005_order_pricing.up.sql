@@ -0,0 +1,43 @@
+-- Per-restaurant pricing: taxes, a service charge and delivery fees by
+-- distance band. Restaurants without a restaurant_pricing row charge menu
+-- prices only.
+
+CREATE TABLE restaurant_pricing (
+    restaurant_id INTEGER PRIMARY KEY,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    service_charge_rate DECIMAL(6,3) NOT NULL DEFAULT 0,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE restaurant_taxes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    name VARCHAR(50) NOT NULL COLLATE NOCASE,
+    rate DECIMAL(6,3) NOT NULL,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE (restaurant_id, name)
+);
+
+CREATE TABLE delivery_fee_bands (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    max_distance_km DECIMAL(7,3) NOT NULL,
+    fee DECIMAL(10,2) NOT NULL,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    UNIQUE (restaurant_id, max_distance_km)
+);
+
+-- The price breakdown of each order, as priced when it was placed;
+-- total_amount stays the grand total. tax_lines holds a JSON array.
+ALTER TABLE orders ADD COLUMN subtotal DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN service_charge DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN delivery_fee DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tip_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE orders ADD COLUMN tax_lines TEXT;
+ALTER TABLE orders ADD COLUMN delivery_distance_km DECIMAL(7,3);
+
+-- Existing orders were priced from their items only
+UPDATE orders SET subtotal = total_amount;
@@ -0,0 +1,4 @@
+ALTER TABLE orders DROP COLUMN discount_lines;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
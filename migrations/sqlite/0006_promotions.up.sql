@@ -0,0 +1,62 @@
+-- Promotions and the orders that redeemed them. A promotion without a code
+-- applies automatically. Redemptions of cancelled orders are deleted, so
+-- promotion_redemptions only holds those that count against usage limits.
+
+CREATE TABLE promotions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(50) UNIQUE COLLATE NOCASE,
+    name VARCHAR(255) NOT NULL COLLATE NOCASE,
+    description TEXT,
+    restaurant_id INTEGER,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('percentage', 'fixed', 'buy_x_get_y')),
+    percent_off DECIMAL(6,3) NOT NULL DEFAULT 0,
+    amount_off DECIMAL(10,2) NOT NULL DEFAULT 0,
+    menu_item_id INTEGER,
+    category VARCHAR(100) COLLATE NOCASE,
+    buy_quantity INTEGER NOT NULL DEFAULT 0,
+    get_quantity INTEGER NOT NULL DEFAULT 0,
+    min_subtotal DECIMAL(10,2) NOT NULL DEFAULT 0,
+    max_redemptions INTEGER NOT NULL DEFAULT 0,
+    max_redemptions_per_customer INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE
+);
+
+CREATE TABLE promotion_redemptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    promotion_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(id) ON DELETE RESTRICT,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_promotions_restaurant_id ON promotions(restaurant_id);
+CREATE INDEX idx_promotions_menu_item_id ON promotions(menu_item_id);
+CREATE INDEX idx_promotion_redemptions_customer ON promotion_redemptions(promotion_id, customer_id);
+CREATE INDEX idx_promotion_redemptions_order_id ON promotion_redemptions(order_id);
+
+CREATE TRIGGER promotions_updated_at AFTER UPDATE ON promotions
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.code IS NOT OLD.code OR NEW.name IS NOT OLD.name OR NEW.description IS NOT OLD.description OR
+    NEW.restaurant_id IS NOT OLD.restaurant_id OR NEW.currency IS NOT OLD.currency OR
+    NEW.kind IS NOT OLD.kind OR NEW.percent_off IS NOT OLD.percent_off OR NEW.amount_off IS NOT OLD.amount_off OR
+    NEW.menu_item_id IS NOT OLD.menu_item_id OR NEW.category IS NOT OLD.category OR
+    NEW.buy_quantity IS NOT OLD.buy_quantity OR NEW.get_quantity IS NOT OLD.get_quantity OR
+    NEW.min_subtotal IS NOT OLD.min_subtotal OR NEW.max_redemptions IS NOT OLD.max_redemptions OR
+    NEW.max_redemptions_per_customer IS NOT OLD.max_redemptions_per_customer OR
+    NEW.starts_at IS NOT OLD.starts_at OR NEW.ends_at IS NOT OLD.ends_at OR NEW.is_active IS NOT OLD.is_active)
+BEGIN
+    UPDATE promotions SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- The discounts of each order as a JSON array, next to discount_amount
+ALTER TABLE orders ADD COLUMN discount_lines TEXT;
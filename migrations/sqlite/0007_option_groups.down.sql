@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_item_options;
+DROP TABLE IF EXISTS menu_options;
+DROP TABLE IF EXISTS option_groups;
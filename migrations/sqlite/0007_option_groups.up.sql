@@ -0,0 +1,40 @@
+-- Option groups of menu items (size, toppings, ...) and the options chosen
+-- for each order item. order_item_options copies the names and price of
+-- the chosen options, so orders keep them when the menu changes.
+
+CREATE TABLE option_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    menu_item_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL COLLATE NOCASE,
+    min_selections INTEGER NOT NULL DEFAULT 0,
+    max_selections INTEGER NOT NULL DEFAULT 1,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE (menu_item_id, name),
+    CHECK (min_selections >= 0 AND max_selections >= 1 AND max_selections >= min_selections)
+);
+
+CREATE TABLE menu_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    option_group_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL COLLATE NOCASE,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (option_group_id) REFERENCES option_groups(id) ON DELETE CASCADE,
+    UNIQUE (option_group_id, name)
+);
+
+CREATE TABLE order_item_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_item_id INTEGER NOT NULL,
+    option_id INTEGER,
+    group_name VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES menu_options(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_order_item_options_order_item_id ON order_item_options(order_item_id);
+CREATE INDEX idx_order_item_options_option_id ON order_item_options(option_id);
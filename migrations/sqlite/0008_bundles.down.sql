@@ -0,0 +1,8 @@
+ALTER TABLE order_items DROP COLUMN bundle_slot;
+ALTER TABLE order_items DROP COLUMN bundle_item_id;
+
+DROP INDEX IF EXISTS idx_bundle_slot_items_menu_item_id;
+DROP TABLE IF EXISTS bundle_slot_items;
+DROP TABLE IF EXISTS bundle_slots;
+
+ALTER TABLE menu_items DROP COLUMN kind;
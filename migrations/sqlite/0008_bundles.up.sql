@@ -0,0 +1,33 @@
+-- Bundles are menu items of kind 'bundle', made of slots filled from sets
+-- of single items. An ordered bundle is stored as its own line plus one
+-- component line per slot, so kitchens and inventory see the real items.
+
+ALTER TABLE menu_items ADD COLUMN kind VARCHAR(10) NOT NULL DEFAULT 'item' CHECK (kind IN ('item', 'bundle'));
+
+CREATE TABLE bundle_slots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    bundle_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL COLLATE NOCASE,
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (bundle_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE (bundle_id, name)
+);
+
+CREATE TABLE bundle_slot_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slot_id INTEGER NOT NULL,
+    menu_item_id INTEGER NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (slot_id) REFERENCES bundle_slots(id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    UNIQUE (slot_id, menu_item_id)
+);
+
+CREATE INDEX idx_bundle_slot_items_menu_item_id ON bundle_slot_items(menu_item_id);
+
+-- Component lines point at the bundle's line of the same order. Both go
+-- with the order, so bundle_item_id needs no foreign key.
+ALTER TABLE order_items ADD COLUMN bundle_item_id INTEGER;
+ALTER TABLE order_items ADD COLUMN bundle_slot VARCHAR(100) NOT NULL DEFAULT '';
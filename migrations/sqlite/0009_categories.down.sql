@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_menu_items_category_id;
+ALTER TABLE menu_items DROP COLUMN category_id;
+
+DROP TRIGGER IF EXISTS categories_updated_at;
+DROP INDEX IF EXISTS idx_categories_parent_id;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,51 @@
+-- Per-restaurant menu categories with a display position, an optional
+-- parent and a visibility switch. Menu items reference them by ID and keep
+-- the category's name in menu_items.category, which the list filters and
+-- category promotions use.
+
+CREATE TABLE categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    name VARCHAR(100) NOT NULL COLLATE NOCASE,
+    description TEXT,
+    position INTEGER NOT NULL DEFAULT 0 CHECK (position >= 0),
+    is_visible BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL,
+    UNIQUE (restaurant_id, name)
+);
+
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+
+CREATE TRIGGER categories_updated_at AFTER UPDATE ON categories
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.restaurant_id IS NOT OLD.restaurant_id OR NEW.parent_id IS NOT OLD.parent_id OR
+    NEW.name IS NOT OLD.name OR NEW.description IS NOT OLD.description OR
+    NEW.position IS NOT OLD.position OR NEW.is_visible IS NOT OLD.is_visible)
+BEGIN
+    UPDATE categories SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE menu_items ADD COLUMN category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_menu_items_category_id ON menu_items(category_id);
+
+-- One category per distinct name of each restaurant, ignoring case and
+-- surrounding spaces, in the alphabetical order the menu was listed in
+INSERT INTO categories (restaurant_id, name, position)
+SELECT restaurant_id, name, ROW_NUMBER() OVER (PARTITION BY restaurant_id ORDER BY name COLLATE NOCASE) - 1
+FROM (
+    SELECT restaurant_id, MIN(TRIM(category)) AS name
+    FROM menu_items
+    WHERE TRIM(category) <> ''
+    GROUP BY restaurant_id, TRIM(category) COLLATE NOCASE
+);
+
+UPDATE menu_items SET category_id = (
+    SELECT c.id FROM categories c WHERE c.restaurant_id = menu_items.restaurant_id AND c.name = TRIM(menu_items.category)
+);
+
+UPDATE menu_items SET category = COALESCE((SELECT c.name FROM categories c WHERE c.id = menu_items.category_id), '');
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_category_availability_category_id;
+DROP TABLE IF EXISTS category_availability;
+DROP INDEX IF EXISTS idx_menu_item_availability_menu_item_id;
+DROP TABLE IF EXISTS menu_item_availability;
+
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE restaurants DROP COLUMN time_zone;
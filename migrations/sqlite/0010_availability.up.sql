@@ -0,0 +1,48 @@
+-- Menu schedules: restaurants read them in their time zone, and menu items
+-- and categories can only be ordered within their availability windows,
+-- if they have any. days is a JSON array of day names, empty for every
+-- day; times are HH:MM and an end at or before the start runs past
+-- midnight.
+
+ALTER TABLE restaurants ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Changing the time zone bumps updated_at like the other columns
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency OR NEW.time_zone IS NOT OLD.time_zone)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TABLE menu_item_availability (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    menu_item_id INTEGER NOT NULL,
+    days TEXT NOT NULL DEFAULT '[]',
+    start_time CHAR(5) NOT NULL DEFAULT '00:00',
+    end_time CHAR(5) NOT NULL DEFAULT '00:00',
+    start_date DATE,
+    end_date DATE,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items(id) ON DELETE CASCADE,
+    CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX idx_menu_item_availability_menu_item_id ON menu_item_availability(menu_item_id);
+
+CREATE TABLE category_availability (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category_id INTEGER NOT NULL,
+    days TEXT NOT NULL DEFAULT '[]',
+    start_time CHAR(5) NOT NULL DEFAULT '00:00',
+    end_time CHAR(5) NOT NULL DEFAULT '00:00',
+    start_date DATE,
+    end_date DATE,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+    CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX idx_category_availability_category_id ON category_availability(category_id);
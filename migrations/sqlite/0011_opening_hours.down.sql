@@ -0,0 +1,18 @@
+ALTER TABLE orders DROP COLUMN scheduled_for;
+
+DROP INDEX IF EXISTS idx_restaurant_closures_restaurant_id;
+DROP TABLE IF EXISTS restaurant_closures;
+DROP INDEX IF EXISTS idx_opening_hours_restaurant_id;
+DROP TABLE IF EXISTS opening_hours;
+
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency OR NEW.time_zone IS NOT OLD.time_zone)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE restaurants DROP COLUMN ordering_paused;
@@ -0,0 +1,45 @@
+-- Opening hours: restaurants take orders during their weekly shifts, read
+-- in their time zone, except on closure dates and while ordering is
+-- paused. day is mon..sun; times are HH:MM and a shift closing at or
+-- before it opens runs past midnight. Orders can be scheduled for a later
+-- time the restaurant is open.
+
+ALTER TABLE restaurants ADD COLUMN ordering_paused BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Pausing ordering bumps updated_at like the other columns
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency OR NEW.time_zone IS NOT OLD.time_zone OR
+    NEW.ordering_paused IS NOT OLD.ordering_paused)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TABLE opening_hours (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    day CHAR(3) NOT NULL CHECK (day IN ('mon', 'tue', 'wed', 'thu', 'fri', 'sat', 'sun')),
+    opens_at CHAR(5) NOT NULL,
+    closes_at CHAR(5) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_opening_hours_restaurant_id ON opening_hours(restaurant_id);
+
+CREATE TABLE restaurant_closures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    restaurant_id INTEGER NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants(id) ON DELETE CASCADE,
+    CHECK (end_date >= start_date)
+);
+
+CREATE INDEX idx_restaurant_closures_restaurant_id ON restaurant_closures(restaurant_id);
+
+ALTER TABLE orders ADD COLUMN scheduled_for TIMESTAMP;
@@ -0,0 +1,22 @@
+UPDATE orders SET status = 'pending' WHERE status = 'scheduled';
+
+DROP INDEX IF EXISTS idx_orders_release_at;
+ALTER TABLE orders DROP COLUMN release_at;
+
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema SET sql = replace(sql, 'CHECK (status IN (''scheduled'', ''pending'',', 'CHECK (status IN (''pending'',')
+WHERE type = 'table' AND name = 'orders';
+PRAGMA writable_schema = RESET;
+
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency OR NEW.time_zone IS NOT OLD.time_zone OR
+    NEW.ordering_paused IS NOT OLD.ordering_paused)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+ALTER TABLE restaurants DROP COLUMN prep_time_minutes;
@@ -0,0 +1,30 @@
+-- Scheduled orders: an order scheduled for later is held in the scheduled
+-- status until release_at, scheduled_for minus the restaurant's preparation
+-- time, when the scheduler moves it to pending.
+
+ALTER TABLE restaurants ADD COLUMN prep_time_minutes INTEGER NOT NULL DEFAULT 20;
+
+-- Changing the preparation time bumps updated_at like the other columns
+DROP TRIGGER IF EXISTS restaurants_updated_at;
+CREATE TRIGGER restaurants_updated_at AFTER UPDATE ON restaurants
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at AND (
+    NEW.name IS NOT OLD.name OR NEW.address IS NOT OLD.address OR NEW.phone IS NOT OLD.phone OR
+    NEW.email IS NOT OLD.email OR NEW.cuisine_type IS NOT OLD.cuisine_type OR
+    NEW.currency IS NOT OLD.currency OR NEW.time_zone IS NOT OLD.time_zone OR
+    NEW.ordering_paused IS NOT OLD.ordering_paused OR NEW.prep_time_minutes IS NOT OLD.prep_time_minutes)
+BEGIN
+    UPDATE restaurants SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- SQLite cannot alter a CHECK constraint and rebuilding orders would
+-- cascade to the rows referencing it, so the stored definition is edited
+-- in place. The ADD COLUMN below bumps the schema version, which makes
+-- every connection reload it.
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema SET sql = replace(sql, 'CHECK (status IN (''pending'',', 'CHECK (status IN (''scheduled'', ''pending'',')
+WHERE type = 'table' AND name = 'orders';
+PRAGMA writable_schema = RESET;
+
+ALTER TABLE orders ADD COLUMN release_at TIMESTAMP;
+
+CREATE INDEX idx_orders_release_at ON orders(status, release_at);
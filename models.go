@@ -1,32 +1,122 @@
 package main
 
-import (
-	"time"
-)
+import "time"
+
+// OrderStatuses lists the values allowed in orders.status
+var OrderStatuses = []string{"scheduled", "pending", "confirmed", "preparing", "ready", "delivered", "cancelled"}
+
+// isValidOrderStatus reports whether status is one of OrderStatuses
+func isValidOrderStatus(status string) bool {
+	for _, s := range OrderStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// orderTransitions is the order lifecycle: the statuses a status update can
+// move an order to from each status. Orders advance one step at a time and
+// can only be cancelled before the kitchen starts preparing them. Scheduled
+// orders can only be cancelled: they become pending when
+// ReleaseScheduledOrders releases them at their release time.
+var orderTransitions = map[string][]string{
+	"scheduled": {"cancelled"},
+	"pending":   {"confirmed", "cancelled"},
+	"confirmed": {"preparing", "cancelled"},
+	"preparing": {"ready"},
+	"ready":     {"delivered"},
+	"delivered": {},
+	"cancelled": {},
+}
+
+// nextOrderStatuses returns the statuses an order in status can move to
+func nextOrderStatuses(status string) []string {
+	return append([]string{}, orderTransitions[status]...)
+}
+
+// canTransitionOrder reports whether an order can move from one status to another
+func canTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// orderStatusPath returns the shortest series of transitions taking an
+// order from one status to another, excluding from, or nil if there is none
+func orderStatusPath(from, to string) []string {
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		status := queue[0]
+		queue = queue[1:]
+		if status == to {
+			var path []string
+			for ; status != from; status = previous[status] {
+				path = append([]string{status}, path...)
+			}
+			return path
+		}
+		for _, next := range orderTransitions[status] {
+			if _, ok := previous[next]; !ok {
+				previous[next] = status
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
 
 // Restaurant represents a restaurant entity
 type Restaurant struct {
-	ID          int       `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Address     string    `json:"address" db:"address"`
-	Phone       string    `json:"phone" db:"phone"`
-	Email       string    `json:"email" db:"email"`
-	CuisineType string    `json:"cuisine_type" db:"cuisine_type"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int    `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Address     string `json:"address" db:"address"`
+	Phone       string `json:"phone" db:"phone"`
+	Email       string `json:"email" db:"email"`
+	CuisineType string `json:"cuisine_type" db:"cuisine_type"`
+	Currency    string `json:"currency" db:"currency"`
+	// TimeZone is the IANA time zone the menu schedules and opening hours
+	// are read in
+	TimeZone string `json:"time_zone" db:"time_zone"`
+	// OrderingPaused stops orders for now whatever the opening hours
+	OrderingPaused bool `json:"ordering_paused" db:"ordering_paused"`
+	// PrepTimeMinutes is the lead time the kitchen needs: scheduled orders
+	// are released to it this long before they are due
+	PrepTimeMinutes int `json:"prep_time_minutes" db:"prep_time_minutes"`
+	// IsOpenNow and NextOpening are computed from the opening hours when the
+	// restaurant is read; NextOpening is null while it is open
+	IsOpenNow   bool       `json:"is_open_now"`
+	NextOpening *time.Time `json:"next_opening"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // MenuItem represents a menu item entity
 type MenuItem struct {
-	ID           int       `json:"id" db:"id"`
-	RestaurantID int       `json:"restaurant_id" db:"restaurant_id"`
-	Name         string    `json:"name" db:"name"`
-	Description  string    `json:"description" db:"description"`
-	Price        float64   `json:"price" db:"price"`
-	Category     string    `json:"category" db:"category"`
-	IsAvailable  bool      `json:"is_available" db:"is_available"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID           int    `json:"id" db:"id"`
+	RestaurantID int    `json:"restaurant_id" db:"restaurant_id"`
+	Name         string `json:"name" db:"name"`
+	Description  string `json:"description" db:"description"`
+	Price        Money  `json:"price" db:"price"`
+	// CategoryID is null for uncategorized items; Category is the name of
+	// the category, kept in step with it
+	CategoryID  *int      `json:"category_id" db:"category_id"`
+	Category    string    `json:"category" db:"category"`
+	Kind        string    `json:"kind" db:"kind"`
+	IsAvailable bool      `json:"is_available" db:"is_available"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// OptionGroups are the choices offered with the item, in display order
+	OptionGroups []OptionGroup `json:"option_groups,omitempty"`
+	// BundleSlots are the parts of a bundle, in display order
+	BundleSlots []BundleSlot `json:"bundle_slots,omitempty"`
+	// Availability lists the windows the item can be ordered in; it can
+	// be ordered at any time without them
+	Availability []AvailabilityWindow `json:"availability,omitempty"`
 }
 
 // Customer represents a customer entity
@@ -42,65 +132,165 @@ type Customer struct {
 
 // Order represents an order entity
 type Order struct {
-	ID              int         `json:"id" db:"id"`
-	CustomerID      int         `json:"customer_id" db:"customer_id"`
-	RestaurantID    int         `json:"restaurant_id" db:"restaurant_id"`
-	TotalAmount     float64     `json:"total_amount" db:"total_amount"`
-	Status          string      `json:"status" db:"status"`
-	OrderDate       time.Time   `json:"order_date" db:"order_date"`
-	DeliveryAddress string      `json:"delivery_address" db:"delivery_address"`
-	Notes           string      `json:"notes" db:"notes"`
-	Items           []OrderItem `json:"items,omitempty"`
+	ID              int       `json:"id" db:"id"`
+	CustomerID      int       `json:"customer_id" db:"customer_id"`
+	RestaurantID    int       `json:"restaurant_id" db:"restaurant_id"`
+	TotalAmount     Money     `json:"total_amount" db:"total_amount"`
+	Currency        string    `json:"currency" db:"currency"`
+	Status          string    `json:"status" db:"status"`
+	OrderDate       time.Time `json:"order_date" db:"order_date"`
+	DeliveryAddress string    `json:"delivery_address" db:"delivery_address"`
+	// DeliveryDistanceKm is the distance the delivery fee was charged for
+	DeliveryDistanceKm *float64 `json:"delivery_distance_km,omitempty" db:"delivery_distance_km"`
+	Notes              string   `json:"notes" db:"notes"`
+	// ScheduledFor is the time the order was placed for, if not as soon
+	// as possible. It waits in the scheduled status until ReleaseAt, when
+	// it goes to the kitchen as a pending order.
+	ScheduledFor *time.Time `json:"scheduled_for" db:"scheduled_for"`
+	ReleaseAt    *time.Time `json:"release_at" db:"release_at"`
+	// Pricing is the breakdown of TotalAmount as priced when the order was
+	// placed
+	Pricing    PriceBreakdown `json:"pricing"`
+	Items      []OrderItem    `json:"items,omitempty"`
+	Customer   *Customer      `json:"customer,omitempty"`
+	Restaurant *Restaurant    `json:"restaurant,omitempty"`
+}
+
+// OrderTransitions lists the statuses an order can move to next
+type OrderTransitions struct {
+	OrderID     int      `json:"order_id"`
+	Status      string   `json:"status"`
+	Transitions []string `json:"transitions"`
 }
 
 // OrderItem represents an order item entity
 type OrderItem struct {
-	ID         int       `json:"id" db:"id"`
-	OrderID    int       `json:"order_id" db:"order_id"`
-	MenuItemID int       `json:"menu_item_id" db:"menu_item_id"`
-	Quantity   int       `json:"quantity" db:"quantity"`
-	UnitPrice  float64   `json:"unit_price" db:"unit_price"`
-	MenuItem   *MenuItem `json:"menu_item,omitempty"`
+	ID         int `json:"id" db:"id"`
+	OrderID    int `json:"order_id" db:"order_id"`
+	MenuItemID int `json:"menu_item_id" db:"menu_item_id"`
+	Quantity   int `json:"quantity" db:"quantity"`
+	// UnitPrice includes the price deltas of the chosen options and bundle
+	// choices
+	UnitPrice Money             `json:"unit_price" db:"unit_price"`
+	Options   []OrderItemOption `json:"options,omitempty"`
+	// BundleItemID is set on the component lines of a bundle and points at
+	// the bundle's line, which carries the price; BundleSlot names the slot
+	// the component fills
+	BundleItemID *int      `json:"bundle_item_id,omitempty" db:"bundle_item_id"`
+	BundleSlot   string    `json:"bundle_slot,omitempty" db:"bundle_slot"`
+	MenuItem     *MenuItem `json:"menu_item,omitempty"`
 }
 
 // CreateRestaurantRequest for creating restaurants
 type CreateRestaurantRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Address     string `json:"address"`
-	Phone       string `json:"phone"`
-	Email       string `json:"email"`
-	CuisineType string `json:"cuisine_type"`
+	Name        string `json:"name" validate:"required,max=255"`
+	Address     string `json:"address" validate:"max=500"`
+	Phone       string `json:"phone" validate:"max=20"`
+	Email       string `json:"email" validate:"omitempty,email,max=255"`
+	CuisineType string `json:"cuisine_type" validate:"max=100"`
+	// Currency is an ISO 4217 code; restaurants default to USD and keep
+	// their currency when it is left out of an update
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+	// TimeZone is an IANA name such as Europe/Paris; restaurants default
+	// to UTC and keep their time zone when it is left out of an update
+	TimeZone string `json:"time_zone" validate:"omitempty,timezone,max=64"`
+	// PrepTimeMinutes defaults to DefaultPrepTimeMinutes and is kept when
+	// it is left out of an update
+	PrepTimeMinutes *int `json:"prep_time_minutes" validate:"omitempty,gte=0,lte=1440"`
 }
 
 // CreateMenuItemRequest for creating menu items
 type CreateMenuItemRequest struct {
-	RestaurantID int     `json:"restaurant_id" validate:"required"`
-	Name         string  `json:"name" validate:"required"`
-	Description  string  `json:"description"`
-	Price        float64 `json:"price" validate:"required,gt=0"`
-	Category     string  `json:"category"`
-	IsAvailable  bool    `json:"is_available"`
+	RestaurantID int    `json:"restaurant_id" validate:"required,gt=0"`
+	Name         string `json:"name" validate:"required,max=255"`
+	Description  string `json:"description"`
+	Price        Money  `json:"price" validate:"required,gt=0,max=99999999.99"`
+	CategoryID   *int   `json:"category_id" validate:"omitempty,gt=0"`
+	// Category is used without a category ID: it names a category of the
+	// restaurant, ignoring case, which is created if there is none
+	Category string `json:"category" validate:"max=100"`
+	// Kind defaults to item; it is set when the menu item is created and
+	// kept by updates
+	Kind        string `json:"kind" validate:"omitempty,oneof=item bundle"`
+	IsAvailable bool   `json:"is_available"`
 }
 
 // CreateCustomerRequest for creating customers
 type CreateCustomerRequest struct {
-	Name    string `json:"name" validate:"required"`
-	Email   string `json:"email" validate:"required,email"`
-	Phone   string `json:"phone"`
-	Address string `json:"address"`
+	Name    string `json:"name" validate:"required,max=255"`
+	Email   string `json:"email" validate:"required,email,max=255"`
+	Phone   string `json:"phone" validate:"max=20"`
+	Address string `json:"address" validate:"max=500"`
 }
 
 // CreateOrderRequest for creating orders
 type CreateOrderRequest struct {
-	CustomerID      int                      `json:"customer_id" validate:"required"`
-	RestaurantID    int                      `json:"restaurant_id" validate:"required"`
-	DeliveryAddress string                   `json:"delivery_address"`
+	CustomerID      int                      `json:"customer_id" validate:"required,gt=0"`
+	RestaurantID    int                      `json:"restaurant_id" validate:"required,gt=0"`
+	DeliveryAddress string                   `json:"delivery_address" validate:"max=500"`
 	Notes           string                   `json:"notes"`
-	Items           []CreateOrderItemRequest `json:"items" validate:"required,min=1"`
+	Items           []CreateOrderItemRequest `json:"items" validate:"required,min=1,dive"`
+	// DeliveryDistanceKm selects the delivery fee band; it is required for
+	// deliveries from restaurants that charge delivery fees
+	DeliveryDistanceKm *float64 `json:"delivery_distance_km" validate:"omitempty,gte=0,lte=1000"`
+	Tip                Money    `json:"tip" validate:"gte=0,max=99999999.99"`
+	// PromotionCode applies the promotion with that code on top of the
+	// automatic ones
+	PromotionCode string `json:"promotion_code" validate:"max=50"`
+	// ScheduledFor places the order for a later time at which the
+	// restaurant is open instead of now
+	ScheduledFor *time.Time `json:"scheduled_for"`
+}
+
+// QuoteOrderRequest for pricing a cart without placing an order
+type QuoteOrderRequest struct {
+	CustomerID         int                      `json:"customer_id" validate:"omitempty,gt=0"`
+	RestaurantID       int                      `json:"restaurant_id" validate:"required,gt=0"`
+	DeliveryAddress    string                   `json:"delivery_address" validate:"max=500"`
+	Items              []CreateOrderItemRequest `json:"items" validate:"required,min=1,dive"`
+	DeliveryDistanceKm *float64                 `json:"delivery_distance_km" validate:"omitempty,gte=0,lte=1000"`
+	Tip                Money                    `json:"tip" validate:"gte=0,max=99999999.99"`
+	PromotionCode      string                   `json:"promotion_code" validate:"max=50"`
+	ScheduledFor       *time.Time               `json:"scheduled_for"`
+}
+
+// order returns the order the quote is for
+func (q QuoteOrderRequest) order() CreateOrderRequest {
+	return CreateOrderRequest{
+		CustomerID:         q.CustomerID,
+		RestaurantID:       q.RestaurantID,
+		DeliveryAddress:    q.DeliveryAddress,
+		Items:              q.Items,
+		DeliveryDistanceKm: q.DeliveryDistanceKm,
+		Tip:                q.Tip,
+		PromotionCode:      q.PromotionCode,
+		ScheduledFor:       q.ScheduledFor,
+	}
+}
+
+// RestaurantPricingRequest for setting the pricing of a restaurant
+type RestaurantPricingRequest struct {
+	PricesIncludeTax  bool              `json:"prices_include_tax"`
+	Taxes             []TaxRate         `json:"taxes" validate:"max=5,unique=Name,dive"`
+	ServiceChargeRate Percent           `json:"service_charge_rate" validate:"gte=0,lte=100"`
+	DeliveryFeeBands  []DeliveryFeeBand `json:"delivery_fee_bands" validate:"max=20,unique=MaxDistanceKm,dive"`
 }
 
 // CreateOrderItemRequest for creating order items
 type CreateOrderItemRequest struct {
-	MenuItemID int `json:"menu_item_id" validate:"required"`
-	Quantity   int `json:"quantity" validate:"required,gt=0"`
+	MenuItemID int `json:"menu_item_id" validate:"required,gt=0"`
+	Quantity   int `json:"quantity" validate:"required,gt=0,max=1000"`
+	// OptionIDs are the options chosen from the item's option groups
+	OptionIDs []int `json:"option_ids" validate:"max=50,unique,dive,gt=0"`
+	// BundleChoices fill the slots of a bundle; slots with a single item
+	// may be left out
+	BundleChoices []BundleChoice `json:"bundle_choices" validate:"max=10,dive"`
+}
+
+// UpdateOrderStatusRequest for changing the status of an order
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" validate:"required,order_status"`
+	// Actor and Reason are recorded in the status history
+	Actor  string `json:"actor" validate:"max=255"`
+	Reason string `json:"reason" validate:"max=500"`
 }
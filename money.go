@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Money is an exact amount in minor units, e.g. cents. Amounts always have
+// two decimals, like the DECIMAL(10,2) columns they are stored in; the
+// currency is set per restaurant and copied to its orders.
+type Money int64
+
+// MaxMoney is the largest amount a DECIMAL(10,2) column holds
+const MaxMoney Money = 9999999999
+
+// DefaultCurrency is used for restaurants created without a currency
+const DefaultCurrency = "USD"
+
+// ParseMoney parses a decimal amount such as "12.5" or "-0.99" exactly.
+// Digits beyond the second decimal must be zeros; amounts are never rounded.
+func ParseMoney(s string) (Money, error) {
+	n, err := parseDecimal(s, 2)
+	return Money(n), err
+}
+
+// parseDecimal parses a decimal number into an integer count of 10^-places
+// units, e.g. "1.5" with 2 places is 150. Digits beyond places must be zeros.
+func parseDecimal(s string, places int) (int64, error) {
+	invalid := fmt.Errorf("invalid amount %q", s)
+	text := strings.TrimSpace(s)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	units, fraction, _ := strings.Cut(text, ".")
+	if units == "" || strings.Trim(units, "0123456789") != "" || strings.Trim(fraction, "0123456789") != "" {
+		return 0, invalid
+	}
+	if len(fraction) > places {
+		if strings.Trim(fraction[places:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount %q: at most %d decimals are allowed", s, places)
+		}
+		fraction = fraction[:places]
+	}
+	fraction += strings.Repeat("0", places-len(fraction))
+
+	scale := int64(math.Pow10(places))
+	n, err := strconv.ParseInt(units, 10, 64)
+	if err != nil || n > math.MaxInt64/scale-1 {
+		return 0, invalid
+	}
+	var frac int64
+	if fraction != "" {
+		frac, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+	n = n*scale + frac
+	if negative {
+		n = -n
+	}
+	return n, nil
+}
+
+// formatDecimal formats a count of 10^-places units with places decimals
+func formatDecimal(n int64, places int) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	scale := int64(math.Pow10(places))
+	return fmt.Sprintf("%s%d.%0*d", sign, n/scale, places, n%scale)
+}
+
+// unmarshalDecimal reads a JSON number, or a string holding one, exactly
+func unmarshalDecimal(data []byte, places int) (int64, error) {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	return parseDecimal(text, places)
+}
+
+// scanDecimal reads a DECIMAL column. MySQL returns them as text; SQLite
+// stores them as INTEGER or REAL, which is exact to the last decimal for
+// the column sizes used in the schema.
+func scanDecimal(src interface{}, places int) (int64, error) {
+	switch v := src.(type) {
+	case []byte:
+		return parseDecimal(string(v), places)
+	case string:
+		return parseDecimal(v, places)
+	case int64:
+		return v * int64(math.Pow10(places)), nil
+	case float64:
+		return int64(math.Round(v * math.Pow10(places))), nil
+	default:
+		return 0, fmt.Errorf("cannot scan %T into a decimal", src)
+	}
+}
+
+// Mul returns the amount multiplied by a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// String formats the amount with two decimals, e.g. "12.50"
+func (m Money) String() string {
+	return formatDecimal(int64(m), 2)
+}
+
+// Float64 returns the amount in major units. It is only meant for
+// validation rules; arithmetic is done on Money.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// MarshalJSON encodes the amount as a fixed-point number, e.g. 12.50
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one, reading the
+// decimal text exactly instead of going through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	n, err := unmarshalDecimal(data, 2)
+	if err != nil {
+		return err
+	}
+	*m = Money(n)
+	return nil
+}
+
+// UnmarshalYAML reads amounts in fixtures exactly
+func (m *Money) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseMoney(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (m *Money) Scan(src interface{}) error {
+	n, err := scanDecimal(src, 2)
+	if err != nil {
+		return err
+	}
+	*m = Money(n)
+	return nil
+}
+
+// Value implements driver.Valuer, sending the exact decimal text
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// amount is a Money value that fits in a DECIMAL(10,2) column
+type amount Money
+
+func (amount) Generate(rng *rand.Rand, size int) reflect.Value {
+	m := Money(rng.Int63n(int64(MaxMoney) + 1))
+	if rng.Intn(2) == 0 {
+		m = -m
+	}
+	return reflect.ValueOf(amount(m))
+}
+
+func TestMoneyStringRoundTrip(t *testing.T) {
+	f := func(a amount) bool {
+		parsed, err := ParseMoney(Money(a).String())
+		return err == nil && parsed == Money(a)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	f := func(a amount) bool {
+		data, err := json.Marshal(Money(a))
+		if err != nil {
+			return false
+		}
+		var fromNumber, fromString Money
+		if json.Unmarshal(data, &fromNumber) != nil || json.Unmarshal([]byte(`"`+string(data)+`"`), &fromString) != nil {
+			return false
+		}
+		return fromNumber == Money(a) && fromString == Money(a)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestMoneyScanFloat checks that amounts SQLite returns as REAL scan back
+// to the exact cents
+func TestMoneyScanFloat(t *testing.T) {
+	f := func(a amount) bool {
+		var m Money
+		return m.Scan(Money(a).Float64()) == nil && m == Money(a)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	valid := map[string]Money{"12": 1200, "12.5": 1250, "0.10": 10, "-0.99": -99, "3.1400": 314, " 7.00 ": 700}
+	for text, want := range valid {
+		if got, err := ParseMoney(text); err != nil || got != want {
+			t.Errorf("ParseMoney(%q) = %v, %v; want %v", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "-", ".5", "1.005", "1e3", "12,50", "abc", "99999999999999999999"} {
+		if _, err := ParseMoney(text); err == nil {
+			t.Errorf("ParseMoney(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+// orderLines is a random basket of up to ten lines over the menu items of
+// a restaurant, given as indexes into its menu
+type orderLines []struct{ Item, Quantity int }
+
+func (orderLines) Generate(rng *rand.Rand, size int) reflect.Value {
+	lines := make(orderLines, rng.Intn(10)+1)
+	for i := range lines {
+		lines[i].Item = rng.Intn(1 << 16)
+		lines[i].Quantity = rng.Intn(1000) + 1
+	}
+	return reflect.ValueOf(lines)
+}
+
+// checkOrderTotals places random orders on store and checks that every
+// subtotal is exactly the sum of its lines, priced from the menu, and that
+// the discount and the total add up from the breakdown
+func checkOrderTotals(t *testing.T, store Store, count int, pricesIncludeTax bool) {
+	ctx := context.Background()
+	restaurant, err := store.CreateRestaurant(ctx, CreateRestaurantRequest{Name: "Totals", Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UpdateRestaurantPricing(ctx, restaurant.ID, RestaurantPricingRequest{
+		PricesIncludeTax:  pricesIncludeTax,
+		Taxes:             []TaxRate{{Name: "State", Rate: 6250}, {Name: "City", Rate: 2625}},
+		ServiceChargeRate: 12500,
+		DeliveryFeeBands:  []DeliveryFeeBand{{MaxDistanceKm: 5, Fee: 299}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer, err := store.CreateCustomer(ctx, CreateCustomerRequest{Name: "Totals", Email: "totals@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Prices whose cents floats cannot represent exactly
+	var menu []MenuItem
+	for _, price := range []Money{1, 10, 33, 99, 105, 1999, 33333, 100000} {
+		item, err := store.CreateMenuItem(ctx, CreateMenuItemRequest{RestaurantID: restaurant.ID, Name: price.String(), Price: price, IsAvailable: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		menu = append(menu, *item)
+	}
+	// An automatic promotion on one of the items
+	_, err = store.CreatePromotion(ctx, PromotionRequest{
+		Name: "Totals", RestaurantID: &restaurant.ID, Kind: "percentage", PercentOff: 12345,
+		MenuItemID: &menu[4].ID, MinSubtotal: 5000, IsActive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := func(lines orderLines) bool {
+		distance := float64(len(lines) % 5)
+		req := CreateOrderRequest{CustomerID: customer.ID, RestaurantID: restaurant.ID, DeliveryDistanceKm: &distance, Tip: Money(len(lines) * 50)}
+		var want Money
+		for _, line := range lines {
+			item := menu[line.Item%len(menu)]
+			req.Items = append(req.Items, CreateOrderItemRequest{MenuItemID: item.ID, Quantity: line.Quantity})
+			want += item.Price.Mul(line.Quantity)
+		}
+		placed, err := store.CreateOrder(ctx, req)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		order, err := store.GetOrder(ctx, placed.ID)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+
+		var sum Money
+		for i, item := range order.Items {
+			if item.UnitPrice != menu[lines[i].Item%len(menu)].Price {
+				return false
+			}
+			sum += item.UnitPrice.Mul(item.Quantity)
+		}
+		p := order.Pricing
+		var taxes, discounts Money
+		for _, line := range p.Taxes {
+			taxes += line.Amount
+		}
+		for _, line := range p.Discounts {
+			discounts += line.Amount
+		}
+		total := p.Subtotal - p.Discount + p.ServiceCharge + p.DeliveryFee + p.Tip
+		if !p.TaxInclusive {
+			total += p.TaxTotal
+		}
+		return p.Subtotal == want && sum == want && taxes == p.TaxTotal && discounts == p.Discount && p.Total == total &&
+			order.TotalAmount == total && placed.TotalAmount == total && p.DeliveryFee == 299 &&
+			p.TaxInclusive == pricesIncludeTax && order.Currency == "EUR"
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: count}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOrderTotalsMemory(t *testing.T) {
+	for _, inclusive := range []bool{false, true} {
+		checkOrderTotals(t, NewMemoryStore(), 500, inclusive)
+	}
+}
+
+func TestOrderTotalsSQLite(t *testing.T) {
+	for _, inclusive := range []bool{false, true} {
+		checkOrderTotals(t, newCountingStore(t, 0), 100, inclusive)
+	}
+}
+
+func TestPriceOrderTaxes(t *testing.T) {
+	menu := map[int]MenuItem{1: {ID: 1, RestaurantID: 1, Price: 2598, IsAvailable: true}}
+	req := CreateOrderRequest{RestaurantID: 1, Items: []CreateOrderItemRequest{{MenuItemID: 1, Quantity: 1}}}
+	pricing := RestaurantPricing{RestaurantID: 1, Taxes: []TaxRate{{Name: "VAT", Rate: 20000}}}
+
+	// Exclusive: 20% of 25.98 is 5.196, added on top
+	_, b, _ := priceOrder(req, menu, pricing, orderPromotions{})
+	if b.TaxTotal != 520 || b.Total != 3118 {
+		t.Errorf("exclusive: got tax %s, total %s; want 5.20, 31.18", b.TaxTotal, b.Total)
+	}
+
+	// Inclusive: 25.98 × 20/120 is 4.33, already in the price
+	pricing.PricesIncludeTax = true
+	_, b, _ = priceOrder(req, menu, pricing, orderPromotions{})
+	if b.TaxTotal != 433 || b.Total != 2598 {
+		t.Errorf("inclusive: got tax %s, total %s; want 4.33, 25.98", b.TaxTotal, b.Total)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPrepTimeMinutes is the preparation time of restaurants created
+// without one
+const DefaultPrepTimeMinutes = 20
+
+// OpeningHours is when a restaurant takes orders: weekly shifts in its time
+// zone, minus the dates it is closed. A restaurant without shifts is open at
+// all times outside its closures.
+type OpeningHours struct {
+	RestaurantID int            `json:"restaurant_id"`
+	TimeZone     string         `json:"time_zone"`
+	Shifts       []OpeningShift `json:"shifts"`
+	Closures     []Closure      `json:"closures"`
+	// OrderingPaused stops orders for now without changing the hours; it
+	// is set with PUT /restaurants/:id/ordering
+	OrderingPaused bool `json:"ordering_paused"`
+	// PrepTimeMinutes is the lead time of scheduled orders
+	PrepTimeMinutes int `json:"prep_time_minutes"`
+}
+
+// OpeningShift is a period a restaurant is open every week on Day. A shift
+// closing at or before it opens runs past midnight, so equal times cover
+// the whole day; a day can have several shifts.
+type OpeningShift struct {
+	Day      string `json:"day" db:"day" validate:"required,oneof=mon tue wed thu fri sat sun"`
+	OpensAt  string `json:"opens_at" db:"opens_at" validate:"required,datetime=15:04"`
+	ClosesAt string `json:"closes_at" db:"closes_at" validate:"required,datetime=15:04"`
+}
+
+// Closure closes a restaurant from StartDate to EndDate inclusive, e.g. for
+// a holiday, whatever its shifts
+type Closure struct {
+	StartDate string `json:"start_date" db:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" db:"end_date" validate:"required,datetime=2006-01-02"`
+	Reason    string `json:"reason" db:"reason" validate:"max=255"`
+}
+
+// window returns the shift as an availability window, which has the same
+// rules for times
+func (s OpeningShift) window() AvailabilityWindow {
+	return AvailabilityWindow{Days: Weekdays{s.Day}, StartTime: s.OpensAt, EndTime: s.ClosesAt}
+}
+
+// closure returns the closure covering the date of t, or nil
+func (h OpeningHours) closure(t time.Time) *Closure {
+	date := t.Format("2006-01-02")
+	for i, c := range h.Closures {
+		if c.StartDate <= date && date <= c.EndDate {
+			return &h.Closures[i]
+		}
+	}
+	return nil
+}
+
+// openAt reports whether the restaurant is open at t, which is in its time
+// zone. Closures are whole local dates and also close the hours of a shift
+// that started the day before.
+func (h OpeningHours) openAt(t time.Time) bool {
+	if h.closure(t) != nil {
+		return false
+	}
+	for _, s := range h.Shifts {
+		if s.window().open(t) {
+			return true
+		}
+	}
+	return len(h.Shifts) == 0
+}
+
+// nextOpening returns the first time after t, which is in the restaurant's
+// time zone, at which the restaurant is open. It looks a year ahead and
+// reports false if the restaurant does not open in that time.
+func (h OpeningHours) nextOpening(t time.Time) (time.Time, bool) {
+	// Opening only happens at midnight, when a closure ends, or when a
+	// shift opens
+	for i := 0; i <= 366; i++ {
+		date := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, t.Location())
+		candidates := []time.Time{date}
+		for _, s := range h.Shifts {
+			if s.window().Days.has(date.Weekday()) {
+				start, _ := s.window().minutes()
+				candidates = append(candidates, time.Date(date.Year(), date.Month(), date.Day(), start/60, start%60, 0, 0, t.Location()))
+			}
+		}
+		sort.Slice(candidates, func(a, b int) bool { return candidates[a].Before(candidates[b]) })
+		for _, c := range candidates {
+			if c.After(t) && h.openAt(c) {
+				return c, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// location returns the location of the restaurant's time zone
+func (h OpeningHours) location() *time.Location {
+	return restaurantLocation(h.TimeZone)
+}
+
+// describe says when the restaurant is closed at t, which is in its time
+// zone, and when it opens next, for error messages
+func (h OpeningHours) describe(t time.Time) string {
+	message := fmt.Sprintf("restaurant %d is closed on %s", h.RestaurantID, t.Format("Mon 2006-01-02 15:04 MST"))
+	if c := h.closure(t); c != nil && c.Reason != "" {
+		message += " (" + c.Reason + ")"
+	}
+	if next, ok := h.nextOpening(t); ok {
+		return message + "; it opens next on " + next.Format("Mon 2006-01-02 15:04 MST")
+	}
+	return message + " and does not open in the coming year"
+}
+
+// prepTime returns the lead time of scheduled orders
+func (h OpeningHours) prepTime() time.Duration {
+	return time.Duration(h.PrepTimeMinutes) * time.Minute
+}
+
+// orderProblems returns the problems with the time of an order placed at
+// now, for scheduledFor if it is set. An order for now needs the restaurant
+// open and its ordering not paused; a scheduled order needs a time at least
+// the preparation time away at which the restaurant is open, and is taken
+// even while ordering is paused.
+func (h OpeningHours) orderProblems(scheduledFor *time.Time, now time.Time) []FieldError {
+	if scheduledFor != nil {
+		at := scheduledFor.In(h.location())
+		switch {
+		case !at.After(now):
+			return []FieldError{{Field: "scheduled_for", Rule: "future", Message: "scheduled_for must be in the future"}}
+		case at.Before(now.Add(h.prepTime())):
+			message := fmt.Sprintf("scheduled_for must be at least %d minutes from now, the preparation time of restaurant %d", h.PrepTimeMinutes, h.RestaurantID)
+			return []FieldError{{Field: "scheduled_for", Rule: "lead_time", Message: message}}
+		case !h.openAt(at):
+			return []FieldError{{Field: "scheduled_for", Rule: "closed", Message: "scheduled_for is not within the opening hours: " + h.describe(at)}}
+		}
+		return nil
+	}
+	now = now.In(h.location())
+	switch {
+	case h.OrderingPaused:
+		return []FieldError{{Field: "restaurant_id", Rule: "paused", Message: fmt.Sprintf("restaurant %d has paused ordering; orders can still be scheduled for later", h.RestaurantID)}}
+	case !h.openAt(now):
+		return []FieldError{{Field: "restaurant_id", Rule: "closed", Message: h.describe(now) + "; schedule the order for a time it is open"}}
+	}
+	return nil
+}
+
+// OpeningHoursRequest replaces the shifts and closures of a restaurant. No
+// shifts means the restaurant is open at all times outside its closures.
+type OpeningHoursRequest struct {
+	Shifts   []OpeningShift `json:"shifts" validate:"max=50,dive"`
+	Closures []Closure      `json:"closures" validate:"max=100,dive"`
+}
+
+// check returns the problems the validate tags cannot express: closures
+// must not end before they start
+func (r OpeningHoursRequest) check() []FieldError {
+	var fields []FieldError
+	for i, c := range r.Closures {
+		if c.EndDate < c.StartDate {
+			field := fmt.Sprintf("closures[%d].end_date", i)
+			fields = append(fields, FieldError{Field: field, Rule: "gtefield", Message: field + " must not be before start_date"})
+		}
+	}
+	return fields
+}
+
+// hours returns the shifts and closures of the request as they are stored:
+// times as HH:MM, shifts in week order and closures by date
+func (r OpeningHoursRequest) hours() ([]OpeningShift, []Closure) {
+	shifts := make([]OpeningShift, len(r.Shifts))
+	for i, s := range r.Shifts {
+		w := AvailabilityRequest{Windows: []AvailabilityWindow{s.window()}}.windows()[0]
+		shifts[i] = OpeningShift{Day: s.Day, OpensAt: w.StartTime, ClosesAt: w.EndTime}
+	}
+	day := func(name string) int {
+		for i, d := range weekdays {
+			if d == name {
+				return i
+			}
+		}
+		return len(weekdays)
+	}
+	sort.SliceStable(shifts, func(a, b int) bool {
+		if shifts[a].Day != shifts[b].Day {
+			return day(shifts[a].Day) < day(shifts[b].Day)
+		}
+		return shifts[a].OpensAt < shifts[b].OpensAt
+	})
+
+	closures := append([]Closure{}, r.Closures...)
+	for i := range closures {
+		closures[i].Reason = strings.TrimSpace(closures[i].Reason)
+	}
+	sort.SliceStable(closures, func(a, b int) bool { return closures[a].StartDate < closures[b].StartDate })
+	return shifts, closures
+}
+
+// OrderingRequest pauses or resumes ordering at a restaurant
+type OrderingRequest struct {
+	Paused *bool `json:"paused" validate:"required"`
+}
+
+// setOpenStatus sets the computed opening fields of r from its hours at now
+func (r *Restaurant) setOpenStatus(h OpeningHours, now time.Time) {
+	now = now.In(h.location())
+	r.IsOpenNow = h.openAt(now)
+	r.NextOpening = nil
+	if !r.IsOpenNow {
+		if next, ok := h.nextOpening(now); ok {
+			r.NextOpening = &next
+		}
+	}
+}
+
+// orderTime returns the time req is for: the time it is scheduled for, or
+// now
+func (r CreateOrderRequest) orderTime(now time.Time) time.Time {
+	if r.ScheduledFor != nil {
+		return *r.ScheduledFor
+	}
+	return now
+}
+
+// releaseAt returns when an order scheduled for scheduledFor goes to the
+// kitchen, or nil for an order placed for now
+func (h OpeningHours) releaseAt(scheduledFor *time.Time) *time.Time {
+	if scheduledFor == nil {
+		return nil
+	}
+	// TIMESTAMP columns only keep whole seconds
+	releaseAt := scheduledFor.Add(-h.prepTime()).Truncate(time.Second)
+	return &releaseAt
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpeningHours(t *testing.T) {
+	var shifts []OpeningShift
+	for _, day := range []string{"mon", "tue", "wed", "thu", "fri"} {
+		shifts = append(shifts, OpeningShift{Day: day, OpensAt: "11:00", ClosesAt: "14:30"})
+	}
+	shifts = append(shifts, OpeningShift{Day: "mon", OpensAt: "18:00", ClosesAt: "22:00"}, OpeningShift{Day: "fri", OpensAt: "18:00", ClosesAt: "02:00"})
+	christmas := []Closure{{StartDate: "2024-12-24", EndDate: "2024-12-26", Reason: "Christmas"}}
+	week := OpeningHours{Shifts: shifts, Closures: christmas}
+
+	tests := []struct {
+		name  string
+		hours OpeningHours
+		at    string // a local time
+		open  bool
+		next  string
+	}{
+		{name: "lunch", hours: week, at: "2024-06-03 12:00", open: true},
+		{name: "between shifts", hours: week, at: "2024-06-03 15:00", next: "2024-06-03 18:00"},
+		{name: "friday night", hours: week, at: "2024-06-01 01:30", open: true},
+		{name: "weekend", hours: week, at: "2024-06-01 03:00", next: "2024-06-03 11:00"},
+		{name: "christmas", hours: week, at: "2024-12-24 12:00", next: "2024-12-27 11:00"},
+		{name: "closure without shifts", hours: OpeningHours{Closures: christmas}, at: "2024-12-25 10:00", next: "2024-12-27 00:00"},
+		{name: "no hours", at: "2024-12-25 10:00", open: true},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse("2006-01-02 15:04", tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tt.hours.openAt(at); got != tt.open {
+			t.Errorf("%s: got open %v, want %v", tt.name, got, tt.open)
+		}
+		if tt.open {
+			continue
+		}
+		next, ok := tt.hours.nextOpening(at)
+		if got := next.Format("2006-01-02 15:04"); !ok || got != tt.next {
+			t.Errorf("%s: got next opening %s, %v; want %s", tt.name, got, ok, tt.next)
+		}
+	}
+}
+
+// TestOpeningHoursOrders closes a restaurant for today and checks its
+// opening status, the orders placed now and scheduled for later, and
+// pausing
+func TestOpeningHoursOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Hours", TimeZone: "Europe/Paris"})
+		ctx, restaurant := f.ctx, f.restaurant
+		if !restaurant.IsOpenNow || restaurant.NextOpening != nil {
+			t.Errorf("got open %v until %v, want a restaurant without hours open", restaurant.IsOpenNow, restaurant.NextOpening)
+		}
+		soup := f.item("Soup")
+
+		paris := restaurantLocation("Europe/Paris")
+		now := time.Now().In(paris)
+		today := now.Format("2006-01-02")
+		tomorrow := func(hour, min int) time.Time {
+			return time.Date(now.Year(), now.Month(), now.Day()+1, hour, min, 0, 0, paris)
+		}
+		var shifts []OpeningShift
+		for _, day := range weekdays {
+			shifts = append(shifts, OpeningShift{Day: day, OpensAt: "18:00", ClosesAt: "23:00"}, OpeningShift{Day: day, OpensAt: "11:00", ClosesAt: "14:30"})
+		}
+		hours, err := store.UpdateOpeningHours(ctx, restaurant.ID, OpeningHoursRequest{
+			Shifts:   shifts,
+			Closures: []Closure{{StartDate: today, EndDate: today, Reason: " Inventory "}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hours.Shifts) != 14 || hours.Shifts[0] != (OpeningShift{Day: "mon", OpensAt: "11:00", ClosesAt: "14:30"}) || hours.Closures[0].Reason != "Inventory" || hours.TimeZone != "Europe/Paris" {
+			t.Fatalf("got %+v, want the shifts in week order and the closure", hours)
+		}
+		restaurant, err = store.GetRestaurant(ctx, restaurant.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opens := tomorrow(11, 0)
+		if restaurant.IsOpenNow || restaurant.NextOpening == nil || !restaurant.NextOpening.Equal(opens) {
+			t.Errorf("got open %v, next opening %v; want closed until %s", restaurant.IsOpenNow, restaurant.NextOpening, opens)
+		}
+
+		req := f.order(line(soup))
+		quote := func(name string, scheduledFor *time.Time, field, rule string) {
+			req := req
+			req.ScheduledFor = scheduledFor
+			var oerr *OrderError
+			if _, err := store.QuoteOrder(ctx, req); !isOrderRule(err, field, rule) || !errors.As(err, &oerr) || len(oerr.Fields) != 1 {
+				t.Errorf("%s: got %v, want rule %s on %s", name, err, rule, field)
+			}
+		}
+		past, afternoon := now.Add(-time.Hour), tomorrow(16, 0)
+		quote("now", nil, "restaurant_id", "closed")
+		quote("in the past", &past, "scheduled_for", "future")
+		quote("between shifts", &afternoon, "scheduled_for", "closed")
+
+		lunch := tomorrow(12, 30)
+		req.ScheduledFor = &lunch
+		order, err := store.CreateOrder(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.ScheduledFor == nil || !order.ScheduledFor.Equal(lunch) {
+			t.Errorf("got scheduled for %v, want %s", order.ScheduledFor, lunch)
+		}
+
+		// Pausing stops orders for now but not scheduled ones
+		if _, err := store.UpdateOpeningHours(ctx, restaurant.ID, OpeningHoursRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		restaurant, err = store.SetOrderingPaused(ctx, restaurant.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !restaurant.OrderingPaused || !restaurant.IsOpenNow {
+			t.Errorf("got paused %v and open %v, want a paused restaurant that is open", restaurant.OrderingPaused, restaurant.IsOpenNow)
+		}
+		quote("paused", nil, "restaurant_id", "paused")
+		if _, err := store.QuoteOrder(ctx, req); err != nil {
+			t.Errorf("scheduled while paused: got %v, want a quote", err)
+		}
+		if _, err := store.SetOrderingPaused(ctx, restaurant.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		req.ScheduledFor = nil
+		if _, err := store.QuoteOrder(ctx, req); err != nil {
+			t.Errorf("resumed: got %v, want a quote", err)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OptionGroup is a choice offered with a menu item, such as its size or
+// extra toppings. Every unit of the item ordered gets between MinSelections
+// and MaxSelections of its options.
+type OptionGroup struct {
+	ID            int          `json:"id" db:"id"`
+	MenuItemID    int          `json:"menu_item_id" db:"menu_item_id"`
+	Name          string       `json:"name" db:"name"`
+	MinSelections int          `json:"min_selections" db:"min_selections"`
+	MaxSelections int          `json:"max_selections" db:"max_selections"`
+	Options       []MenuOption `json:"options"`
+}
+
+// MenuOption is one option of an option group. Its price delta is added to
+// the price of the menu item and may be negative, e.g. for a smaller size.
+type MenuOption struct {
+	ID            int    `json:"id" db:"id"`
+	OptionGroupID int    `json:"option_group_id" db:"option_group_id"`
+	Name          string `json:"name" db:"name"`
+	PriceDelta    Money  `json:"price_delta" db:"price_delta"`
+	IsAvailable   bool   `json:"is_available" db:"is_available"`
+}
+
+// OrderItemOption is an option chosen for an order item, copied from the
+// menu when the order was placed so receipts and kitchen tickets still show
+// it after the menu changes. OptionID is null once the option is removed
+// from the menu.
+type OrderItemOption struct {
+	ID          int    `json:"id" db:"id"`
+	OrderItemID int    `json:"order_item_id" db:"order_item_id"`
+	OptionID    *int   `json:"option_id" db:"option_id"`
+	GroupName   string `json:"group_name" db:"group_name"`
+	Name        string `json:"name" db:"name"`
+	PriceDelta  Money  `json:"price_delta" db:"price_delta"`
+}
+
+// selectedOptions returns the options of m chosen by ids, in the order of
+// ids, as they are stored on order items. IDs that are not options of m are
+// skipped.
+func (m MenuItem) selectedOptions(ids []int) []OrderItemOption {
+	var selected []OrderItemOption
+	for _, id := range ids {
+		for _, g := range m.OptionGroups {
+			for _, o := range g.Options {
+				if o.ID == id {
+					optionID := o.ID
+					selected = append(selected, OrderItemOption{OptionID: &optionID, GroupName: g.Name, Name: o.Name, PriceDelta: o.PriceDelta})
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// unitPrice returns the price of one unit of m as ordered by item, with the
+// deltas of its options and bundle choices
+func (m MenuItem) unitPrice(item CreateOrderItemRequest) Money {
+	price := m.Price
+	for _, o := range m.selectedOptions(item.OptionIDs) {
+		price += o.PriceDelta
+	}
+	for _, c := range m.components(item.BundleChoices) {
+		price += c.Item.PriceDelta
+	}
+	return price
+}
+
+// optionProblems returns the problems with choosing the options ids for
+// item i of an order: each must be an available option of m, and each
+// option group of m needs between its minimum and maximum selections
+func (m MenuItem) optionProblems(i int, ids []int) []FieldError {
+	var fields []FieldError
+	groupOf := make(map[int]int)
+	available := make(map[int]bool)
+	for _, g := range m.OptionGroups {
+		for _, o := range g.Options {
+			groupOf[o.ID] = g.ID
+			available[o.ID] = o.IsAvailable
+		}
+	}
+
+	selections := make(map[int]int)
+	for j, id := range ids {
+		field := fmt.Sprintf("items[%d].option_ids[%d]", i, j)
+		groupID, ok := groupOf[id]
+		switch {
+		case !ok:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "exists",
+				Message: fmt.Sprintf("%s is not an option of menu item %d", field, m.ID),
+			})
+		case !available[id]:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "available",
+				Message: fmt.Sprintf("%s is not available", field),
+			})
+		default:
+			selections[groupID]++
+		}
+	}
+
+	field := fmt.Sprintf("items[%d].option_ids", i)
+	for _, g := range m.OptionGroups {
+		switch n := selections[g.ID]; {
+		case n < g.MinSelections:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "min_selections",
+				Message: fmt.Sprintf("%s must include at least %d option(s) of %s", field, g.MinSelections, g.Name),
+			})
+		case n > g.MaxSelections:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "max_selections",
+				Message: fmt.Sprintf("%s must include at most %d option(s) of %s", field, g.MaxSelections, g.Name),
+			})
+		}
+	}
+	return fields
+}
+
+// findOptionGroup returns the group of groups named name, ignoring case like
+// the column, or nil
+func findOptionGroup(groups []OptionGroup, name string) *OptionGroup {
+	for i := range groups {
+		if strings.EqualFold(groups[i].Name, name) {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+// findMenuOption returns the option of options named name, ignoring case, or nil
+func findMenuOption(options []MenuOption, name string) *MenuOption {
+	for i := range options {
+		if strings.EqualFold(options[i].Name, name) {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+// findOptionGroupRequest returns the group of groups named name, ignoring
+// case, or nil
+func findOptionGroupRequest(groups []OptionGroupRequest, name string) *OptionGroupRequest {
+	for i := range groups {
+		if strings.EqualFold(groups[i].Name, name) {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+// findMenuOptionRequest returns the option of options named name, ignoring
+// case, or nil
+func findMenuOptionRequest(options []MenuOptionRequest, name string) *MenuOptionRequest {
+	for i := range options {
+		if strings.EqualFold(options[i].Name, name) {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+// MenuItemOptionsRequest for replacing the option groups of a menu item.
+// Groups and options are matched to the existing ones by name, so the ones
+// kept keep their IDs.
+type MenuItemOptionsRequest struct {
+	OptionGroups []OptionGroupRequest `json:"option_groups" validate:"max=20,dive"`
+}
+
+// OptionGroupRequest is one option group of a MenuItemOptionsRequest
+type OptionGroupRequest struct {
+	Name          string              `json:"name" validate:"required,max=100"`
+	MinSelections int                 `json:"min_selections" validate:"gte=0,max=50"`
+	MaxSelections int                 `json:"max_selections" validate:"gte=1,max=50"`
+	Options       []MenuOptionRequest `json:"options" validate:"required,min=1,max=50,dive"`
+}
+
+// MenuOptionRequest is one option of an OptionGroupRequest
+type MenuOptionRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	PriceDelta  Money  `json:"price_delta" validate:"gte=-99999999.99,max=99999999.99"`
+	IsAvailable bool   `json:"is_available"`
+}
+
+// check returns the problems the validate tags cannot express: names are
+// unique regardless of case, like the columns, and every group must allow
+// as many selections as it requires
+func (r MenuItemOptionsRequest) check() []FieldError {
+	var fields []FieldError
+	groups := make(map[string]bool)
+	for i, g := range r.OptionGroups {
+		prefix := fmt.Sprintf("option_groups[%d]", i)
+		if groups[strings.ToLower(g.Name)] {
+			fields = append(fields, FieldError{Field: prefix + ".name", Rule: "unique", Message: prefix + ".name must not repeat another group's name"})
+		}
+		groups[strings.ToLower(g.Name)] = true
+
+		if g.MaxSelections < g.MinSelections {
+			fields = append(fields, FieldError{Field: prefix + ".max_selections", Rule: "gtefield", Message: prefix + ".max_selections must be at least min_selections"})
+		}
+		if g.MinSelections > len(g.Options) {
+			fields = append(fields, FieldError{Field: prefix + ".min_selections", Rule: "lte", Message: fmt.Sprintf("%s.min_selections must be at most the number of options (%d)", prefix, len(g.Options))})
+		}
+
+		options := make(map[string]bool)
+		for j, o := range g.Options {
+			if options[strings.ToLower(o.Name)] {
+				field := fmt.Sprintf("%s.options[%d].name", prefix, j)
+				fields = append(fields, FieldError{Field: field, Rule: "unique", Message: field + " must not repeat another option's name"})
+			}
+			options[strings.ToLower(o.Name)] = true
+		}
+	}
+	return fields
+}
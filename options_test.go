@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+// TestMenuItemOptions orders a menu item with options, then replaces its
+// option groups and checks that the options kept keep their IDs and the
+// order keeps its copies of the ones removed
+func TestMenuItemOptions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Options"})
+		ctx := f.ctx
+		pizza := f.menuItem(CreateMenuItemRequest{Name: "Margherita", Price: 1000, IsAvailable: true})
+		pizza, err := store.UpdateMenuItemOptions(ctx, pizza.ID, MenuItemOptionsRequest{OptionGroups: []OptionGroupRequest{
+			{Name: "Size", MinSelections: 1, MaxSelections: 1, Options: []MenuOptionRequest{
+				{Name: "Small", PriceDelta: -200, IsAvailable: true},
+				{Name: "Large", PriceDelta: 350, IsAvailable: true},
+			}},
+			{Name: "Extras", MaxSelections: 2, Options: []MenuOptionRequest{
+				{Name: "Extra cheese", PriceDelta: 125, IsAvailable: true},
+				{Name: "No basil", IsAvailable: true},
+			}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, extras := pizza.OptionGroups[0], pizza.OptionGroups[1]
+		small, large, cheese := size.Options[0].ID, size.Options[1].ID, extras.Options[0].ID
+
+		tests := []struct {
+			name      string
+			optionIDs []int
+			price     Money
+			rule      string
+		}{
+			{name: "large with cheese", optionIDs: []int{large, cheese}, price: 1475},
+			{name: "small", optionIDs: []int{small}, price: 800},
+			{name: "no size", optionIDs: []int{cheese}, rule: "min_selections"},
+			{name: "two sizes", optionIDs: []int{small, large}, rule: "max_selections"},
+			{name: "other item", optionIDs: []int{small, 99999}, rule: "exists"},
+		}
+		for _, tt := range tests {
+			quote, err := store.QuoteOrder(ctx, f.order(CreateOrderItemRequest{MenuItemID: pizza.ID, Quantity: 2, OptionIDs: tt.optionIDs}))
+			if tt.rule != "" {
+				if !isOrderRule(err, "", tt.rule) {
+					t.Errorf("%s: got %v, want rule %s", tt.name, err, tt.rule)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if quote.Items[0].UnitPrice != tt.price || quote.Pricing.Subtotal != tt.price.Mul(2) {
+				t.Errorf("%s: got unit price %s, subtotal %s; want %s", tt.name, quote.Items[0].UnitPrice, quote.Pricing.Subtotal, tt.price)
+			}
+		}
+
+		order, err := store.CreateOrder(ctx, f.order(CreateOrderItemRequest{MenuItemID: pizza.ID, Quantity: 1, OptionIDs: []int{large, cheese}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Keep Size, spelled differently, drop Extras and make Large unavailable
+		pizza, err = store.UpdateMenuItemOptions(ctx, pizza.ID, MenuItemOptionsRequest{OptionGroups: []OptionGroupRequest{
+			{Name: "size", MinSelections: 1, MaxSelections: 1, Options: []MenuOptionRequest{
+				{Name: "Large", PriceDelta: 400},
+				{Name: "Small", PriceDelta: -200, IsAvailable: true},
+			}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pizza.OptionGroups) != 1 || pizza.OptionGroups[0].ID != size.ID || pizza.OptionGroups[0].Options[0].ID != large || pizza.OptionGroups[0].Options[1].ID != small {
+			t.Errorf("option groups were not matched by name: %+v", pizza.OptionGroups)
+		}
+
+		order, err = store.GetOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options := order.Items[0].Options
+		if order.Items[0].UnitPrice != 1475 || len(options) != 2 {
+			t.Fatalf("got order item %+v, want 14.75 with 2 options", order.Items[0])
+		}
+		if options[0].OptionID == nil || *options[0].OptionID != large || options[0].PriceDelta != 350 || options[0].GroupName != "Size" {
+			t.Errorf("got %+v, want the Large option as ordered", options[0])
+		}
+		if options[1].OptionID != nil || options[1].Name != "Extra cheese" || options[1].PriceDelta != 125 {
+			t.Errorf("got %+v, want a copy of the removed Extra cheese option", options[1])
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+// OrderStatusEvent records one change of an order's status. The event
+// written when an order is placed has no FromStatus.
+type OrderStatusEvent struct {
+	ID         int       `json:"id" db:"id"`
+	OrderID    int       `json:"order_id" db:"order_id"`
+	FromStatus string    `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Actor      string    `json:"actor,omitempty" db:"actor"`
+	Reason     string    `json:"reason,omitempty" db:"reason"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// OrderHistory is the status timeline of an order, oldest event first
+type OrderHistory struct {
+	OrderID   int                `json:"order_id"`
+	Status    string             `json:"status"`
+	Events    []OrderStatusEvent `json:"events"`
+	Durations OrderDurations     `json:"durations"`
+}
+
+// OrderDurations holds the seconds an order spent in each stage of the
+// lifecycle. A stage is omitted until the order has completed it.
+type OrderDurations struct {
+	// TimeToConfirm runs from the order reaching the kitchen as pending,
+	// when it is placed or a scheduled order is released, until it is
+	// confirmed
+	TimeToConfirm *int64 `json:"time_to_confirm_seconds,omitempty"`
+	// WaitTime runs from confirmation until preparation starts
+	WaitTime *int64 `json:"wait_seconds,omitempty"`
+	// PrepTime runs from the start of preparation until the order is ready
+	PrepTime *int64 `json:"prep_seconds,omitempty"`
+	// DeliveryTime runs from ready until delivered
+	DeliveryTime *int64 `json:"delivery_seconds,omitempty"`
+	// Total runs from placing the order, its first event, until it is
+	// delivered or cancelled, including the time a scheduled order waited
+	// for its release
+	Total *int64 `json:"total_seconds,omitempty"`
+}
+
+// orderDurations measures the stages of an order from its status events,
+// oldest first, using the first time the order entered each status
+func orderDurations(events []OrderStatusEvent) OrderDurations {
+	entered := make(map[string]time.Time)
+	if len(events) > 0 {
+		entered["placed"] = events[0].CreatedAt
+	}
+	for _, e := range events {
+		if _, ok := entered[e.ToStatus]; !ok {
+			entered[e.ToStatus] = e.CreatedAt
+		}
+	}
+	between := func(from, to string) *int64 {
+		start, ok := entered[from]
+		if !ok {
+			return nil
+		}
+		end, ok := entered[to]
+		if !ok {
+			return nil
+		}
+		seconds := int64(end.Sub(start) / time.Second)
+		return &seconds
+	}
+
+	d := OrderDurations{
+		TimeToConfirm: between("pending", "confirmed"),
+		WaitTime:      between("confirmed", "preparing"),
+		PrepTime:      between("preparing", "ready"),
+		DeliveryTime:  between("ready", "delivered"),
+		Total:         between("placed", "delivered"),
+	}
+	if d.Total == nil {
+		d.Total = between("placed", "cancelled")
+	}
+	return d
+}
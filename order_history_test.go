@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderDurations(t *testing.T) {
+	placed := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	history := func(statuses ...string) []OrderStatusEvent {
+		// One event every ten minutes from placed
+		events := make([]OrderStatusEvent, len(statuses))
+		for i, status := range statuses {
+			events[i] = OrderStatusEvent{ToStatus: status, CreatedAt: placed.Add(time.Duration(i) * 10 * time.Minute)}
+		}
+		return events
+	}
+	seconds := func(d *int64) int64 {
+		if d == nil {
+			return -1
+		}
+		return *d
+	}
+
+	tests := []struct {
+		name    string
+		events  []OrderStatusEvent
+		confirm int64 // -1 when not measured
+		total   int64
+	}{
+		{name: "delivered", events: history("pending", "confirmed", "preparing", "ready", "delivered"), confirm: 600, total: 2400},
+		{name: "in progress", events: history("pending", "confirmed"), confirm: 600, total: -1},
+		{name: "cancelled", events: history("pending", "cancelled"), confirm: -1, total: 600},
+		{name: "scheduled and delivered", events: history("scheduled", "pending", "confirmed", "preparing", "ready", "delivered"), confirm: 600, total: 3000},
+		{name: "scheduled and cancelled", events: history("scheduled", "cancelled"), confirm: -1, total: 600},
+		{name: "no events", confirm: -1, total: -1},
+	}
+	for _, tt := range tests {
+		d := orderDurations(tt.events)
+		if got := seconds(d.TimeToConfirm); got != tt.confirm {
+			t.Errorf("%s: got time to confirm %d, want %d", tt.name, got, tt.confirm)
+		}
+		if got := seconds(d.Total); got != tt.total {
+			t.Errorf("%s: got total %d, want %d", tt.name, got, tt.total)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestCanTransitionOrder checks every pair of statuses against the order
+// lifecycle documented in the README
+func TestCanTransitionOrder(t *testing.T) {
+	allowed := map[string]bool{
+		"scheduled>cancelled": true,
+		"pending>confirmed":   true,
+		"pending>cancelled":   true,
+		"confirmed>preparing": true,
+		"confirmed>cancelled": true,
+		"preparing>ready":     true,
+		"ready>delivered":     true,
+	}
+	for _, from := range append(OrderStatuses, "lost") {
+		for _, to := range append(OrderStatuses, "lost") {
+			want := allowed[from+">"+to]
+			if got := canTransitionOrder(from, to); got != want {
+				t.Errorf("%s to %s: got %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestOrderStatusPath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"pending", "delivered", "confirmed preparing ready delivered"},
+		{"confirmed", "cancelled", "cancelled"},
+		{"scheduled", "cancelled", "cancelled"},
+		{"preparing", "cancelled", ""},
+		{"scheduled", "delivered", ""},
+		{"delivered", "pending", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(orderStatusPath(tt.from, tt.to), " "); got != tt.want {
+			t.Errorf("%s to %s: got %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// TestRejectedTransitions checks that a status change the lifecycle does
+// not allow leaves the order and its history as they were
+func TestRejectedTransitions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Transitions"})
+		ctx := f.ctx
+		order, err := store.CreateOrder(ctx, f.order(line(f.item("Soup"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, status := range []string{"confirmed", "preparing"} {
+			if _, err := store.UpdateOrderStatus(ctx, order.ID, UpdateOrderStatusRequest{Status: status}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, status := range []string{"cancelled", "delivered", "pending", "preparing", "scheduled"} {
+			_, err := store.UpdateOrderStatus(ctx, order.ID, UpdateOrderStatusRequest{Status: status, Actor: "staff"})
+			var terr *TransitionError
+			if !errors.As(err, &terr) || terr.From != "preparing" || terr.To != status {
+				t.Errorf("preparing to %s: got %v, want a *TransitionError", status, err)
+			}
+		}
+
+		got, err := store.GetOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "preparing" {
+			t.Errorf("got status %s, want preparing", got.Status)
+		}
+		events, err := store.ListOrderStatusEvents(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 || events[2].ToStatus != "preparing" {
+			t.Errorf("got history %+v, want placed, confirmed and preparing only", events)
+		}
+
+		// The API answers 409 with the transitions that are allowed
+		e := newTestServer(store)
+		rec := serveJSON(e, http.MethodPatch, fmt.Sprintf("/api/v1/orders/%d/status", order.ID), `{"status": "cancelled"}`, nil)
+		p := problemOf(t, rec)
+		if rec.Code != http.StatusConflict || p.Code != "invalid_transition" || strings.Join(p.AllowedTransitions, " ") != "ready" {
+			t.Errorf("got %d %s allowing %v, want 409 invalid_transition allowing ready", rec.Code, p.Code, p.AllowedTransitions)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestCheckOrder places orders breaking several rules at once and checks
+// that every problem is reported against the request field at fault
+func TestCheckOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		burgers := newFixture(t, store, CreateRestaurantRequest{Name: "Burger Barn"})
+		sushi := newFixture(t, store, CreateRestaurantRequest{Name: "Sushi Zen"})
+		ctx := sushi.ctx
+		burger := burgers.item("Classic Burger")
+		roll := sushi.item("Salmon Roll")
+		soldOut := sushi.menuItem(CreateMenuItemRequest{Name: "Eel Roll", IsAvailable: false})
+
+		tests := []struct {
+			name string
+			req  CreateOrderRequest
+			want []string // field and rule of each problem, in order
+		}{
+			{
+				name: "item of another restaurant",
+				req:  sushi.order(line(roll), line(burger)),
+				want: []string{"items[1].menu_item_id same_restaurant"},
+			},
+			{
+				name: "unavailable item",
+				req:  sushi.order(line(soldOut), line(roll)),
+				want: []string{"items[0].menu_item_id available"},
+			},
+			{
+				name: "every bad line",
+				req:  sushi.order(line(burger), line(roll), line(soldOut), CreateOrderItemRequest{MenuItemID: 9999, Quantity: 1}),
+				want: []string{
+					"items[0].menu_item_id same_restaurant",
+					"items[2].menu_item_id available",
+					"items[3].menu_item_id exists",
+				},
+			},
+			{
+				name: "unknown customer and restaurant",
+				req:  CreateOrderRequest{CustomerID: 9999, RestaurantID: 9999, Items: []CreateOrderItemRequest{line(roll)}},
+				want: []string{"customer_id exists", "restaurant_id exists"},
+			},
+		}
+		for _, tt := range tests {
+			_, err := store.CreateOrder(ctx, tt.req)
+			var oerr *OrderError
+			if !errors.As(err, &oerr) {
+				t.Errorf("%s: got %v, want an *OrderError", tt.name, err)
+				continue
+			}
+			var got []string
+			for _, f := range oerr.Fields {
+				got = append(got, f.Field+" "+f.Rule)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+			}
+		}
+
+		// The API answers 422 listing every problem
+		e := newTestServer(store)
+		body := fmt.Sprintf(`{"customer_id": %d, "restaurant_id": %d, "items": [{"menu_item_id": %d, "quantity": 1}, {"menu_item_id": %d, "quantity": 1}]}`,
+			sushi.customer.ID, sushi.restaurant.ID, burger.ID, soldOut.ID)
+		rec := serveJSON(e, http.MethodPost, "/api/v1/orders", body, nil)
+		if p := problemOf(t, rec); rec.Code != http.StatusUnprocessableEntity || p.Code != "invalid_order" || len(p.Errors) != 2 {
+			t.Errorf("got %d %s with %d errors, want 422 invalid_order with 2", rec.Code, p.Code, len(p.Errors))
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Paginator reads the paging, sort and filter parameters of list requests
+// and writes the list envelope. Clients page either with limit and the
+// opaque cursor from next_cursor, or with page and per_page.
+type Paginator struct {
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
+// NewPaginator creates a paginator from the configuration
+func NewPaginator(cfg PaginationConfig) Paginator {
+	return Paginator{DefaultPageSize: cfg.DefaultPageSize, MaxPageSize: cfg.MaxPageSize}
+}
+
+// ListResponse is the envelope returned by every list endpoint
+type ListResponse struct {
+	Data       interface{} `json:"data"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Page       int         `json:"page,omitempty"`
+	PerPage    int         `json:"per_page,omitempty"`
+	TotalPages int         `json:"total_pages,omitempty"`
+}
+
+// maxPageOffset is the number of records page and per_page may skip. Deeper
+// offsets make the database read and discard every skipped row; clients
+// paging that far use cursors instead.
+const maxPageOffset = 100000
+
+// positiveParam parses an optional positive integer query parameter
+func positiveParam(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, BadRequest("invalid_parameter", fmt.Sprintf("%s must be a positive integer", name))
+	}
+	return n, nil
+}
+
+// Parse returns the list options requested by c for res
+func (p Paginator) Parse(c echo.Context, res listResource) (ListOptions, error) {
+	opts := ListOptions{Sort: res.defaultSort, Limit: p.DefaultPageSize}
+
+	limit, err := positiveParam(c, "limit")
+	if err != nil {
+		return opts, err
+	}
+	perPage, err := positiveParam(c, "per_page")
+	if err != nil {
+		return opts, err
+	}
+	page, err := positiveParam(c, "page")
+	if err != nil {
+		return opts, err
+	}
+	cursor := c.QueryParam("cursor")
+
+	if limit > 0 && perPage > 0 {
+		return opts, BadRequest("invalid_parameter", "Use either limit or per_page, not both")
+	}
+	if cursor != "" && page > 0 {
+		return opts, BadRequest("invalid_parameter", "Use either cursor or page, not both")
+	}
+	if perPage > 0 {
+		limit = perPage
+	}
+	if limit > 0 {
+		opts.Limit = limit
+	}
+	if opts.Limit > p.MaxPageSize {
+		opts.Limit = p.MaxPageSize
+	}
+
+	if page > 0 {
+		// Compared before multiplying, which could overflow
+		if page-1 > maxPageOffset/opts.Limit {
+			e := BadRequest("invalid_parameter", "page is too large; use cursor to page further")
+			e.Fields = []FieldError{{
+				Field:   "page",
+				Rule:    "max",
+				Message: fmt.Sprintf("page must be at most %d with %d per page", maxPageOffset/opts.Limit+1, opts.Limit),
+			}}
+			return opts, e
+		}
+		opts.Offset = (page - 1) * opts.Limit
+	}
+
+	if raw := c.QueryParam("sort"); raw != "" {
+		if opts.Sort, err = parseSort(res, raw); err != nil {
+			return opts, BadRequest("invalid_parameter", err.Error())
+		}
+	}
+	if opts.Filters, err = parseFilters(c, res); err != nil {
+		return opts, err
+	}
+	if opts.Include, err = parseInclude(c, res); err != nil {
+		return opts, err
+	}
+
+	// The cursor is checked against the sort order, so it is decoded last
+	if cursor != "" {
+		opts.After, err = decodeCursor(res, opts, cursor)
+		if err != nil {
+			return opts, BadRequest("invalid_cursor", "Invalid or expired cursor")
+		}
+	}
+	return opts, nil
+}
+
+// listParams are the query parameters that never name a filter
+var listParams = map[string]bool{"limit": true, "per_page": true, "page": true, "cursor": true, "sort": true, "include": true}
+
+// parseFilters reads the filters of res from the query string. Parameters
+// that do not name a field of res are ignored unless they use the
+// field[op] syntax, so a typo in an operator is never silently dropped.
+func parseFilters(c echo.Context, res listResource) ([]Filter, error) {
+	query := c.QueryParams()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	// A stable order keeps the generated SQL the same for the same request
+	sort.Strings(names)
+
+	var filters []Filter
+	for _, name := range names {
+		if listParams[name] {
+			continue
+		}
+		if _, ok := res.field(name); !ok && !strings.HasSuffix(name, "]") {
+			continue
+		}
+		for _, raw := range query[name] {
+			filter, err := parseFilter(res, name, raw)
+			if err != nil {
+				return nil, BadRequest("invalid_parameter", err.Error())
+			}
+			filters = append(filters, *filter)
+		}
+	}
+	return filters, nil
+}
+
+// parseInclude reads the related records to load from the include
+// parameter. Without it the defaults of res apply; an empty include loads
+// nothing.
+func parseInclude(c echo.Context, res listResource) ([]string, error) {
+	values, ok := c.QueryParams()["include"]
+	if !ok {
+		return res.defaultInclude, nil
+	}
+	include := []string{}
+	for _, path := range strings.Split(strings.Join(values, ","), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !containsString(res.includes, path) {
+			if len(res.includes) == 0 {
+				return nil, BadRequest("invalid_parameter", fmt.Sprintf("%s have no related records to include", strings.ReplaceAll(res.table, "_", " ")))
+			}
+			return nil, BadRequest("invalid_parameter", fmt.Sprintf("cannot include %q; valid values are %s", path, strings.Join(res.includes, ", ")))
+		}
+		include = append(include, path)
+	}
+	return include, nil
+}
+
+// Respond writes data, a slice holding one page of res, in the list
+// envelope and sets the Link header
+func (p Paginator) Respond(c echo.Context, res listResource, opts ListOptions, data interface{}, info PageInfo) error {
+	resp := ListResponse{Data: data, Total: info.Total, Limit: opts.Limit}
+	links := []string{linkTo(c, "first", map[string]string{"cursor": "", "page": ""})}
+
+	if c.QueryParam("page") != "" || c.QueryParam("per_page") != "" {
+		resp.Page = opts.Offset/opts.Limit + 1
+		resp.PerPage = opts.Limit
+		resp.TotalPages = (info.Total + opts.Limit - 1) / opts.Limit
+		if resp.Page > 1 {
+			links = append(links, linkTo(c, "prev", map[string]string{"page": strconv.Itoa(resp.Page - 1)}))
+		}
+		if info.HasMore {
+			links = append(links, linkTo(c, "next", map[string]string{"page": strconv.Itoa(resp.Page + 1)}))
+		}
+		if resp.TotalPages > 0 {
+			links = append(links, linkTo(c, "last", map[string]string{"page": strconv.Itoa(resp.TotalPages)}))
+		}
+	} else if info.HasMore {
+		v := reflect.ValueOf(data)
+		last := v.Index(v.Len() - 1).Addr().Interface()
+		resp.NextCursor = encodeCursor(res, opts, cursorFor(res, opts, last))
+		links = append(links, linkTo(c, "next", map[string]string{"cursor": resp.NextCursor}))
+	}
+
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
+	return c.JSON(http.StatusOK, resp)
+}
+
+// linkTo renders a Link header entry for the current request URL with the
+// given query parameters replaced; empty values remove the parameter
+func linkTo(c echo.Context, rel string, params map[string]string) string {
+	u := url.URL{Path: c.Request().URL.Path}
+	query := c.Request().URL.Query()
+	for name, value := range params {
+		if value == "" {
+			query.Del(name)
+		} else {
+			query.Set(name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+}
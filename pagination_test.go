@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// parseList parses the list options of a request for res with the given
+// query string
+func parseList(p Paginator, res listResource, query string) (ListOptions, error) {
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return p.Parse(echo.New().NewContext(req, httptest.NewRecorder()), res)
+}
+
+func TestPaginatorParsePages(t *testing.T) {
+	p := Paginator{DefaultPageSize: 20, MaxPageSize: 100}
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		field  string // field of the error, "-" for an error without one
+	}{
+		{query: "", limit: 20},
+		{query: "limit=5", limit: 5},
+		{query: "limit=500", limit: 100},
+		{query: "page=3&per_page=10", limit: 10, offset: 20},
+		{query: "page=5001", limit: 20, offset: 100000},
+		{query: "page=5002", field: "page"},
+		{query: "page=1001&per_page=100", limit: 100, offset: 100000},
+		{query: "page=1002&per_page=100", field: "page"},
+		{query: "page=9223372036854775807", field: "page"},
+		{query: "page=99999999999999999999", field: "-"},
+		{query: "page=0", field: "-"},
+		{query: "limit=abc", field: "-"},
+		{query: "limit=5&per_page=5", field: "-"},
+		{query: "page=2&cursor=abc", field: "-"},
+	}
+	for _, tt := range tests {
+		opts, err := parseList(p, customerListing, tt.query)
+		if tt.field == "" {
+			if err != nil || opts.Limit != tt.limit || opts.Offset != tt.offset {
+				t.Errorf("%q: got limit %d offset %d, %v; want limit %d offset %d", tt.query, opts.Limit, opts.Offset, err, tt.limit, tt.offset)
+			}
+			continue
+		}
+
+		var e *Error
+		if !errors.As(err, &e) || e.Status() != http.StatusBadRequest {
+			t.Errorf("%q: got %v, want a 400 error", tt.query, err)
+			continue
+		}
+		field := "-"
+		if len(e.Fields) > 0 {
+			field = e.Fields[0].Field
+		}
+		if field != tt.field {
+			t.Errorf("%q: got field %s, want %s", tt.query, field, tt.field)
+		}
+	}
+}
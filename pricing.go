@@ -0,0 +1,297 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Percent is an exact percentage with three decimals, e.g. 8.875, stored
+// as thousandths of a percent
+type Percent int64
+
+// String formats the percentage with three decimals, e.g. "20.000"
+func (p Percent) String() string {
+	return formatDecimal(int64(p), 3)
+}
+
+// Float64 returns the percentage. It is only meant for validation rules.
+func (p Percent) Float64() float64 {
+	return float64(p) / 1000
+}
+
+// MarshalJSON encodes the percentage as a number, e.g. 20.000
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	n, err := unmarshalDecimal(data, 3)
+	if err != nil {
+		return err
+	}
+	*p = Percent(n)
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (p *Percent) Scan(src interface{}) error {
+	n, err := scanDecimal(src, 3)
+	if err != nil {
+		return err
+	}
+	*p = Percent(n)
+	return nil
+}
+
+// Value implements driver.Valuer, sending the exact decimal text
+func (p Percent) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// of returns the percentage of m, rounded half away from zero to the cent
+func (p Percent) of(m Money) Money {
+	return m.ratio(int64(p), 100000)
+}
+
+// ratio returns m × num / den rounded half away from zero to the cent
+func (m Money) ratio(num, den int64) Money {
+	n := int64(m) * num
+	if n < 0 {
+		return -Money((-n*2 + den) / (den * 2))
+	}
+	return Money((n*2 + den) / (den * 2))
+}
+
+// TaxRate is a tax charged by a restaurant, e.g. VAT at 20%
+type TaxRate struct {
+	Name string  `json:"name" validate:"required,max=50"`
+	Rate Percent `json:"rate" validate:"gt=0,lte=100"`
+}
+
+// DeliveryFeeBand charges Fee for deliveries up to MaxDistanceKm away
+type DeliveryFeeBand struct {
+	MaxDistanceKm float64 `json:"max_distance_km" validate:"gt=0,lte=1000"`
+	Fee           Money   `json:"fee" validate:"gte=0,max=99999999.99"`
+}
+
+// RestaurantPricing is how a restaurant prices its orders on top of the
+// menu prices. A restaurant that has not set it charges menu prices only.
+type RestaurantPricing struct {
+	RestaurantID int `json:"restaurant_id"`
+	// PricesIncludeTax says menu prices already include the taxes, as
+	// usual with VAT; otherwise taxes are added on top, like sales tax
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	Taxes            []TaxRate `json:"taxes"`
+	// ServiceChargeRate is charged on the discounted subtotal
+	ServiceChargeRate Percent `json:"service_charge_rate"`
+	// DeliveryFeeBands are sorted by distance. Without bands delivery is
+	// free at any distance.
+	DeliveryFeeBands []DeliveryFeeBand `json:"delivery_fee_bands"`
+}
+
+// deliveryFee returns the fee of the first band covering distance, or
+// false when distance is beyond every band
+func (p RestaurantPricing) deliveryFee(distance float64) (Money, bool) {
+	if len(p.DeliveryFeeBands) == 0 {
+		return 0, true
+	}
+	for _, band := range p.DeliveryFeeBands {
+		if distance <= band.MaxDistanceKm {
+			return band.Fee, true
+		}
+	}
+	return 0, false
+}
+
+// sortDeliveryFeeBands sorts bands by distance
+func sortDeliveryFeeBands(bands []DeliveryFeeBand) {
+	sort.Slice(bands, func(i, j int) bool { return bands[i].MaxDistanceKm < bands[j].MaxDistanceKm })
+}
+
+// TaxLine is the amount of one tax charged on an order
+type TaxLine struct {
+	Name   string  `json:"name"`
+	Rate   Percent `json:"rate"`
+	Amount Money   `json:"amount"`
+}
+
+// TaxLines is stored as a JSON array in orders.tax_lines
+type TaxLines []TaxLine
+
+// Scan implements sql.Scanner
+func (t *TaxLines) Scan(src interface{}) error {
+	lines := TaxLines{}
+	if err := scanJSONArray(src, &lines); err != nil {
+		return err
+	}
+	*t = lines
+	return nil
+}
+
+// Value implements driver.Valuer
+func (t TaxLines) Value() (driver.Value, error) {
+	if t == nil {
+		t = TaxLines{}
+	}
+	data, err := json.Marshal(t)
+	return string(data), err
+}
+
+// scanJSONArray decodes a JSON array column into dest, leaving it as is
+// for NULL
+func scanJSONArray(src interface{}, dest interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// PriceBreakdown itemizes the total of an order:
+//
+//	total = subtotal - discount + service_charge + delivery_fee + tip
+//	        + tax_total, unless tax_inclusive
+//
+// The discount is the sum of the discount lines of the promotions the
+// order got. Taxes are charged on the discounted subtotal. With tax_inclusive the
+// menu prices already include them, and the tax lines show the share of
+// the subtotal that is tax.
+type PriceBreakdown struct {
+	Subtotal      Money         `json:"subtotal"`
+	Discount      Money         `json:"discount"`
+	Discounts     DiscountLines `json:"discounts"`
+	ServiceCharge Money         `json:"service_charge"`
+	DeliveryFee   Money         `json:"delivery_fee"`
+	Tip           Money         `json:"tip"`
+	Taxes         TaxLines      `json:"taxes"`
+	TaxTotal      Money         `json:"tax_total"`
+	TaxInclusive  bool          `json:"tax_inclusive"`
+	Total         Money         `json:"total"`
+}
+
+// OrderQuote is the price of a cart, computed exactly as placing it as an
+// order would
+type OrderQuote struct {
+	RestaurantID int            `json:"restaurant_id"`
+	Currency     string         `json:"currency"`
+	Items        []QuoteItem    `json:"items"`
+	Pricing      PriceBreakdown `json:"pricing"`
+}
+
+// QuoteItem is one priced line of a quote
+type QuoteItem struct {
+	MenuItemID    int            `json:"menu_item_id"`
+	Quantity      int            `json:"quantity"`
+	OptionIDs     []int          `json:"option_ids,omitempty"`
+	BundleChoices []BundleChoice `json:"bundle_choices,omitempty"`
+	UnitPrice     Money          `json:"unit_price"`
+	LineTotal     Money          `json:"line_total"`
+}
+
+// priceOrder prices req from the menu, the pricing of its restaurant and
+// the promotions it may get. It returns the unit price of each item with
+// its options and bundle choices, the breakdown and the problems that keep
+// the order from being priced, such as a delivery beyond the restaurant's
+// delivery area. Every item must be in menuItems.
+func priceOrder(req CreateOrderRequest, menuItems map[int]MenuItem, pricing RestaurantPricing, promotions orderPromotions) ([]Money, PriceBreakdown, []FieldError) {
+	var problems []FieldError
+	b := PriceBreakdown{Taxes: TaxLines{}, TaxInclusive: pricing.PricesIncludeTax}
+
+	prices := make([]Money, len(req.Items))
+	for i, item := range req.Items {
+		prices[i] = menuItems[item.MenuItemID].unitPrice(item)
+		b.Subtotal += prices[i].Mul(item.Quantity)
+	}
+	b.Discounts, problems = promotions.apply(req, menuItems, prices, b.Subtotal)
+	for _, line := range b.Discounts {
+		b.Discount += line.Amount
+	}
+	goods := b.Subtotal - b.Discount
+
+	b.ServiceCharge = pricing.ServiceChargeRate.of(goods)
+	b.Tip = req.Tip
+
+	if req.DeliveryAddress != "" || req.DeliveryDistanceKm != nil {
+		switch {
+		case req.DeliveryDistanceKm == nil && len(pricing.DeliveryFeeBands) > 0:
+			problems = append(problems, FieldError{
+				Field:   "delivery_distance_km",
+				Rule:    "required",
+				Message: fmt.Sprintf("delivery_distance_km is required for deliveries from restaurant %d", req.RestaurantID),
+			})
+		case req.DeliveryDistanceKm != nil:
+			fee, ok := pricing.deliveryFee(*req.DeliveryDistanceKm)
+			if !ok {
+				last := pricing.DeliveryFeeBands[len(pricing.DeliveryFeeBands)-1]
+				problems = append(problems, FieldError{
+					Field:   "delivery_distance_km",
+					Rule:    "delivery_area",
+					Message: fmt.Sprintf("restaurant %d only delivers up to %g km", req.RestaurantID, last.MaxDistanceKm),
+				})
+			}
+			b.DeliveryFee = fee
+		}
+	}
+
+	// Inclusive taxes are each rate's share of the gross amount:
+	// goods × rate / (100% + the sum of the rates)
+	var combined int64
+	for _, tax := range pricing.Taxes {
+		combined += int64(tax.Rate)
+	}
+	for _, tax := range pricing.Taxes {
+		line := TaxLine{Name: tax.Name, Rate: tax.Rate, Amount: tax.Rate.of(goods)}
+		if pricing.PricesIncludeTax {
+			line.Amount = goods.ratio(int64(tax.Rate), 100000+combined)
+		}
+		b.Taxes = append(b.Taxes, line)
+		b.TaxTotal += line.Amount
+	}
+
+	b.Total = goods + b.ServiceCharge + b.DeliveryFee + b.Tip
+	if !pricing.PricesIncludeTax {
+		b.Total += b.TaxTotal
+	}
+	if b.Total > MaxMoney {
+		problems = append(problems, FieldError{
+			Field:   "items",
+			Rule:    "max",
+			Message: fmt.Sprintf("the order total must be at most %s", MaxMoney),
+		})
+	}
+	return prices, b, problems
+}
+
+// newOrderQuote returns the quote for req priced by priceOrder
+func newOrderQuote(req CreateOrderRequest, currency string, prices []Money, pricing PriceBreakdown) *OrderQuote {
+	quote := &OrderQuote{
+		RestaurantID: req.RestaurantID,
+		Currency:     currency,
+		Items:        make([]QuoteItem, len(req.Items)),
+		Pricing:      pricing,
+	}
+	for i, item := range req.Items {
+		quote.Items[i] = QuoteItem{
+			MenuItemID:    item.MenuItemID,
+			Quantity:      item.Quantity,
+			OptionIDs:     item.OptionIDs,
+			BundleChoices: item.BundleChoices,
+			UnitPrice:     prices[i],
+			LineTotal:     prices[i].Mul(item.Quantity),
+		}
+	}
+	return quote
+}
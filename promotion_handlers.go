@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PromotionHandler handles promotion-related requests
+type PromotionHandler struct {
+	store PromotionStore
+	pager Paginator
+}
+
+// NewPromotionHandler creates a new promotion handler
+func NewPromotionHandler(store PromotionStore, pager Paginator) *PromotionHandler {
+	return &PromotionHandler{store: store, pager: pager}
+}
+
+// bindPromotion binds and validates a promotion request, including the
+// settings its kind needs
+func bindPromotion(c echo.Context) (PromotionRequest, error) {
+	var req PromotionRequest
+	if err := c.Bind(&req); err != nil {
+		return req, BadRequest("invalid_body", "Invalid request body").WithCause(err)
+	}
+	if err := c.Validate(&req); err != nil {
+		return req, err
+	}
+	if fields := req.check(); len(fields) > 0 {
+		return req, Validation(fields)
+	}
+	return req, nil
+}
+
+// CreatePromotion creates a new promotion
+func (h *PromotionHandler) CreatePromotion(c echo.Context) error {
+	req, err := bindPromotion(c)
+	if err != nil {
+		return err
+	}
+
+	promotion, err := h.store.CreatePromotion(c.Request().Context(), req)
+	if err != nil {
+		return storeError("Failed to create promotion", err)
+	}
+
+	return c.JSON(http.StatusCreated, promotion)
+}
+
+// GetPromotions retrieves a page of promotions
+func (h *PromotionHandler) GetPromotions(c echo.Context) error {
+	opts, err := h.pager.Parse(c, promotionListing)
+	if err != nil {
+		return err
+	}
+
+	promotions, info, err := h.store.ListPromotions(c.Request().Context(), opts)
+	if err != nil {
+		return Internal("Failed to fetch promotions", err)
+	}
+
+	return h.pager.Respond(c, promotionListing, opts, promotions, info)
+}
+
+// GetPromotion retrieves a promotion by ID
+func (h *PromotionHandler) GetPromotion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid promotion ID")
+	}
+
+	promotion, err := h.store.GetPromotion(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("promotion")
+		}
+		return Internal("Failed to fetch promotion", err)
+	}
+
+	return c.JSON(http.StatusOK, promotion)
+}
+
+// UpdatePromotion updates a promotion
+func (h *PromotionHandler) UpdatePromotion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid promotion ID")
+	}
+
+	req, err := bindPromotion(c)
+	if err != nil {
+		return err
+	}
+
+	promotion, err := h.store.UpdatePromotion(c.Request().Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("promotion")
+		}
+		return storeError("Failed to update promotion", err)
+	}
+
+	return c.JSON(http.StatusOK, promotion)
+}
+
+// DeletePromotion deletes a promotion that no order has redeemed
+func (h *PromotionHandler) DeletePromotion(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return BadRequest("invalid_id", "Invalid promotion ID")
+	}
+
+	if err := h.store.DeletePromotion(c.Request().Context(), id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return NotFound("promotion")
+		}
+		return storeError("Failed to delete promotion", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Promotion deleted successfully"})
+}
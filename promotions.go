@@ -0,0 +1,311 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PromotionKinds lists the values allowed in promotions.kind:
+//
+//   - percentage takes percent_off off the eligible items
+//   - fixed takes amount_off off the eligible items
+//   - buy_x_get_y takes percent_off off get_quantity of every
+//     buy_quantity + get_quantity eligible units, cheapest first
+var PromotionKinds = []string{"percentage", "fixed", "buy_x_get_y"}
+
+// Promotion is a discount applied to orders, either automatically or when
+// the order is placed with its code. It covers the items of the order that
+// match its menu item and category, or every item if it sets neither.
+type Promotion struct {
+	ID int `json:"id" db:"id"`
+	// Code is empty for promotions applied automatically; codes match
+	// case-insensitively
+	Code        string `json:"code" db:"code"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+	// RestaurantID limits the promotion to one restaurant; null means every
+	// restaurant with the promotion's currency
+	RestaurantID *int    `json:"restaurant_id" db:"restaurant_id"`
+	Currency     string  `json:"currency" db:"currency"`
+	Kind         string  `json:"kind" db:"kind"`
+	PercentOff   Percent `json:"percent_off" db:"percent_off"`
+	AmountOff    Money   `json:"amount_off" db:"amount_off"`
+	MenuItemID   *int    `json:"menu_item_id" db:"menu_item_id"`
+	Category     string  `json:"category" db:"category"`
+	BuyQuantity  int     `json:"buy_quantity" db:"buy_quantity"`
+	GetQuantity  int     `json:"get_quantity" db:"get_quantity"`
+	// MinSubtotal is the order subtotal, before discounts, the promotion
+	// needs
+	MinSubtotal Money `json:"min_subtotal" db:"min_subtotal"`
+	// MaxRedemptions and MaxRedemptionsPerCustomer are unlimited when 0
+	MaxRedemptions            int        `json:"max_redemptions" db:"max_redemptions"`
+	MaxRedemptionsPerCustomer int        `json:"max_redemptions_per_customer" db:"max_redemptions_per_customer"`
+	StartsAt                  *time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt                    *time.Time `json:"ends_at" db:"ends_at"`
+	IsActive                  bool       `json:"is_active" db:"is_active"`
+	// Redemptions counts the orders that got the promotion, except
+	// cancelled ones
+	Redemptions int       `json:"redemptions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// covers reports whether the promotion discounts menu item m
+func (p Promotion) covers(m MenuItem) bool {
+	if p.MenuItemID != nil && *p.MenuItemID != m.ID {
+		return false
+	}
+	return p.Category == "" || strings.EqualFold(p.Category, m.Category)
+}
+
+// discountOn returns the discount the promotion gives on the items of req,
+// priced at prices, ignoring its conditions
+func (p Promotion) discountOn(req CreateOrderRequest, menuItems map[int]MenuItem, prices []Money) Money {
+	type line struct {
+		price    Money
+		quantity int
+	}
+	var lines []line
+	var total Money
+	units := 0
+	for i, item := range req.Items {
+		m, ok := menuItems[item.MenuItemID]
+		if !ok || !p.covers(m) {
+			continue
+		}
+		lines = append(lines, line{prices[i], item.Quantity})
+		total += prices[i].Mul(item.Quantity)
+		units += item.Quantity
+	}
+
+	switch p.Kind {
+	case "percentage":
+		return p.PercentOff.of(total)
+	case "fixed":
+		if p.AmountOff < total {
+			return p.AmountOff
+		}
+		return total
+	case "buy_x_get_y":
+		if p.BuyQuantity+p.GetQuantity <= 0 {
+			return 0
+		}
+		// The discounted units are the cheapest ones
+		free := units / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].price < lines[j].price })
+		var discounted Money
+		for _, l := range lines {
+			n := l.quantity
+			if n > free {
+				n = free
+			}
+			discounted += l.price.Mul(n)
+			free -= n
+		}
+		return p.PercentOff.of(discounted)
+	}
+	return 0
+}
+
+// promotionUsage counts the redemptions of a promotion: all of them and
+// those of the ordering customer
+type promotionUsage struct {
+	Total    int
+	Customer int
+}
+
+// orderPromotions are the promotions an order may get, as loaded by a store
+// for its restaurant
+type orderPromotions struct {
+	// Now decides whether the promotions have started or ended
+	Now time.Time
+	// Currency is the currency of the order
+	Currency string
+	// Candidates are the active automatic promotions of the restaurant and
+	// the active promotion matching the order's code, if any
+	Candidates []Promotion
+	// Usage counts the redemptions of each candidate by ID
+	Usage map[int]promotionUsage
+}
+
+// apply returns the discounts the order gets on subtotal and, when the
+// promotion named by its code cannot apply, why. Automatic promotions are
+// applied first, by ID, and skipped when they do not apply. Each discount is
+// computed on the unit prices of the items, options included; together they
+// never exceed subtotal.
+func (op orderPromotions) apply(req CreateOrderRequest, menuItems map[int]MenuItem, prices []Money, subtotal Money) (DiscountLines, []FieldError) {
+	lines := DiscountLines{}
+	remaining := subtotal
+	add := func(p Promotion, amount Money) {
+		if amount > remaining {
+			amount = remaining
+		}
+		remaining -= amount
+		lines = append(lines, DiscountLine{PromotionID: p.ID, Code: p.Code, Name: p.Name, Amount: amount})
+	}
+
+	var coded *Promotion
+	for i, p := range op.Candidates {
+		if p.Code != "" {
+			if req.PromotionCode != "" && strings.EqualFold(p.Code, req.PromotionCode) {
+				coded = &op.Candidates[i]
+			}
+			continue
+		}
+		if amount, problem := op.discount(p, req, menuItems, prices, subtotal); problem == nil {
+			add(p, amount)
+		}
+	}
+
+	if req.PromotionCode == "" {
+		return lines, nil
+	}
+	if coded == nil {
+		return lines, []FieldError{promotionProblem("exists", "promotion_code does not match an active promotion")}
+	}
+	amount, problem := op.discount(*coded, req, menuItems, prices, subtotal)
+	if problem != nil {
+		return lines, []FieldError{*problem}
+	}
+	add(*coded, amount)
+	return lines, nil
+}
+
+// discount returns the discount p gives on the order, or why it does not
+// apply
+func (op orderPromotions) discount(p Promotion, req CreateOrderRequest, menuItems map[int]MenuItem, prices []Money, subtotal Money) (Money, *FieldError) {
+	problem := func(rule, format string, args ...interface{}) (Money, *FieldError) {
+		fe := promotionProblem(rule, fmt.Sprintf(format, args...))
+		return 0, &fe
+	}
+	usage := op.Usage[p.ID]
+	switch {
+	case p.RestaurantID != nil && *p.RestaurantID != req.RestaurantID:
+		return problem("restaurant", "promotion_code is not valid at restaurant %d", req.RestaurantID)
+	case p.Currency != op.Currency:
+		return problem("currency", "promotion_code is only valid on orders in %s", p.Currency)
+	case p.StartsAt != nil && op.Now.Before(*p.StartsAt):
+		return problem("not_started", "promotion_code is not valid before %s", p.StartsAt.UTC().Format(time.RFC3339))
+	case p.EndsAt != nil && !op.Now.Before(*p.EndsAt):
+		return problem("expired", "promotion_code expired at %s", p.EndsAt.UTC().Format(time.RFC3339))
+	case subtotal < p.MinSubtotal:
+		return problem("min_subtotal", "promotion_code needs a subtotal of at least %s", p.MinSubtotal)
+	case p.MaxRedemptions > 0 && usage.Total >= p.MaxRedemptions:
+		return problem("usage_limit", "promotion_code has been redeemed the maximum number of times")
+	case p.MaxRedemptionsPerCustomer > 0 && req.CustomerID != 0 && usage.Customer >= p.MaxRedemptionsPerCustomer:
+		return problem("customer_usage_limit", "promotion_code has already been redeemed the maximum number of times by customer %d", req.CustomerID)
+	}
+	amount := p.discountOn(req, menuItems, prices)
+	if amount <= 0 {
+		return problem("applicable", "promotion_code does not apply to any item of the order")
+	}
+	return amount, nil
+}
+
+func promotionProblem(rule, message string) FieldError {
+	return FieldError{Field: "promotion_code", Rule: rule, Message: message}
+}
+
+// PromotionRequest for creating and updating promotions
+type PromotionRequest struct {
+	Code         string `json:"code" validate:"max=50"`
+	Name         string `json:"name" validate:"required,max=255"`
+	Description  string `json:"description"`
+	RestaurantID *int   `json:"restaurant_id" validate:"omitempty,gt=0"`
+	// Currency defaults to the restaurant's, or USD for promotions of every
+	// restaurant
+	Currency    string  `json:"currency" validate:"omitempty,iso4217"`
+	Kind        string  `json:"kind" validate:"required,oneof=percentage fixed buy_x_get_y"`
+	PercentOff  Percent `json:"percent_off" validate:"gte=0,lte=100"`
+	AmountOff   Money   `json:"amount_off" validate:"gte=0,max=99999999.99"`
+	MenuItemID  *int    `json:"menu_item_id" validate:"omitempty,gt=0"`
+	Category    string  `json:"category" validate:"max=100"`
+	BuyQuantity int     `json:"buy_quantity" validate:"gte=0,max=1000"`
+	GetQuantity int     `json:"get_quantity" validate:"gte=0,max=1000"`
+	MinSubtotal Money   `json:"min_subtotal" validate:"gte=0,max=99999999.99"`
+	// MaxRedemptions and MaxRedemptionsPerCustomer are unlimited when 0
+	MaxRedemptions            int        `json:"max_redemptions" validate:"gte=0"`
+	MaxRedemptionsPerCustomer int        `json:"max_redemptions_per_customer" validate:"gte=0"`
+	StartsAt                  *time.Time `json:"starts_at"`
+	EndsAt                    *time.Time `json:"ends_at"`
+	IsActive                  bool       `json:"is_active"`
+}
+
+// check returns the problems the validate tags cannot express: the
+// settings each kind needs and the order of the validity window
+func (r PromotionRequest) check() []FieldError {
+	var fields []FieldError
+	need := func(field, rule, message string) {
+		fields = append(fields, FieldError{Field: field, Rule: rule, Message: message})
+	}
+	switch r.Kind {
+	case "percentage":
+		if r.PercentOff <= 0 {
+			need("percent_off", "required", "percent_off is required for percentage promotions")
+		}
+		if r.AmountOff != 0 {
+			need("amount_off", "excluded", "amount_off is not allowed for percentage promotions")
+		}
+	case "fixed":
+		if r.AmountOff <= 0 {
+			need("amount_off", "required", "amount_off is required for fixed promotions")
+		}
+		if r.PercentOff != 0 {
+			need("percent_off", "excluded", "percent_off is not allowed for fixed promotions")
+		}
+	case "buy_x_get_y":
+		if r.BuyQuantity <= 0 {
+			need("buy_quantity", "required", "buy_quantity is required for buy_x_get_y promotions")
+		}
+		if r.GetQuantity <= 0 {
+			need("get_quantity", "required", "get_quantity is required for buy_x_get_y promotions")
+		}
+		if r.PercentOff <= 0 {
+			need("percent_off", "required", "percent_off is required for buy_x_get_y promotions; use 100 for free items")
+		}
+		if r.AmountOff != 0 {
+			need("amount_off", "excluded", "amount_off is not allowed for buy_x_get_y promotions")
+		}
+	}
+	if r.Kind != "buy_x_get_y" && (r.BuyQuantity != 0 || r.GetQuantity != 0) {
+		need("buy_quantity", "excluded", "buy_quantity and get_quantity are only allowed for buy_x_get_y promotions")
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		need("ends_at", "gtfield", "ends_at must be after starts_at")
+	}
+	return fields
+}
+
+// DiscountLine is the amount one promotion took off an order
+type DiscountLine struct {
+	PromotionID int    `json:"promotion_id"`
+	Code        string `json:"code,omitempty"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"`
+}
+
+// DiscountLines is stored as a JSON array in orders.discount_lines
+type DiscountLines []DiscountLine
+
+// Scan implements sql.Scanner
+func (d *DiscountLines) Scan(src interface{}) error {
+	lines := DiscountLines{}
+	if err := scanJSONArray(src, &lines); err != nil {
+		return err
+	}
+	*d = lines
+	return nil
+}
+
+// Value implements driver.Valuer
+func (d DiscountLines) Value() (driver.Value, error) {
+	if d == nil {
+		d = DiscountLines{}
+	}
+	data, err := json.Marshal(d)
+	return string(data), err
+}
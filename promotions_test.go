@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPromotionDiscounts(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+	pizza := 1
+	menu := map[int]MenuItem{
+		1: {ID: 1, RestaurantID: 1, Price: 1000, Category: "Mains", IsAvailable: true},
+		2: {ID: 2, RestaurantID: 1, Price: 800, Category: "Mains", IsAvailable: true},
+		3: {ID: 3, RestaurantID: 1, Price: 250, Category: "Drinks", IsAvailable: true},
+	}
+	// 2 × 10.00 + 3 × 8.00 + 4 × 2.50 = 54.00
+	items := []CreateOrderItemRequest{{MenuItemID: 1, Quantity: 2}, {MenuItemID: 2, Quantity: 3}, {MenuItemID: 3, Quantity: 4}}
+
+	tests := []struct {
+		name      string
+		promotion Promotion
+		code      string
+		want      Money
+		rule      string
+	}{
+		{name: "percentage", promotion: Promotion{Kind: "percentage", PercentOff: 10000}, want: 540},
+		{name: "category", promotion: Promotion{Kind: "percentage", PercentOff: 50000, Category: "drinks"}, want: 500},
+		{name: "fixed", promotion: Promotion{Kind: "fixed", AmountOff: 700, MenuItemID: &pizza}, want: 700},
+		{name: "fixed capped", promotion: Promotion{Kind: "fixed", AmountOff: 9000}, want: 5400},
+		// 5 mains: one of every 2 + 1 free, the cheapest first
+		{name: "buy 2 get 1", promotion: Promotion{Kind: "buy_x_get_y", BuyQuantity: 2, GetQuantity: 1, PercentOff: 100000, Category: "Mains"}, want: 800},
+		{name: "buy 1 get 1 half price", promotion: Promotion{Kind: "buy_x_get_y", BuyQuantity: 1, GetQuantity: 1, PercentOff: 50000, Category: "Mains"}, want: 800},
+		{name: "min subtotal", promotion: Promotion{Kind: "fixed", AmountOff: 500, MinSubtotal: 5400}, want: 500},
+		{name: "min subtotal not met", promotion: Promotion{Kind: "fixed", AmountOff: 500, MinSubtotal: 5401}, code: "SAVE", rule: "min_subtotal"},
+		{name: "not started", promotion: Promotion{Kind: "fixed", AmountOff: 500, StartsAt: &tomorrow}, code: "SAVE", rule: "not_started"},
+		{name: "expired", promotion: Promotion{Kind: "fixed", AmountOff: 500, EndsAt: &yesterday}, code: "SAVE", rule: "expired"},
+		{name: "window", promotion: Promotion{Kind: "fixed", AmountOff: 500, StartsAt: &yesterday, EndsAt: &tomorrow}, code: "save", want: 500},
+		{name: "other currency", promotion: Promotion{Kind: "fixed", AmountOff: 500, Currency: "USD"}, code: "SAVE", rule: "currency"},
+		{name: "no eligible item", promotion: Promotion{Kind: "percentage", PercentOff: 10000, Category: "Desserts"}, code: "SAVE", rule: "applicable"},
+		{name: "unknown code", promotion: Promotion{Kind: "fixed", AmountOff: 500}, code: "OTHER", rule: "exists"},
+		{name: "automatic not met", promotion: Promotion{Kind: "fixed", AmountOff: 500, MinSubtotal: 10000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.promotion
+			p.ID, p.IsActive = 1, true
+			if p.Currency == "" {
+				p.Currency = "EUR"
+			}
+			if tt.code != "" {
+				p.Code = "SAVE"
+			}
+			req := CreateOrderRequest{RestaurantID: 1, Items: items, PromotionCode: tt.code}
+			promotions := orderPromotions{Now: now, Currency: "EUR", Candidates: []Promotion{p}}
+
+			_, b, problems := priceOrder(req, menu, RestaurantPricing{RestaurantID: 1}, promotions)
+			if tt.rule != "" {
+				if len(problems) != 1 || problems[0].Field != "promotion_code" || problems[0].Rule != tt.rule {
+					t.Fatalf("got problems %+v, want promotion_code rule %s", problems, tt.rule)
+				}
+				return
+			}
+			if len(problems) > 0 {
+				t.Fatalf("unexpected problems %+v", problems)
+			}
+			if b.Discount != tt.want || b.Total != b.Subtotal-tt.want {
+				t.Errorf("got discount %s, total %s; want discount %s", b.Discount, b.Total, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromotionsStackUpToSubtotal(t *testing.T) {
+	menu := map[int]MenuItem{1: {ID: 1, RestaurantID: 1, Price: 1000, IsAvailable: true}}
+	req := CreateOrderRequest{RestaurantID: 1, Items: []CreateOrderItemRequest{{MenuItemID: 1, Quantity: 1}}, PromotionCode: "TEN"}
+	promotions := orderPromotions{Currency: "USD", Candidates: []Promotion{
+		{ID: 1, Kind: "percentage", PercentOff: 60000, Currency: "USD", IsActive: true},
+		{ID: 2, Code: "TEN", Kind: "fixed", AmountOff: 1000, Currency: "USD", IsActive: true},
+	}}
+
+	_, b, problems := priceOrder(req, menu, RestaurantPricing{RestaurantID: 1}, promotions)
+	if len(problems) > 0 {
+		t.Fatal(problems)
+	}
+	if b.Discount != 1000 || len(b.Discounts) != 2 || b.Discounts[0].Amount != 600 || b.Discounts[1].Amount != 400 || b.Total != 0 {
+		t.Errorf("got %+v, want 6.00 then 4.00 off 10.00", b)
+	}
+}
+
+// checkPromotionLimits places orders with a limited promotion concurrently
+// and checks that no more get it than its limits allow, and that cancelling
+// an order gives its redemption back
+func checkPromotionLimits(t *testing.T, store Store) {
+	ctx := context.Background()
+	restaurant, err := store.CreateRestaurant(ctx, CreateRestaurantRequest{Name: "Limits"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := store.CreateMenuItem(ctx, CreateMenuItemRequest{RestaurantID: restaurant.ID, Name: "Soup", Price: 500, IsAvailable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var customers []int
+	for _, email := range []string{"limits1@example.com", "limits2@example.com", "limits3@example.com"} {
+		c, err := store.CreateCustomer(ctx, CreateCustomerRequest{Name: "Limits", Email: email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		customers = append(customers, c.ID)
+	}
+	promotion, err := store.CreatePromotion(ctx, PromotionRequest{
+		Code: "LIMITED", Name: "Limited", RestaurantID: &restaurant.ID, Kind: "fixed", AmountOff: 100,
+		MaxRedemptions: 4, MaxRedemptionsPerCustomer: 2, IsActive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	placed := map[int][]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 24; i++ {
+		customerID := customers[i%len(customers)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := store.CreateOrder(ctx, CreateOrderRequest{
+				CustomerID: customerID, RestaurantID: restaurant.ID, PromotionCode: "limited",
+				Items: []CreateOrderItemRequest{{MenuItemID: item.ID, Quantity: 1}},
+			})
+			var oerr *OrderError
+			if errors.As(err, &oerr) {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			placed[customerID] = append(placed[customerID], order.ID)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for customerID, orders := range placed {
+		if len(orders) > 2 {
+			t.Errorf("customer %d got the promotion %d times, want at most 2", customerID, len(orders))
+		}
+		total += len(orders)
+	}
+	if total != 4 {
+		t.Fatalf("%d orders got the promotion, want 4", total)
+	}
+	if p, err := store.GetPromotion(ctx, promotion.ID); err != nil || p.Redemptions != 4 {
+		t.Fatalf("got %v redemptions (%v), want 4", p, err)
+	}
+
+	// Cancelling one of the orders lets its customer redeem the promotion again
+	var customerID, orderID int
+	for id, orders := range placed {
+		customerID, orderID = id, orders[0]
+		break
+	}
+	if _, err := store.UpdateOrderStatus(ctx, orderID, UpdateOrderStatusRequest{Status: "cancelled"}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := store.CreateOrder(ctx, CreateOrderRequest{
+		CustomerID: customerID, RestaurantID: restaurant.ID, PromotionCode: "LIMITED",
+		Items: []CreateOrderItemRequest{{MenuItemID: item.ID, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Pricing.Discount != 100 || order.TotalAmount != 400 {
+		t.Errorf("got discount %s, total %s; want 1.00, 4.00", order.Pricing.Discount, order.TotalAmount)
+	}
+
+	var cerr *ConstraintError
+	if err := store.DeletePromotion(ctx, promotion.ID); !errors.As(err, &cerr) || cerr.Kind != StillReferenced {
+		t.Errorf("deleting a redeemed promotion: got %v, want StillReferenced", err)
+	}
+}
+
+func TestPromotionLimitsMemory(t *testing.T) {
+	checkPromotionLimits(t, NewMemoryStore())
+}
+
+func TestPromotionLimitsSQLite(t *testing.T) {
+	checkPromotionLimits(t, newCountingStore(t, 0))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestScheduledOrders schedules an order at a restaurant with a 30 minute
+// preparation time and checks its lead time, when it is released to the
+// kitchen and that it is released only once
+func TestScheduledOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		prepTime := 30
+		f := newFixture(t, store, CreateRestaurantRequest{Name: "Scheduled", PrepTimeMinutes: &prepTime})
+		ctx := f.ctx
+		if f.restaurant.PrepTimeMinutes != 30 {
+			t.Errorf("got prep time %d, want 30", f.restaurant.PrepTimeMinutes)
+		}
+		soup := f.item("Soup")
+
+		now := time.Now().Truncate(time.Second)
+		req := f.order(line(soup))
+		soon := now.Add(10 * time.Minute)
+		req.ScheduledFor = &soon
+		if _, err := store.QuoteOrder(ctx, req); !isOrderRule(err, "scheduled_for", "lead_time") {
+			t.Errorf("within the prep time: got %v, want rule lead_time on scheduled_for", err)
+		}
+
+		later := now.Add(2 * time.Hour)
+		req.ScheduledFor = &later
+		order, err := store.CreateOrder(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releaseAt := later.Add(-30 * time.Minute)
+		if order.Status != "scheduled" || order.ReleaseAt == nil || !order.ReleaseAt.Equal(releaseAt) {
+			t.Fatalf("got status %s released at %v, want scheduled until %s", order.Status, order.ReleaseAt, releaseAt)
+		}
+		req.ScheduledFor = nil
+		placed, err := store.CreateOrder(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if placed.Status != "pending" || placed.ReleaseAt != nil {
+			t.Errorf("got status %s released at %v, want an order for now pending", placed.Status, placed.ReleaseAt)
+		}
+
+		// Only the scheduler sends a scheduled order to the kitchen
+		var terr *TransitionError
+		if _, err := store.UpdateOrderStatus(ctx, order.ID, UpdateOrderStatusRequest{Status: "pending"}); !errors.As(err, &terr) {
+			t.Errorf("releasing by hand: got %v, want a *TransitionError", err)
+		}
+		if got := nextOrderStatuses("scheduled"); len(got) != 1 || got[0] != "cancelled" {
+			t.Errorf("got transitions %v from scheduled, want only cancelled", got)
+		}
+
+		if n, err := store.ReleaseScheduledOrders(ctx, now, 10); err != nil || n != 0 {
+			t.Errorf("before the release time: got %d, %v; want nothing released", n, err)
+		}
+		if n, err := store.ReleaseScheduledOrders(ctx, releaseAt, 10); err != nil || n != 1 {
+			t.Fatalf("at the release time: got %d, %v; want the order released", n, err)
+		}
+		if n, err := store.ReleaseScheduledOrders(ctx, releaseAt, 10); err != nil || n != 0 {
+			t.Errorf("again: got %d, %v; want nothing released", n, err)
+		}
+		order, err = store.GetOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Status != "pending" {
+			t.Errorf("got status %s, want pending", order.Status)
+		}
+		events, err := store.ListOrderStatusEvents(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].ToStatus != "scheduled" || events[1].FromStatus != "scheduled" || events[1].Actor != "scheduler" {
+			t.Errorf("got history %+v, want scheduled then released by the scheduler", events)
+		}
+
+		// A cancelled scheduled order is never released
+		req.ScheduledFor = &later
+		cancelled, err := store.CreateOrder(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.UpdateOrderStatus(ctx, cancelled.ID, UpdateOrderStatusRequest{Status: "cancelled"}); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := store.ReleaseScheduledOrders(ctx, later, 10); err != nil || n != 0 {
+			t.Errorf("cancelled: got %d, %v; want nothing released", n, err)
+		}
+	})
+}
+
+func TestOrderSchedulerBatches(t *testing.T) {
+	store := NewMemoryStore()
+	f := newFixture(t, store, CreateRestaurantRequest{Name: "Batches"})
+	ctx := f.ctx
+	req := f.order(line(f.item("Soup")))
+	later := time.Now().Add(time.Hour)
+	req.ScheduledFor = &later
+	for i := 0; i < 5; i++ {
+		if _, err := store.CreateOrder(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scheduler := NewOrderScheduler(store, SchedulerConfig{Interval: time.Minute, BatchSize: 2})
+	scheduler.now = func() time.Time { return later }
+	if n, err := scheduler.ReleaseDue(ctx); err != nil || n != 5 {
+		t.Errorf("got %d, %v; want all 5 orders released in batches", n, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// OrderScheduler releases scheduled orders to the kitchen once their
+// release time, the time they are scheduled for minus the restaurant's
+// preparation time, has come. Its state is the orders themselves, so it
+// picks up where it left off after a restart, and several API replicas can
+// run it at once: the store releases each order only once.
+type OrderScheduler struct {
+	store OrderStore
+	cfg   SchedulerConfig
+	now   func() time.Time
+}
+
+// NewOrderScheduler creates a scheduler releasing the orders of store
+func NewOrderScheduler(store OrderStore, cfg SchedulerConfig) *OrderScheduler {
+	return &OrderScheduler{store: store, cfg: cfg, now: time.Now}
+}
+
+// Run releases due orders straight away, to catch up on the ones that came
+// due while no instance was running, then every interval until ctx is done
+func (s *OrderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		released, err := s.ReleaseDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to release scheduled orders: %v", err)
+		}
+		if released > 0 {
+			log.Printf("Released %d scheduled orders to the kitchen", released)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReleaseDue releases every order due now, one batch per transaction, and
+// returns how many it released
+func (s *OrderScheduler) ReleaseDue(ctx context.Context) (int, error) {
+	total := 0
+	now := s.now()
+	for {
+		released, err := s.store.ReleaseScheduledOrders(ctx, now, s.cfg.BatchSize)
+		total += released
+		if err != nil || released < s.cfg.BatchSize {
+			return total, err
+		}
+	}
+}
+
+// releaseEvent returns the history event of an order released by the
+// scheduler
+func releaseEvent(orderID int) OrderStatusEvent {
+	return OrderStatusEvent{
+		OrderID:    orderID,
+		FromStatus: "scheduled",
+		ToStatus:   "pending",
+		Actor:      "scheduler",
+		Reason:     "Released to the kitchen",
+	}
+}
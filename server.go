@@ -1,40 +1,186 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRestaurantNaja(db *Database) *RestaurantHandler {
-	return &RestaurantHandler{db: db}
+func NewRestaurantNaja(store RestaurantStore, pager Paginator) *RestaurantHandler {
+	return &RestaurantHandler{store: store, pager: pager}
+}
+
+// openDatabase connects to the SQL database selected in the configuration
+func openDatabase(cfg *Config) (*Database, error) {
+	switch cfg.Store {
+	case "sqlite":
+		return NewSQLiteDatabase(cfg.SQLite)
+	case "mysql":
+		return NewDatabase(cfg.Database)
+	default:
+		return nil, fmt.Errorf("the %s store has no database", cfg.Store)
+	}
+}
+
+// openStore creates the storage backend selected in the configuration and
+// returns a function releasing its resources
+func openStore(cfg *Config) (Store, func() error, error) {
+	if cfg.Store == "memory" {
+		store := NewMemoryStore()
+		if err := seedDemoData(context.Background(), store); err != nil {
+			return nil, nil, err
+		}
+		log.Println("Using in-memory store with demo data; changes are lost on restart")
+		return store, func() error { return nil }, nil
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.MigrateOnStart {
+		migrator, err := NewMigrator(db)
+		if err == nil {
+			err = migrator.Up(context.Background())
+		}
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		log.Println("Database schema is up to date")
+	}
+	return NewSQLStore(db), db.Close, nil
 }
 
 func main() {
-	// Initialize database
-	db, err := NewDatabase()
+	// Load configuration
+	cfg, args, err := LoadConfig(os.Args[1:])
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		log.Fatal(err)
+	}
+
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
-	defer db.Close()
 
+	switch command {
+	case "serve":
+		if len(args) > 0 {
+			log.Fatalf("unexpected argument %q", args[0])
+		}
+		serve(cfg)
+	case "migrate":
+		db, err := openDatabase(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		if err := runMigrateCommand(context.Background(), db, args, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case "seed":
+		if cfg.Store == "memory" {
+			log.Fatal("the memory store is seeded with demo data on startup; use --store=mysql or --store=sqlite")
+		}
+		store, closeStore, err := openStore(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closeStore()
+		if err := runSeedCommand(context.Background(), store, args, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown command %q, run with -h for usage", command)
+	}
+}
+
+// serve runs the API server until it is interrupted
+func serve(cfg *Config) {
+	// Initialize storage
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+	defer closeStore()
+
+	e := newServer(cfg, store)
+
+	// Release scheduled orders in the background until shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var scheduler sync.WaitGroup
+	if cfg.Scheduler.Interval > 0 {
+		scheduler.Add(1)
+		go func() {
+			defer scheduler.Done()
+			NewOrderScheduler(store, cfg.Scheduler).Run(ctx)
+		}()
+	}
+
+	// Start server
+	go func() {
+		log.Printf("Starting server on %s", cfg.Server.Addr)
+		if err := e.Start(cfg.Server.Addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// Wait for an interrupt, then let in-flight requests and the scheduler
+	// finish
+	<-ctx.Done()
+
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		e.Logger.Error(err)
+	}
+	scheduler.Wait()
+}
+
+// newServer creates the Echo instance serving the API from store
+func newServer(cfg *Config, store Store) *echo.Echo {
 	// Initialize Echo
 	e := echo.New()
+	e.HideBanner = true
+	e.Validator = NewRequestValidator()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(e)
+	e.Logger.SetLevel(logLevels[strings.ToLower(cfg.LogLevel)])
+	e.Server.ReadTimeout = cfg.Server.ReadTimeout
+	e.Server.WriteTimeout = cfg.Server.WriteTimeout
 
 	// Middleware
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: cfg.CORS.AllowOrigins,
+	}))
 
 	// Initialize handlers
-	restaurantHandler := NewRestaurantNaja(db)
-	menuItemHandler := NewMenuItemHandler(db)
-	customerHandler := NewCustomerHandler(db)
-	orderHandler := NewOrderHandler(db)
-
-	
+	pager := NewPaginator(cfg.Pagination)
+	restaurantHandler := NewRestaurantNaja(store, pager)
+	menuItemHandler := NewMenuItemHandler(store, pager)
+	categoryHandler := NewCategoryHandler(store, pager)
+	customerHandler := NewCustomerHandler(store, pager)
+	orderHandler := NewOrderHandler(store, pager)
+	promotionHandler := NewPromotionHandler(store, pager)
 
 	// Root endpoint
 	e.GET("/", func(c echo.Context) error {
@@ -54,6 +200,12 @@ func main() {
 	restaurants.GET("/:id", restaurantHandler.GetRestaurant)
 	restaurants.PUT("/:id", restaurantHandler.UpdateRestaurant)
 	restaurants.DELETE("/:id", restaurantHandler.DeleteRestaurant)
+	restaurants.GET("/:id/pricing", restaurantHandler.GetRestaurantPricing)
+	restaurants.PUT("/:id/pricing", restaurantHandler.UpdateRestaurantPricing)
+	restaurants.GET("/:id/menu", categoryHandler.GetRestaurantMenu)
+	restaurants.GET("/:id/hours", restaurantHandler.GetOpeningHours)
+	restaurants.PUT("/:id/hours", restaurantHandler.UpdateOpeningHours)
+	restaurants.PUT("/:id/ordering", restaurantHandler.UpdateOrdering)
 
 	// Menu item routes
 	menuItems := v1.Group("/menu-items")
@@ -62,6 +214,18 @@ func main() {
 	menuItems.GET("/:id", menuItemHandler.GetMenuItem)
 	menuItems.PUT("/:id", menuItemHandler.UpdateMenuItem)
 	menuItems.DELETE("/:id", menuItemHandler.DeleteMenuItem)
+	menuItems.PUT("/:id/option-groups", menuItemHandler.UpdateMenuItemOptions)
+	menuItems.PUT("/:id/bundle-slots", menuItemHandler.UpdateBundleSlots)
+	menuItems.PUT("/:id/availability", menuItemHandler.UpdateMenuItemAvailability)
+
+	// Category routes
+	categories := v1.Group("/categories")
+	categories.POST("", categoryHandler.CreateCategory)
+	categories.GET("", categoryHandler.GetCategories)
+	categories.GET("/:id", categoryHandler.GetCategory)
+	categories.PUT("/:id", categoryHandler.UpdateCategory)
+	categories.DELETE("/:id", categoryHandler.DeleteCategory)
+	categories.PUT("/:id/availability", categoryHandler.UpdateCategoryAvailability)
 
 	// Customer routes
 	customers := v1.Group("/customers")
@@ -73,18 +237,27 @@ func main() {
 
 	// Order routes
 	orders := v1.Group("/orders")
-	orders.POST("", orderHandler.CreateOrder)
-	orders.GET("", orderHandler.GetOrders) // Supports ?customer_id=X and ?restaurant_id=X filters
+	orders.POST("", orderHandler.CreateOrder, Idempotency(store, cfg.Idempotency))
+	orders.POST("/quote", orderHandler.QuoteOrder)
+	orders.GET("", orderHandler.GetOrders) // Supports filters, sort and include
 	orders.GET("/:id", orderHandler.GetOrder)
+	orders.GET("/:id/transitions", orderHandler.GetOrderTransitions)
+	orders.GET("/:id/history", orderHandler.GetOrderHistory)
 	orders.PATCH("/:id/status", orderHandler.UpdateOrderStatus)
 	orders.DELETE("/:id", orderHandler.DeleteOrder)
 
+	// Promotion routes
+	promotions := v1.Group("/promotions")
+	promotions.POST("", promotionHandler.CreatePromotion)
+	promotions.GET("", promotionHandler.GetPromotions)
+	promotions.GET("/:id", promotionHandler.GetPromotion)
+	promotions.PUT("/:id", promotionHandler.UpdatePromotion)
+	promotions.DELETE("/:id", promotionHandler.DeletePromotion)
+
 	// Health check
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
 	})
 
-	// Start server
-	log.Println("Starting server on :3644")
-	e.Logger.Fatal(e.Start(":3644"))
+	return e
 }
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by stores when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ConstraintKind says which kind of constraint a write violated
+type ConstraintKind int
+
+const (
+	// UniqueViolation means Column already holds the value in another row
+	UniqueViolation ConstraintKind = iota + 1
+	// MissingReference means Column refers to a row of RefTable that does
+	// not exist
+	MissingReference
+	// StillReferenced means the row cannot be deleted because rows of
+	// RefTable refer to it
+	StillReferenced
+	// CheckViolation means a value is outside the range allowed by the schema
+	CheckViolation
+)
+
+// ConstraintError is returned by stores when a write violates a schema
+// constraint. Table and Column use the names from the schema, which match
+// the JSON field names of the API. Column or RefTable is empty when the
+// database did not say which one was involved.
+type ConstraintError struct {
+	Kind     ConstraintKind
+	Table    string
+	Column   string
+	RefTable string
+	Err      error
+}
+
+func (e *ConstraintError) Error() string {
+	var msg string
+	switch e.Kind {
+	case UniqueViolation:
+		msg = fmt.Sprintf("duplicate value for %s.%s", e.Table, e.Column)
+	case MissingReference:
+		msg = fmt.Sprintf("%s.%s refers to a missing %s row", e.Table, e.Column, e.RefTable)
+	case StillReferenced:
+		msg = fmt.Sprintf("%s row is still referenced by %s", e.Table, e.RefTable)
+	default:
+		msg = fmt.Sprintf("check constraint failed on %s", e.Table)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// TransitionError is returned when an order cannot move from its current
+// status to the requested one
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+// OrderError is returned when an order cannot be placed as requested. Each
+// field error names the request field at fault, e.g. items[1].menu_item_id.
+type OrderError struct {
+	Fields []FieldError
+}
+
+func (e *OrderError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return "invalid order: " + strings.Join(messages, "; ")
+}
+
+// CategoryError is returned when a category or a menu item refers to a
+// category it cannot use, such as one of another restaurant. Each field
+// error names the request field at fault.
+type CategoryError struct {
+	Fields []FieldError
+}
+
+func (e *CategoryError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return "invalid category: " + strings.Join(messages, "; ")
+}
+
+// BundleError is returned when the slots of a bundle cannot be set as
+// requested. Each field error names the request field at fault, e.g.
+// slots[0].items[1].menu_item_id.
+type BundleError struct {
+	Fields []FieldError
+}
+
+func (e *BundleError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return "invalid bundle: " + strings.Join(messages, "; ")
+}
+
+// checkOrder returns the problems with placing req, given whether its
+// customer and restaurant exist, the menu items it references by ID, the
+// opening hours of the restaurant, the menu schedules at the time the order
+// is for, the pricing of the restaurant and the promotions the order may
+// get, which are read at the time it is placed. The restaurant must take
+// orders at that time, and every item must exist, be on the menu of the
+// order's restaurant and be available, within its schedules, with valid
+// options and bundle choices, and the order must be priceable.
+func checkOrder(req CreateOrderRequest, customerExists, restaurantExists bool, menuItems map[int]MenuItem, hours OpeningHours, clock menuClock, pricing RestaurantPricing, promotions orderPromotions) *OrderError {
+	var fields []FieldError
+	if !customerExists {
+		fields = append(fields, FieldError{
+			Field:   "customer_id",
+			Rule:    "exists",
+			Message: "customer_id refers to a customer that does not exist",
+		})
+	}
+	if !restaurantExists {
+		fields = append(fields, FieldError{
+			Field:   "restaurant_id",
+			Rule:    "exists",
+			Message: "restaurant_id refers to a restaurant that does not exist",
+		})
+	} else {
+		fields = append(fields, hours.orderProblems(req.ScheduledFor, promotions.Now)...)
+	}
+	for i, item := range req.Items {
+		field := fmt.Sprintf("items[%d].menu_item_id", i)
+		// The order is in the restaurant's currency, so all its items must
+		// come from that menu
+		m, ok := menuItems[item.MenuItemID]
+		switch {
+		case !ok:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "exists",
+				Message: fmt.Sprintf("%s refers to a menu item that does not exist", field),
+			})
+		case restaurantExists && m.RestaurantID != req.RestaurantID:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "same_restaurant",
+				Message: fmt.Sprintf("%s is not on the menu of restaurant %d", field, req.RestaurantID),
+			})
+		case !m.IsAvailable:
+			fields = append(fields, FieldError{
+				Field:   field,
+				Rule:    "available",
+				Message: fmt.Sprintf("%s is not available", field),
+			})
+		case !clock.orderable(m):
+			fields = append(fields, clock.scheduleProblem(field, m))
+		default:
+			problems := append(m.optionProblems(i, item.OptionIDs), m.bundleProblems(i, item.BundleChoices, clock)...)
+			if len(problems) == 0 && m.unitPrice(item) < 0 {
+				problems = append(problems, FieldError{
+					Field:   fmt.Sprintf("items[%d]", i),
+					Rule:    "price",
+					Message: fmt.Sprintf("the options of items[%d] bring its price below zero", i),
+				})
+			}
+			fields = append(fields, problems...)
+		}
+	}
+	if len(fields) > 0 {
+		return &OrderError{Fields: fields}
+	}
+
+	if _, _, problems := priceOrder(req, menuItems, pricing, promotions); len(problems) > 0 {
+		return &OrderError{Fields: problems}
+	}
+	return nil
+}
+
+// RestaurantStore persists restaurants
+type RestaurantStore interface {
+	CreateRestaurant(ctx context.Context, req CreateRestaurantRequest) (*Restaurant, error)
+	ListRestaurants(ctx context.Context, opts ListOptions) ([]Restaurant, PageInfo, error)
+	GetRestaurant(ctx context.Context, id int) (*Restaurant, error)
+	UpdateRestaurant(ctx context.Context, id int, req CreateRestaurantRequest) (*Restaurant, error)
+	DeleteRestaurant(ctx context.Context, id int) error
+	// GetRestaurantPricing returns the pricing of a restaurant, which
+	// charges menu prices only until it is set
+	GetRestaurantPricing(ctx context.Context, restaurantID int) (*RestaurantPricing, error)
+	// UpdateRestaurantPricing replaces the pricing of a restaurant
+	UpdateRestaurantPricing(ctx context.Context, restaurantID int, req RestaurantPricingRequest) (*RestaurantPricing, error)
+	// GetOpeningHours returns the shifts and closures of a restaurant,
+	// ErrNotFound if it does not exist
+	GetOpeningHours(ctx context.Context, restaurantID int) (*OpeningHours, error)
+	// UpdateOpeningHours replaces the shifts and closures of a restaurant
+	UpdateOpeningHours(ctx context.Context, restaurantID int, req OpeningHoursRequest) (*OpeningHours, error)
+	// SetOrderingPaused pauses or resumes ordering at a restaurant
+	SetOrderingPaused(ctx context.Context, restaurantID int, paused bool) (*Restaurant, error)
+}
+
+// MenuItemStore persists menu items
+type MenuItemStore interface {
+	CreateMenuItem(ctx context.Context, req CreateMenuItemRequest) (*MenuItem, error)
+	ListMenuItems(ctx context.Context, opts ListOptions) ([]MenuItem, PageInfo, error)
+	GetMenuItem(ctx context.Context, id int) (*MenuItem, error)
+	UpdateMenuItem(ctx context.Context, id int, req CreateMenuItemRequest) (*MenuItem, error)
+	DeleteMenuItem(ctx context.Context, id int) error
+	// UpdateMenuItemOptions replaces the option groups of a menu item.
+	// Groups and options keep their IDs while their names are unchanged;
+	// orders keep copies of the options they chose.
+	UpdateMenuItemOptions(ctx context.Context, id int, req MenuItemOptionsRequest) (*MenuItem, error)
+	// UpdateBundleSlots replaces the slots of a bundle, keeping the IDs of
+	// the slots whose names are unchanged, and returns a *BundleError if
+	// the slots cannot hold the requested items
+	UpdateBundleSlots(ctx context.Context, id int, req BundleSlotsRequest) (*MenuItem, error)
+	// UpdateMenuItemAvailability replaces the availability schedule of a
+	// menu item
+	UpdateMenuItemAvailability(ctx context.Context, id int, req AvailabilityRequest) (*MenuItem, error)
+}
+
+// CategoryStore persists the menu categories of restaurants. Menu items
+// refer to them by ID and return a *CategoryError when given a category of
+// another restaurant.
+type CategoryStore interface {
+	// CreateCategory and UpdateCategory return a *CategoryError if the
+	// parent is not a category of the same restaurant or, on update, is the
+	// category itself or one of its subcategories
+	CreateCategory(ctx context.Context, req CategoryRequest) (*Category, error)
+	ListCategories(ctx context.Context, opts ListOptions) ([]Category, PageInfo, error)
+	GetCategory(ctx context.Context, id int) (*Category, error)
+	UpdateCategory(ctx context.Context, id int, req CategoryRequest) (*Category, error)
+	// DeleteCategory removes a category. Its subcategories move up to its
+	// parent and its menu items become uncategorized.
+	DeleteCategory(ctx context.Context, id int) error
+	// UpdateCategoryAvailability replaces the availability schedule of a
+	// category, which applies to its subcategories and menu items
+	UpdateCategoryAvailability(ctx context.Context, id int, req AvailabilityRequest) (*Category, error)
+	// GetRestaurantMenu returns every category and menu item of a
+	// restaurant arranged as its menu at time at, leaving out hidden
+	// categories unless includeHidden is set
+	GetRestaurantMenu(ctx context.Context, restaurantID int, includeHidden bool, at time.Time) (*Menu, error)
+}
+
+// CustomerStore persists customers
+type CustomerStore interface {
+	CreateCustomer(ctx context.Context, req CreateCustomerRequest) (*Customer, error)
+	ListCustomers(ctx context.Context, opts ListOptions) ([]Customer, PageInfo, error)
+	GetCustomer(ctx context.Context, id int) (*Customer, error)
+	UpdateCustomer(ctx context.Context, id int, req CreateCustomerRequest) (*Customer, error)
+	DeleteCustomer(ctx context.Context, id int) error
+}
+
+// OrderStore persists orders together with their items
+type OrderStore interface {
+	// CreateOrder checks and prices the requested items, applies the
+	// promotions the order gets and stores it together with their
+	// redemptions atomically, returning an *OrderError if it cannot be
+	// placed. Usage limits hold however many orders are placed at once.
+	CreateOrder(ctx context.Context, req CreateOrderRequest) (*Order, error)
+	// QuoteOrder prices req exactly as CreateOrder would without storing
+	// anything. The customer may be left out.
+	QuoteOrder(ctx context.Context, req CreateOrderRequest) (*OrderQuote, error)
+	ListOrders(ctx context.Context, opts ListOptions) ([]Order, PageInfo, error)
+	GetOrder(ctx context.Context, id int) (*Order, error)
+	// UpdateOrderStatus moves an order to req.Status and records the change
+	// in its history, returning a *TransitionError unless the lifecycle
+	// allows it from the current status
+	UpdateOrderStatus(ctx context.Context, id int, req UpdateOrderStatusRequest) (*Order, error)
+	// ListOrderStatusEvents returns the status history of an order, oldest first
+	ListOrderStatusEvents(ctx context.Context, orderID int) ([]OrderStatusEvent, error)
+	DeleteOrder(ctx context.Context, id int) error
+	// ReleaseScheduledOrders moves up to limit scheduled orders whose
+	// release time is at or before now to pending, earliest first, records
+	// the change in their history and returns how many it moved. Callers
+	// running at the same time never release an order twice.
+	ReleaseScheduledOrders(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// PromotionStore persists promotions. Orders record the promotions they
+// got as redemptions, which count against the promotion's usage limits
+// until the order is cancelled or deleted.
+type PromotionStore interface {
+	CreatePromotion(ctx context.Context, req PromotionRequest) (*Promotion, error)
+	ListPromotions(ctx context.Context, opts ListOptions) ([]Promotion, PageInfo, error)
+	GetPromotion(ctx context.Context, id int) (*Promotion, error)
+	UpdatePromotion(ctx context.Context, id int, req PromotionRequest) (*Promotion, error)
+	// DeletePromotion fails with a StillReferenced *ConstraintError while
+	// orders hold redemptions of the promotion
+	DeletePromotion(ctx context.Context, id int) error
+}
+
+// IdempotencyStore keeps the responses of requests sent with an
+// Idempotency-Key so retries can be answered without running them again
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey stores rec as in progress and returns nil, unless
+	// a record for the same scope and key is still live at rec.CreatedAt,
+	// which is returned instead
+	ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of the request holding the
+	// key of rec and drops expired records. The key must still be held by
+	// the claim of rec, the one with its request hash and creation time;
+	// otherwise it fails with ErrIdempotencyKeyLost.
+	CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error
+	// ReleaseIdempotencyKey forgets a key whose request did not complete, so
+	// it can be retried. Like CompleteIdempotencyKey it fails with
+	// ErrIdempotencyKeyLost unless the key is still held by the claim of rec.
+	ReleaseIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error
+}
+
+// Store bundles every store the API needs
+type Store interface {
+	RestaurantStore
+	MenuItemStore
+	CategoryStore
+	CustomerStore
+	OrderStore
+	PromotionStore
+	IdempotencyStore
+}
@@ -0,0 +1,1486 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store entirely in memory. It mirrors the constraints
+// of the SQL schema in migrations/: auto-increment IDs, a unique customer email
+// and promotion code, ON DELETE CASCADE from restaurants to menu items and
+// promotions, from menu items to option groups and from orders to order
+// items and redemptions, ON DELETE RESTRICT for everything referenced by
+// orders or redemptions, and created_at/updated_at stamping.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	restaurants map[int]Restaurant
+	menuItems   map[int]MenuItem
+	categories  map[int]Category
+	options     map[int][]OptionGroup // by menu item ID, in display order
+	bundleSlots map[int][]BundleSlot  // by bundle ID, in display order
+	// Availability windows by menu item and by category ID
+	itemAvailability     map[int][]AvailabilityWindow
+	categoryAvailability map[int][]AvailabilityWindow
+	customers            map[int]Customer
+	orders               map[int]Order
+	orderItems           map[int]OrderItem
+	orderEvents          map[int]OrderStatusEvent
+	promotions           map[int]Promotion
+	redemptions          map[int]redemption
+	pricing              map[int]RestaurantPricing       // by restaurant ID
+	hours                map[int]OpeningHours            // by restaurant ID
+	idempotency          map[[2]string]IdempotencyRecord // by scope and key
+	lastID               map[string]int
+	now                  func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		restaurants: make(map[int]Restaurant),
+		menuItems:   make(map[int]MenuItem),
+		categories:  make(map[int]Category),
+		options:     make(map[int][]OptionGroup),
+		bundleSlots: make(map[int][]BundleSlot),
+
+		itemAvailability:     make(map[int][]AvailabilityWindow),
+		categoryAvailability: make(map[int][]AvailabilityWindow),
+		customers:            make(map[int]Customer),
+		orders:               make(map[int]Order),
+		orderItems:           make(map[int]OrderItem),
+		orderEvents:          make(map[int]OrderStatusEvent),
+		pricing:              make(map[int]RestaurantPricing),
+		hours:                make(map[int]OpeningHours),
+		promotions:           make(map[int]Promotion),
+		redemptions:          make(map[int]redemption),
+		idempotency:          make(map[[2]string]IdempotencyRecord),
+		lastID:               make(map[string]int),
+		now: func() time.Time {
+			// TIMESTAMP columns only keep whole seconds
+			return time.Now().Truncate(time.Second)
+		},
+	}
+}
+
+// nextID emulates AUTO_INCREMENT; IDs are never reused, even after deletes
+func (s *MemoryStore) nextID(table string) int {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// CreateRestaurant inserts a restaurant and returns the stored row
+func (s *MemoryStore) CreateRestaurant(ctx context.Context, req CreateRestaurantRequest) (*Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if req.Currency == "" {
+		req.Currency = DefaultCurrency
+	}
+	if req.TimeZone == "" {
+		req.TimeZone = DefaultTimeZone
+	}
+	prepTime := DefaultPrepTimeMinutes
+	if req.PrepTimeMinutes != nil {
+		prepTime = *req.PrepTimeMinutes
+	}
+	r := Restaurant{
+		ID:              s.nextID("restaurants"),
+		Name:            req.Name,
+		Address:         req.Address,
+		Phone:           req.Phone,
+		Email:           req.Email,
+		CuisineType:     req.CuisineType,
+		Currency:        req.Currency,
+		TimeZone:        req.TimeZone,
+		PrepTimeMinutes: prepTime,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.restaurants[r.ID] = r
+	r = s.restaurantOf(r)
+	return &r, nil
+}
+
+// ListRestaurants returns a page of restaurants, newest first by default
+func (s *MemoryStore) ListRestaurants(ctx context.Context, opts ListOptions) ([]Restaurant, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restaurants := []Restaurant{}
+	for _, r := range s.restaurants {
+		restaurants = append(restaurants, s.restaurantOf(r))
+	}
+	restaurants, info := pageRecords(restaurantListing, restaurants, opts)
+	return restaurants, info, nil
+}
+
+// GetRestaurant returns a restaurant by ID
+func (s *MemoryStore) GetRestaurant(ctx context.Context, id int) (*Restaurant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.restaurants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r = s.restaurantOf(r)
+	return &r, nil
+}
+
+// UpdateRestaurant overwrites a restaurant and returns the stored row
+func (s *MemoryStore) UpdateRestaurant(ctx context.Context, id int, req CreateRestaurantRequest) (*Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.restaurants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	updated := r
+	updated.Name = req.Name
+	updated.Address = req.Address
+	updated.Phone = req.Phone
+	updated.Email = req.Email
+	updated.CuisineType = req.CuisineType
+	if req.Currency != "" {
+		updated.Currency = req.Currency
+	}
+	if req.TimeZone != "" {
+		updated.TimeZone = req.TimeZone
+	}
+	if req.PrepTimeMinutes != nil {
+		updated.PrepTimeMinutes = *req.PrepTimeMinutes
+	}
+	if updated != r {
+		updated.UpdatedAt = s.now()
+	}
+	s.restaurants[id] = updated
+	updated = s.restaurantOf(updated)
+	return &updated, nil
+}
+
+// DeleteRestaurant removes a restaurant and cascades to its menu items,
+// categories and promotions
+func (s *MemoryStore) DeleteRestaurant(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.restaurants[id]; !ok {
+		return ErrNotFound
+	}
+	for _, o := range s.orders {
+		if o.RestaurantID == id {
+			return &ConstraintError{Kind: StillReferenced, Table: "restaurants", RefTable: "orders"}
+		}
+	}
+
+	var cascaded []int
+	for _, m := range s.menuItems {
+		if m.RestaurantID == id {
+			if s.menuItemReferenced(m.ID) {
+				return &ConstraintError{Kind: StillReferenced, Table: "restaurants", RefTable: "order_items"}
+			}
+			cascaded = append(cascaded, m.ID)
+		}
+	}
+
+	for _, menuItemID := range cascaded {
+		s.deleteMenuItem(menuItemID)
+	}
+	for categoryID, c := range s.categories {
+		if c.RestaurantID == id {
+			delete(s.categories, categoryID)
+			delete(s.categoryAvailability, categoryID)
+		}
+	}
+	for promotionID, p := range s.promotions {
+		if p.RestaurantID != nil && *p.RestaurantID == id {
+			delete(s.promotions, promotionID)
+		}
+	}
+	delete(s.restaurants, id)
+	delete(s.pricing, id)
+	delete(s.hours, id)
+	return nil
+}
+
+// GetRestaurantPricing returns the pricing of a restaurant
+func (s *MemoryStore) GetRestaurantPricing(ctx context.Context, restaurantID int) (*RestaurantPricing, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.restaurants[restaurantID]; !ok {
+		return nil, ErrNotFound
+	}
+	pricing := s.pricingOf(restaurantID)
+	return &pricing, nil
+}
+
+// pricingOf returns a copy of the pricing of a restaurant
+func (s *MemoryStore) pricingOf(restaurantID int) RestaurantPricing {
+	pricing, ok := s.pricing[restaurantID]
+	if !ok {
+		pricing = RestaurantPricing{RestaurantID: restaurantID}
+	}
+	pricing.Taxes = append([]TaxRate{}, pricing.Taxes...)
+	pricing.DeliveryFeeBands = append([]DeliveryFeeBand{}, pricing.DeliveryFeeBands...)
+	return pricing
+}
+
+// UpdateRestaurantPricing replaces the pricing of a restaurant
+func (s *MemoryStore) UpdateRestaurantPricing(ctx context.Context, restaurantID int, req RestaurantPricingRequest) (*RestaurantPricing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.restaurants[restaurantID]; !ok {
+		return nil, ErrNotFound
+	}
+	pricing := RestaurantPricing{
+		RestaurantID:      restaurantID,
+		PricesIncludeTax:  req.PricesIncludeTax,
+		Taxes:             append([]TaxRate{}, req.Taxes...),
+		ServiceChargeRate: req.ServiceChargeRate,
+		DeliveryFeeBands:  append([]DeliveryFeeBand{}, req.DeliveryFeeBands...),
+	}
+	sortDeliveryFeeBands(pricing.DeliveryFeeBands)
+	s.pricing[restaurantID] = pricing
+	result := s.pricingOf(restaurantID)
+	return &result, nil
+}
+
+// restaurantOf returns r with its opening status computed now
+func (s *MemoryStore) restaurantOf(r Restaurant) Restaurant {
+	r.setOpenStatus(s.hoursOf(r), s.now())
+	return r
+}
+
+// hoursOf returns a copy of the opening hours of restaurant r
+func (s *MemoryStore) hoursOf(r Restaurant) OpeningHours {
+	hours := s.hours[r.ID]
+	return OpeningHours{
+		RestaurantID:    r.ID,
+		TimeZone:        r.TimeZone,
+		Shifts:          append([]OpeningShift{}, hours.Shifts...),
+		Closures:        append([]Closure{}, hours.Closures...),
+		OrderingPaused:  r.OrderingPaused,
+		PrepTimeMinutes: r.PrepTimeMinutes,
+	}
+}
+
+// GetOpeningHours returns the shifts and closures of a restaurant
+func (s *MemoryStore) GetOpeningHours(ctx context.Context, restaurantID int) (*OpeningHours, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	hours := s.hoursOf(r)
+	return &hours, nil
+}
+
+// UpdateOpeningHours replaces the shifts and closures of a restaurant
+func (s *MemoryStore) UpdateOpeningHours(ctx context.Context, restaurantID int, req OpeningHoursRequest) (*OpeningHours, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	shifts, closures := req.hours()
+	s.hours[restaurantID] = OpeningHours{Shifts: shifts, Closures: closures}
+	hours := s.hoursOf(r)
+	return &hours, nil
+}
+
+// SetOrderingPaused pauses or resumes ordering at a restaurant
+func (s *MemoryStore) SetOrderingPaused(ctx context.Context, restaurantID int, paused bool) (*Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if r.OrderingPaused != paused {
+		r.OrderingPaused = paused
+		r.UpdatedAt = s.now()
+		s.restaurants[restaurantID] = r
+	}
+	r = s.restaurantOf(r)
+	return &r, nil
+}
+
+// menuItemReferenced reports whether any order item points at the menu item
+func (s *MemoryStore) menuItemReferenced(menuItemID int) bool {
+	for _, item := range s.orderItems {
+		if item.MenuItemID == menuItemID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateMenuItem inserts a menu item and returns the stored row
+func (s *MemoryStore) CreateMenuItem(ctx context.Context, req CreateMenuItemRequest) (*MenuItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.restaurants[req.RestaurantID]; !ok {
+		return nil, &ConstraintError{Kind: MissingReference, Table: "menu_items", Column: "restaurant_id", RefTable: "restaurants"}
+	}
+
+	categoryID, category, err := s.menuItemCategory(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if req.Kind == "" {
+		req.Kind = "item"
+	}
+	m := MenuItem{
+		ID:           s.nextID("menu_items"),
+		RestaurantID: req.RestaurantID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Price:        req.Price,
+		CategoryID:   categoryID,
+		Category:     category,
+		Kind:         req.Kind,
+		IsAvailable:  req.IsAvailable,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.menuItems[m.ID] = m
+	return &m, nil
+}
+
+// ListMenuItems returns a page of menu items, ordered by restaurant,
+// category and name by default
+func (s *MemoryStore) ListMenuItems(ctx context.Context, opts ListOptions) ([]MenuItem, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	menuItems := []MenuItem{}
+	for _, m := range s.menuItems {
+		menuItems = append(menuItems, m)
+	}
+	menuItems, info := pageRecords(menuItemListing, menuItems, opts)
+	for i := range menuItems {
+		menuItems[i] = s.menuItemOf(menuItems[i])
+	}
+	return menuItems, info, nil
+}
+
+// GetMenuItem returns a menu item by ID
+func (s *MemoryStore) GetMenuItem(ctx context.Context, id int) (*MenuItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.menuItems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	m = s.menuItemOf(m)
+	return &m, nil
+}
+
+// menuItemCategory returns the category ID and name to store with a menu
+// item written with req. A category named by req.Category alone is
+// created if the restaurant has none by that name.
+func (s *MemoryStore) menuItemCategory(req CreateMenuItemRequest) (*int, string, error) {
+	if req.CategoryID != nil {
+		c, ok := s.categories[*req.CategoryID]
+		if !ok {
+			return nil, "", &ConstraintError{Kind: MissingReference, Table: "menu_items", Column: "category_id", RefTable: "categories"}
+		}
+		if fields := categoryOfMenuItem(req.RestaurantID, c); len(fields) > 0 {
+			return nil, "", &CategoryError{Fields: fields}
+		}
+		return &c.ID, c.Name, nil
+	}
+
+	name := strings.TrimSpace(req.Category)
+	if name == "" {
+		return nil, "", nil
+	}
+	categories := s.restaurantCategories(req.RestaurantID)
+	if c := findCategory(categories, name); c != nil {
+		return &c.ID, c.Name, nil
+	}
+	now := s.now()
+	c := Category{
+		ID:           s.nextID("categories"),
+		RestaurantID: req.RestaurantID,
+		Name:         name,
+		Position:     nextCategoryPosition(categories),
+		IsVisible:    true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.categories[c.ID] = c
+	return &c.ID, c.Name, nil
+}
+
+// menuItemOf returns m with its option groups and bundle slots. Option
+// groups are replaced rather than modified, so the copy can share them;
+// slots are copied to fill in the current name and availability of their
+// items.
+func (s *MemoryStore) menuItemOf(m MenuItem) MenuItem {
+	m.OptionGroups = s.options[m.ID]
+	m.BundleSlots = nil
+	for _, slot := range s.bundleSlots[m.ID] {
+		slot.Items = append([]BundleSlotItem{}, slot.Items...)
+		for i, item := range slot.Items {
+			component := s.menuItems[item.MenuItemID]
+			slot.Items[i].Name, slot.Items[i].IsAvailable = component.Name, component.IsAvailable
+			slot.Items[i].availability, slot.Items[i].categoryID = s.itemAvailability[component.ID], component.CategoryID
+		}
+		m.BundleSlots = append(m.BundleSlots, slot)
+	}
+	m.Availability = s.itemAvailability[m.ID]
+	return m
+}
+
+// UpdateMenuItem overwrites a menu item and returns the stored row. The
+// kind of a menu item is kept.
+func (s *MemoryStore) UpdateMenuItem(ctx context.Context, id int, req CreateMenuItemRequest) (*MenuItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.menuItems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := s.restaurants[req.RestaurantID]; !ok {
+		return nil, &ConstraintError{Kind: MissingReference, Table: "menu_items", Column: "restaurant_id", RefTable: "restaurants"}
+	}
+
+	categoryID, category, err := s.menuItemCategory(req)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := m
+	updated.RestaurantID = req.RestaurantID
+	updated.Name = req.Name
+	updated.Description = req.Description
+	updated.Price = req.Price
+	updated.CategoryID = categoryID
+	updated.Category = category
+	updated.IsAvailable = req.IsAvailable
+	if !reflect.DeepEqual(updated, m) {
+		updated.UpdatedAt = s.now()
+	}
+	s.menuItems[id] = updated
+	updated = s.menuItemOf(updated)
+	return &updated, nil
+}
+
+// UpdateMenuItemOptions replaces the option groups of a menu item, keeping
+// the IDs of the groups and options matched by name
+func (s *MemoryStore) UpdateMenuItemOptions(ctx context.Context, id int, req MenuItemOptionsRequest) (*MenuItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.menuItems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	existing := s.options[id]
+	groups := make([]OptionGroup, 0, len(req.OptionGroups))
+	kept := make(map[int]bool)
+	for _, g := range req.OptionGroups {
+		group := OptionGroup{MenuItemID: id, Name: g.Name, MinSelections: g.MinSelections, MaxSelections: g.MaxSelections}
+		var options []MenuOption
+		if eg := findOptionGroup(existing, g.Name); eg != nil {
+			group.ID, options = eg.ID, eg.Options
+		} else {
+			group.ID = s.nextID("option_groups")
+		}
+		for _, o := range g.Options {
+			option := MenuOption{OptionGroupID: group.ID, Name: o.Name, PriceDelta: o.PriceDelta, IsAvailable: o.IsAvailable}
+			if eo := findMenuOption(options, o.Name); eo != nil {
+				option.ID = eo.ID
+			} else {
+				option.ID = s.nextID("menu_options")
+			}
+			kept[option.ID] = true
+			group.Options = append(group.Options, option)
+		}
+		groups = append(groups, group)
+	}
+
+	// ON DELETE SET NULL: orders keep their copies of removed options
+	for itemID, item := range s.orderItems {
+		if item.MenuItemID != id {
+			continue
+		}
+		options := append([]OrderItemOption{}, item.Options...)
+		for i, o := range options {
+			if o.OptionID != nil && !kept[*o.OptionID] {
+				options[i].OptionID = nil
+			}
+		}
+		item.Options = options
+		s.orderItems[itemID] = item
+	}
+
+	if len(groups) == 0 {
+		delete(s.options, id)
+	} else {
+		s.options[id] = groups
+	}
+	m = s.menuItemOf(m)
+	return &m, nil
+}
+
+// UpdateBundleSlots replaces the slots of a bundle, keeping the IDs of the
+// slots matched by name
+func (s *MemoryStore) UpdateBundleSlots(ctx context.Context, id int, req BundleSlotsRequest) (*MenuItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.menuItems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if fields := req.problems(m, s.menuItems); len(fields) > 0 {
+		return nil, &BundleError{Fields: fields}
+	}
+
+	existing := s.bundleSlots[id]
+	slots := make([]BundleSlot, 0, len(req.Slots))
+	for _, slot := range req.Slots {
+		bundleSlot := BundleSlot{BundleID: id, Name: slot.Name, Quantity: slot.Quantity}
+		if bundleSlot.Quantity == 0 {
+			bundleSlot.Quantity = 1
+		}
+		if es := findBundleSlot(existing, slot.Name); es != nil {
+			bundleSlot.ID = es.ID
+		} else {
+			bundleSlot.ID = s.nextID("bundle_slots")
+		}
+		for _, item := range slot.Items {
+			bundleSlot.Items = append(bundleSlot.Items, BundleSlotItem{MenuItemID: item.MenuItemID, PriceDelta: item.PriceDelta})
+		}
+		slots = append(slots, bundleSlot)
+	}
+
+	if len(slots) == 0 {
+		delete(s.bundleSlots, id)
+	} else {
+		s.bundleSlots[id] = slots
+	}
+	m = s.menuItemOf(m)
+	return &m, nil
+}
+
+// UpdateMenuItemAvailability replaces the availability windows of a menu
+// item
+func (s *MemoryStore) UpdateMenuItemAvailability(ctx context.Context, id int, req AvailabilityRequest) (*MenuItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.menuItems[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if windows := req.windows(); len(windows) > 0 {
+		s.itemAvailability[id] = windows
+	} else {
+		delete(s.itemAvailability, id)
+	}
+	m = s.menuItemOf(m)
+	return &m, nil
+}
+
+// DeleteMenuItem removes a menu item unless an order item references it,
+// cascading to its promotions
+func (s *MemoryStore) DeleteMenuItem(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.menuItems[id]; !ok {
+		return ErrNotFound
+	}
+	if s.menuItemReferenced(id) {
+		return &ConstraintError{Kind: StillReferenced, Table: "menu_items", RefTable: "order_items"}
+	}
+	s.deleteMenuItem(id)
+	return nil
+}
+
+// deleteMenuItem removes a menu item and cascades to its option groups, its
+// bundle slots, its availability windows, its place in the slots of other
+// bundles and the promotions of it. Promotions of an item no order
+// references have no redemptions.
+func (s *MemoryStore) deleteMenuItem(id int) {
+	delete(s.options, id)
+	delete(s.bundleSlots, id)
+	delete(s.itemAvailability, id)
+	for bundleID, slots := range s.bundleSlots {
+		slots = append([]BundleSlot{}, slots...)
+		for i, slot := range slots {
+			items := []BundleSlotItem{}
+			for _, item := range slot.Items {
+				if item.MenuItemID != id {
+					items = append(items, item)
+				}
+			}
+			slots[i].Items = items
+		}
+		s.bundleSlots[bundleID] = slots
+	}
+	for promotionID, p := range s.promotions {
+		if p.MenuItemID != nil && *p.MenuItemID == id {
+			delete(s.promotions, promotionID)
+		}
+	}
+	delete(s.menuItems, id)
+}
+
+// emailTaken reports whether another customer already uses the email. The
+// column collation is case-insensitive, so the comparison is as well.
+func (s *MemoryStore) emailTaken(email string, exceptID int) bool {
+	for _, c := range s.customers {
+		if c.ID != exceptID && strings.EqualFold(c.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryOf returns c with its availability windows
+func (s *MemoryStore) categoryOf(c Category) Category {
+	c.Availability = s.categoryAvailability[c.ID]
+	return c
+}
+
+// restaurantCategories returns every category of a restaurant with its
+// availability windows
+func (s *MemoryStore) restaurantCategories(restaurantID int) []Category {
+	categories := []Category{}
+	for _, c := range s.categories {
+		if c.RestaurantID == restaurantID {
+			categories = append(categories, s.categoryOf(c))
+		}
+	}
+	return categories
+}
+
+// CreateCategory inserts a category and returns the stored row
+func (s *MemoryStore) CreateCategory(ctx context.Context, req CategoryRequest) (*Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.restaurants[req.RestaurantID]; !ok {
+		return nil, &ConstraintError{Kind: MissingReference, Table: "categories", Column: "restaurant_id", RefTable: "restaurants"}
+	}
+	if fields := req.problems(nil, s.categories); len(fields) > 0 {
+		return nil, &CategoryError{Fields: fields}
+	}
+	if findCategory(s.restaurantCategories(req.RestaurantID), req.Name) != nil {
+		return nil, &ConstraintError{Kind: UniqueViolation, Table: "categories", Column: "name"}
+	}
+
+	now := s.now()
+	c := Category{
+		ID:           s.nextID("categories"),
+		RestaurantID: req.RestaurantID,
+		ParentID:     req.ParentID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Position:     req.Position,
+		IsVisible:    req.IsVisible,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.categories[c.ID] = c
+	return &c, nil
+}
+
+// ListCategories returns a page of categories, in display order by default
+func (s *MemoryStore) ListCategories(ctx context.Context, opts ListOptions) ([]Category, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := []Category{}
+	for _, c := range s.categories {
+		categories = append(categories, s.categoryOf(c))
+	}
+	categories, info := pageRecords(categoryListing, categories, opts)
+	return categories, info, nil
+}
+
+// GetCategory returns a category by ID
+func (s *MemoryStore) GetCategory(ctx context.Context, id int) (*Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c = s.categoryOf(c)
+	return &c, nil
+}
+
+// UpdateCategory overwrites a category and returns the stored row. Its
+// menu items take its new name.
+func (s *MemoryStore) UpdateCategory(ctx context.Context, id int, req CategoryRequest) (*Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if fields := req.problems(&c, s.categories); len(fields) > 0 {
+		return nil, &CategoryError{Fields: fields}
+	}
+	if other := findCategory(s.restaurantCategories(req.RestaurantID), req.Name); other != nil && other.ID != id {
+		return nil, &ConstraintError{Kind: UniqueViolation, Table: "categories", Column: "name"}
+	}
+
+	updated := c
+	updated.ParentID = req.ParentID
+	updated.Name = req.Name
+	updated.Description = req.Description
+	updated.Position = req.Position
+	updated.IsVisible = req.IsVisible
+	if !reflect.DeepEqual(updated, c) {
+		updated.UpdatedAt = s.now()
+	}
+	s.categories[id] = updated
+	if updated.Name != c.Name {
+		for menuItemID, m := range s.menuItems {
+			if m.CategoryID != nil && *m.CategoryID == id {
+				m.Category = updated.Name
+				m.UpdatedAt = s.now()
+				s.menuItems[menuItemID] = m
+			}
+		}
+	}
+	updated = s.categoryOf(updated)
+	return &updated, nil
+}
+
+// DeleteCategory removes a category, moving its subcategories up to its
+// parent and leaving its menu items uncategorized
+func (s *MemoryStore) DeleteCategory(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for childID, child := range s.categories {
+		if child.ParentID != nil && *child.ParentID == id {
+			child.ParentID = c.ParentID
+			s.categories[childID] = child
+		}
+	}
+	for menuItemID, m := range s.menuItems {
+		if m.CategoryID != nil && *m.CategoryID == id {
+			m.CategoryID, m.Category = nil, ""
+			m.UpdatedAt = s.now()
+			s.menuItems[menuItemID] = m
+		}
+	}
+	delete(s.categories, id)
+	delete(s.categoryAvailability, id)
+	return nil
+}
+
+// UpdateCategoryAvailability replaces the availability windows of a
+// category
+func (s *MemoryStore) UpdateCategoryAvailability(ctx context.Context, id int, req AvailabilityRequest) (*Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if windows := req.windows(); len(windows) > 0 {
+		s.categoryAvailability[id] = windows
+	} else {
+		delete(s.categoryAvailability, id)
+	}
+	c = s.categoryOf(c)
+	return &c, nil
+}
+
+// GetRestaurantMenu returns the menu of a restaurant at time at with the
+// option groups, bundle slots and schedules of its items
+func (s *MemoryStore) GetRestaurantMenu(ctx context.Context, restaurantID int, includeHidden bool, at time.Time) (*Menu, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restaurant, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	menuItems := []MenuItem{}
+	for _, m := range s.menuItems {
+		if m.RestaurantID == restaurantID {
+			menuItems = append(menuItems, s.menuItemOf(m))
+		}
+	}
+	return buildMenu(restaurant, s.restaurantCategories(restaurantID), menuItems, includeHidden, at), nil
+}
+
+// CreateCustomer inserts a customer and returns the stored row
+func (s *MemoryStore) CreateCustomer(ctx context.Context, req CreateCustomerRequest) (*Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(req.Email, 0) {
+		return nil, &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email"}
+	}
+
+	now := s.now()
+	customer := Customer{
+		ID:        s.nextID("customers"),
+		Name:      req.Name,
+		Email:     req.Email,
+		Phone:     req.Phone,
+		Address:   req.Address,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.customers[customer.ID] = customer
+	return &customer, nil
+}
+
+// ListCustomers returns a page of customers, newest first by default
+func (s *MemoryStore) ListCustomers(ctx context.Context, opts ListOptions) ([]Customer, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	customers := []Customer{}
+	for _, c := range s.customers {
+		customers = append(customers, c)
+	}
+	customers, info := pageRecords(customerListing, customers, opts)
+	return customers, info, nil
+}
+
+// GetCustomer returns a customer by ID
+func (s *MemoryStore) GetCustomer(ctx context.Context, id int) (*Customer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	customer, ok := s.customers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &customer, nil
+}
+
+// UpdateCustomer overwrites a customer and returns the stored row
+func (s *MemoryStore) UpdateCustomer(ctx context.Context, id int, req CreateCustomerRequest) (*Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	customer, ok := s.customers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if s.emailTaken(req.Email, id) {
+		return nil, &ConstraintError{Kind: UniqueViolation, Table: "customers", Column: "email"}
+	}
+
+	updated := customer
+	updated.Name = req.Name
+	updated.Email = req.Email
+	updated.Phone = req.Phone
+	updated.Address = req.Address
+	if updated != customer {
+		updated.UpdatedAt = s.now()
+	}
+	s.customers[id] = updated
+	return &updated, nil
+}
+
+// DeleteCustomer removes a customer unless they have orders
+func (s *MemoryStore) DeleteCustomer(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.customers[id]; !ok {
+		return ErrNotFound
+	}
+	for _, o := range s.orders {
+		if o.CustomerID == id {
+			return &ConstraintError{Kind: StillReferenced, Table: "customers", RefTable: "orders"}
+		}
+	}
+	delete(s.customers, id)
+	return nil
+}
+
+// CreateOrder checks and prices the items from the menu and stores the order
+// atomically
+func (s *MemoryStore) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Validate everything before writing so a failure leaves no partial order
+	priced, err := s.prepareOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	pricing := priced.pricing
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, &ConstraintError{Kind: CheckViolation, Table: "order_items", Column: "quantity"}
+		}
+	}
+
+	order := Order{
+		ID:                 s.nextID("orders"),
+		CustomerID:         req.CustomerID,
+		RestaurantID:       req.RestaurantID,
+		TotalAmount:        pricing.Total,
+		Currency:           priced.currency,
+		Status:             priced.status(),
+		OrderDate:          s.now(),
+		DeliveryAddress:    req.DeliveryAddress,
+		DeliveryDistanceKm: req.DeliveryDistanceKm,
+		Notes:              req.Notes,
+		Pricing:            pricing,
+	}
+	if req.ScheduledFor != nil {
+		// TIMESTAMP columns only keep whole seconds
+		scheduledFor := req.ScheduledFor.Truncate(time.Second)
+		order.ScheduledFor, order.ReleaseAt = &scheduledFor, priced.releaseAt
+	}
+	s.orders[order.ID] = order
+	s.addStatusEvent(OrderStatusEvent{OrderID: order.ID, ToStatus: order.Status})
+
+	for i, item := range req.Items {
+		orderItem := OrderItem{
+			ID:         s.nextID("order_items"),
+			OrderID:    order.ID,
+			MenuItemID: item.MenuItemID,
+			Quantity:   item.Quantity,
+			UnitPrice:  priced.prices[i],
+		}
+		m := priced.menuItems[item.MenuItemID]
+		for _, o := range m.selectedOptions(item.OptionIDs) {
+			o.ID = s.nextID("order_item_options")
+			o.OrderItemID = orderItem.ID
+			orderItem.Options = append(orderItem.Options, o)
+		}
+		s.orderItems[orderItem.ID] = orderItem
+
+		// Each bundle is followed by the lines of its components
+		for _, c := range m.components(item.BundleChoices) {
+			bundleItemID := orderItem.ID
+			component := OrderItem{
+				ID:           s.nextID("order_items"),
+				OrderID:      order.ID,
+				MenuItemID:   c.Item.MenuItemID,
+				Quantity:     item.Quantity * c.Slot.Quantity,
+				BundleItemID: &bundleItemID,
+				BundleSlot:   c.Slot.Name,
+			}
+			s.orderItems[component.ID] = component
+		}
+	}
+
+	// Record the promotions the order got against their usage limits
+	for _, line := range pricing.Discounts {
+		r := redemption{
+			ID:          s.nextID("promotion_redemptions"),
+			PromotionID: line.PromotionID,
+			OrderID:     order.ID,
+			CustomerID:  req.CustomerID,
+			Amount:      line.Amount,
+			CreatedAt:   order.OrderDate,
+		}
+		s.redemptions[r.ID] = r
+	}
+
+	return s.getOrder(order.ID)
+}
+
+// prepareOrder checks req and prices it from the menu, the pricing of its
+// restaurant and its promotions. A customer ID of 0 is not checked.
+func (s *MemoryStore) prepareOrder(req CreateOrderRequest) (*pricedOrder, error) {
+	_, customerExists := s.customers[req.CustomerID]
+	restaurant, restaurantExists := s.restaurants[req.RestaurantID]
+	menuItems := make(map[int]MenuItem, len(req.Items))
+	for _, item := range req.Items {
+		if m, ok := s.menuItems[item.MenuItemID]; ok {
+			menuItems[m.ID] = s.menuItemOf(m)
+		}
+	}
+	pricing := s.pricingOf(req.RestaurantID)
+	promotions := s.orderPromotions(req)
+	hours := s.hoursOf(restaurant)
+	clock := newMenuClock(restaurant, s.restaurantCategories(req.RestaurantID), req.orderTime(promotions.Now))
+	if oerr := checkOrder(req, customerExists || req.CustomerID == 0, restaurantExists, menuItems, hours, clock, pricing, promotions); oerr != nil {
+		return nil, oerr
+	}
+	prices, breakdown, _ := priceOrder(req, menuItems, pricing, promotions)
+	return &pricedOrder{currency: restaurant.Currency, menuItems: menuItems, prices: prices, pricing: breakdown, releaseAt: hours.releaseAt(req.ScheduledFor)}, nil
+}
+
+// QuoteOrder prices req like CreateOrder without storing anything
+func (s *MemoryStore) QuoteOrder(ctx context.Context, req CreateOrderRequest) (*OrderQuote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	priced, err := s.prepareOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	return newOrderQuote(req, priced.currency, priced.prices, priced.pricing), nil
+}
+
+// ListOrders returns a page of orders with the related records selected by
+// opts.Include, newest first by default
+func (s *MemoryStore) ListOrders(ctx context.Context, opts ListOptions) ([]Order, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orders := []Order{}
+	for _, o := range s.orders {
+		orders = append(orders, o)
+	}
+	orders, info := pageRecords(orderListing, orders, opts)
+	for i := range orders {
+		s.loadOrderRelations(&orders[i], opts)
+	}
+	return orders, info, nil
+}
+
+// GetOrder returns an order by ID together with its items and their menu items
+func (s *MemoryStore) GetOrder(ctx context.Context, id int) (*Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getOrder(id)
+}
+
+func (s *MemoryStore) getOrder(id int) (*Order, error) {
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	s.loadOrderRelations(&order, ListOptions{Include: orderListing.defaultInclude})
+	return &order, nil
+}
+
+// loadOrderRelations attaches copies of the related records selected by
+// opts.Include to order
+func (s *MemoryStore) loadOrderRelations(order *Order, opts ListOptions) {
+	if opts.includes("items") {
+		order.Items = nil
+		for _, item := range s.orderItems {
+			if item.OrderID != order.ID {
+				continue
+			}
+			if opts.includes("items.menu_item") {
+				m := s.menuItems[item.MenuItemID]
+				item.MenuItem = &m
+			}
+			order.Items = append(order.Items, item)
+		}
+		sort.Slice(order.Items, func(i, j int) bool { return order.Items[i].ID < order.Items[j].ID })
+	}
+	if opts.includes("customer") {
+		c := s.customers[order.CustomerID]
+		order.Customer = &c
+	}
+	if opts.includes("restaurant") {
+		r := s.restaurantOf(s.restaurants[order.RestaurantID])
+		order.Restaurant = &r
+	}
+}
+
+// UpdateOrderStatus moves an order to req.Status, records the change and
+// returns the stored order
+func (s *MemoryStore) UpdateOrderStatus(ctx context.Context, id int, req UpdateOrderStatusRequest) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !canTransitionOrder(order.Status, req.Status) {
+		return nil, &TransitionError{From: order.Status, To: req.Status}
+	}
+	s.addStatusEvent(OrderStatusEvent{
+		OrderID:    id,
+		FromStatus: order.Status,
+		ToStatus:   req.Status,
+		Actor:      req.Actor,
+		Reason:     req.Reason,
+	})
+	order.Status = req.Status
+	s.orders[id] = order
+	// Cancelled orders give their promotions back
+	if req.Status == "cancelled" {
+		s.deleteRedemptions(id)
+	}
+	return s.getOrder(id)
+}
+
+// ReleaseScheduledOrders moves up to limit scheduled orders whose release
+// time has come by now to pending, the earliest first
+func (s *MemoryStore) ReleaseScheduledOrders(ctx context.Context, now time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Order
+	for _, o := range s.orders {
+		if o.Status == "scheduled" && o.ReleaseAt != nil && !o.ReleaseAt.After(now) {
+			due = append(due, o)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].ReleaseAt.Equal(*due[j].ReleaseAt) {
+			return due[i].ReleaseAt.Before(*due[j].ReleaseAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, o := range due {
+		s.addStatusEvent(releaseEvent(o.ID))
+		o.Status = "pending"
+		s.orders[o.ID] = o
+	}
+	return len(due), nil
+}
+
+// addStatusEvent stamps e and adds it to the status history
+func (s *MemoryStore) addStatusEvent(e OrderStatusEvent) {
+	e.ID = s.nextID("order_status_events")
+	e.CreatedAt = s.now()
+	s.orderEvents[e.ID] = e
+}
+
+// ListOrderStatusEvents returns the status history of an order, oldest first
+func (s *MemoryStore) ListOrderStatusEvents(ctx context.Context, orderID int) ([]OrderStatusEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.orders[orderID]; !ok {
+		return nil, ErrNotFound
+	}
+	events := []OrderStatusEvent{}
+	for _, e := range s.orderEvents {
+		if e.OrderID == orderID {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// DeleteOrder removes an order and cascades to its items, status history
+// and promotion redemptions
+func (s *MemoryStore) DeleteOrder(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[id]; !ok {
+		return ErrNotFound
+	}
+	for itemID, item := range s.orderItems {
+		if item.OrderID == id {
+			delete(s.orderItems, itemID)
+		}
+	}
+	for eventID, e := range s.orderEvents {
+		if e.OrderID == id {
+			delete(s.orderEvents, eventID)
+		}
+	}
+	s.deleteRedemptions(id)
+	delete(s.orders, id)
+	return nil
+}
+
+// ClaimIdempotencyKey stores rec as in progress unless its key is held by
+// a live record, which is returned instead
+func (s *MemoryStore) ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	if existing, ok := s.idempotency[id]; ok && existing.live(rec.CreatedAt) {
+		existing.Body = append([]byte(nil), existing.Body...)
+		return &existing, nil
+	}
+	rec.StatusCode, rec.ContentType, rec.Body = 0, "", nil
+	s.idempotency[id] = rec
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response of rec and drops expired records
+func (s *MemoryStore) CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	existing, ok := s.idempotency[id]
+	if !ok || !existing.heldBy(rec) {
+		return ErrIdempotencyKeyLost
+	}
+	existing.StatusCode = rec.StatusCode
+	existing.ContentType = rec.ContentType
+	existing.Body = append([]byte(nil), rec.Body...)
+	s.idempotency[id] = existing
+	for id, r := range s.idempotency {
+		if r.ExpiresAt.Before(rec.CreatedAt) {
+			delete(s.idempotency, id)
+		}
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key that is still in progress
+func (s *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{rec.Scope, rec.Key}
+	existing, ok := s.idempotency[id]
+	if !ok || !existing.heldBy(rec) {
+		return ErrIdempotencyKeyLost
+	}
+	delete(s.idempotency, id)
+	return nil
+}
+
+// redemption is a row of promotion_redemptions
+type redemption struct {
+	ID          int
+	PromotionID int
+	OrderID     int
+	CustomerID  int
+	Amount      Money
+	CreatedAt   time.Time
+}
+
+// promotionOf returns a copy of a stored promotion with its redemption count
+func (s *MemoryStore) promotionOf(p Promotion) Promotion {
+	p.Redemptions = 0
+	for _, r := range s.redemptions {
+		if r.PromotionID == p.ID {
+			p.Redemptions++
+		}
+	}
+	return p
+}
+
+// setPromotion checks req against the constraints of the promotions table
+// and copies it into p. Left out, the currency follows the restaurant, then
+// stays as is.
+func (s *MemoryStore) setPromotion(p *Promotion, req PromotionRequest) error {
+	if req.Code != "" {
+		for _, other := range s.promotions {
+			if other.ID != p.ID && strings.EqualFold(other.Code, req.Code) {
+				return &ConstraintError{Kind: UniqueViolation, Table: "promotions", Column: "code"}
+			}
+		}
+	}
+	currency := req.Currency
+	if req.RestaurantID != nil {
+		r, ok := s.restaurants[*req.RestaurantID]
+		if !ok {
+			return &ConstraintError{Kind: MissingReference, Table: "promotions", Column: "restaurant_id", RefTable: "restaurants"}
+		}
+		if currency == "" {
+			currency = r.Currency
+		}
+	}
+	if req.MenuItemID != nil {
+		if _, ok := s.menuItems[*req.MenuItemID]; !ok {
+			return &ConstraintError{Kind: MissingReference, Table: "promotions", Column: "menu_item_id", RefTable: "menu_items"}
+		}
+	}
+	if currency != "" {
+		p.Currency = currency
+	}
+
+	// TIMESTAMP columns only keep whole seconds
+	seconds := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		truncated := t.Truncate(time.Second)
+		return &truncated
+	}
+	copyInt := func(n *int) *int {
+		if n == nil {
+			return nil
+		}
+		v := *n
+		return &v
+	}
+	p.Code = req.Code
+	p.Name = req.Name
+	p.Description = req.Description
+	p.RestaurantID = copyInt(req.RestaurantID)
+	p.Kind = req.Kind
+	p.PercentOff = req.PercentOff
+	p.AmountOff = req.AmountOff
+	p.MenuItemID = copyInt(req.MenuItemID)
+	p.Category = req.Category
+	p.BuyQuantity = req.BuyQuantity
+	p.GetQuantity = req.GetQuantity
+	p.MinSubtotal = req.MinSubtotal
+	p.MaxRedemptions = req.MaxRedemptions
+	p.MaxRedemptionsPerCustomer = req.MaxRedemptionsPerCustomer
+	p.StartsAt = seconds(req.StartsAt)
+	p.EndsAt = seconds(req.EndsAt)
+	p.IsActive = req.IsActive
+	return nil
+}
+
+// CreatePromotion inserts a promotion and returns the stored row
+func (s *MemoryStore) CreatePromotion(ctx context.Context, req PromotionRequest) (*Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := Promotion{Currency: DefaultCurrency}
+	if err := s.setPromotion(&p, req); err != nil {
+		return nil, err
+	}
+	p.ID = s.nextID("promotions")
+	p.CreatedAt = s.now()
+	p.UpdatedAt = p.CreatedAt
+	s.promotions[p.ID] = p
+	result := s.promotionOf(p)
+	return &result, nil
+}
+
+// ListPromotions returns a page of promotions, newest first by default
+func (s *MemoryStore) ListPromotions(ctx context.Context, opts ListOptions) ([]Promotion, PageInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	promotions := []Promotion{}
+	for _, p := range s.promotions {
+		promotions = append(promotions, s.promotionOf(p))
+	}
+	promotions, info := pageRecords(promotionListing, promotions, opts)
+	return promotions, info, nil
+}
+
+// GetPromotion returns a promotion by ID
+func (s *MemoryStore) GetPromotion(ctx context.Context, id int) (*Promotion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.promotions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := s.promotionOf(p)
+	return &result, nil
+}
+
+// UpdatePromotion overwrites a promotion and returns the stored row
+func (s *MemoryStore) UpdatePromotion(ctx context.Context, id int, req PromotionRequest) (*Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.promotions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := p
+	if err := s.setPromotion(&updated, req); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(updated, p) {
+		updated.UpdatedAt = s.now()
+	}
+	s.promotions[id] = updated
+	result := s.promotionOf(updated)
+	return &result, nil
+}
+
+// DeletePromotion removes a promotion no order has redeemed
+func (s *MemoryStore) DeletePromotion(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.promotions[id]; !ok {
+		return ErrNotFound
+	}
+	if s.promotionRedeemed(id) {
+		return &ConstraintError{Kind: StillReferenced, Table: "promotions", RefTable: "promotion_redemptions"}
+	}
+	delete(s.promotions, id)
+	return nil
+}
+
+// promotionRedeemed reports whether any order holds a redemption of the
+// promotion
+func (s *MemoryStore) promotionRedeemed(promotionID int) bool {
+	for _, r := range s.redemptions {
+		if r.PromotionID == promotionID {
+			return true
+		}
+	}
+	return false
+}
+
+// orderPromotions returns the promotions req may get with their usage
+func (s *MemoryStore) orderPromotions(req CreateOrderRequest) orderPromotions {
+	promotions := orderPromotions{
+		Now:      s.now(),
+		Currency: s.restaurants[req.RestaurantID].Currency,
+		Usage:    map[int]promotionUsage{},
+	}
+	for _, p := range s.promotions {
+		automatic := p.Code == "" && (p.RestaurantID == nil || *p.RestaurantID == req.RestaurantID)
+		coded := p.Code != "" && strings.EqualFold(p.Code, req.PromotionCode)
+		if p.IsActive && (automatic || coded) {
+			promotions.Candidates = append(promotions.Candidates, p)
+		}
+	}
+	sort.Slice(promotions.Candidates, func(i, j int) bool { return promotions.Candidates[i].ID < promotions.Candidates[j].ID })
+	for _, r := range s.redemptions {
+		usage := promotions.Usage[r.PromotionID]
+		usage.Total++
+		if r.CustomerID == req.CustomerID {
+			usage.Customer++
+		}
+		promotions.Usage[r.PromotionID] = usage
+	}
+	return promotions
+}
+
+// deleteRedemptions gives back the promotions redeemed by an order
+func (s *MemoryStore) deleteRedemptions(orderID int) {
+	for id, r := range s.redemptions {
+		if r.OrderID == orderID {
+			delete(s.redemptions, id)
+		}
+	}
+}
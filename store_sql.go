@@ -0,0 +1,2244 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore implements Store with plain SQL that runs unchanged on MySQL
+// and SQLite, using the schema from the migrations directory
+type SQLStore struct {
+	db *Database
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore creates a store backed by the given database
+func NewSQLStore(db *Database) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound converts sql.ErrNoRows into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// requireAffected returns ErrNotFound when a statement touched no rows
+func requireAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// forUpdate returns the clause locking the rows read by a SELECT until the
+// transaction ends. SQLite transactions take the database write lock when
+// they begin (see NewSQLiteDatabase), so they need none.
+func (s *SQLStore) forUpdate() string {
+	if s.db.Driver == "sqlite" {
+		return ""
+	}
+	return ` FOR UPDATE`
+}
+
+// sqlArg converts a cursor value into a query argument. SQLite compares
+// timestamps as text, so they must match the format of CURRENT_TIMESTAMP.
+func (s *SQLStore) sqlArg(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok && s.db.Driver == "sqlite" {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return v
+}
+
+// list selects one page of res.table and counts all rows matching the
+// filters of opts; scan is called for each row of the page.
+func (s *SQLStore) list(ctx context.Context, res listResource, columns string, opts ListOptions, scan func(rowScanner) error) (PageInfo, error) {
+	var info PageInfo
+	conds, args := filterConditions(res, opts.Filters, s.sqlArg)
+	where := func() string {
+		if len(conds) == 0 {
+			return ""
+		}
+		return ` WHERE ` + strings.Join(conds, " AND ")
+	}
+
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+res.table+where(), args...).Scan(&info.Total)
+	if err != nil {
+		return info, err
+	}
+
+	sortFields := res.sortFields(opts)
+	if opts.After != nil {
+		cond, condArgs := keysetCondition(res, sortFields, opts.After, s.sqlArg)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+	query := `SELECT ` + columns + ` FROM ` + res.table + where() + orderByClause(res, sortFields)
+	if opts.Limit > 0 {
+		// One extra row tells whether another page follows
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, opts.Limit+1, opts.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return info, err
+	}
+	defer rows.Close()
+
+	for n := 0; rows.Next(); n++ {
+		if opts.Limit > 0 && n == opts.Limit {
+			info.HasMore = true
+			break
+		}
+		if err := scan(rows); err != nil {
+			return info, err
+		}
+	}
+	return info, rows.Err()
+}
+
+const restaurantColumns = `id, name, address, phone, email, cuisine_type, currency, time_zone, ordering_paused, prep_time_minutes, created_at, updated_at`
+
+func scanRestaurant(row rowScanner) (*Restaurant, error) {
+	var r Restaurant
+	err := row.Scan(&r.ID, &r.Name, &r.Address, &r.Phone, &r.Email, &r.CuisineType, &r.Currency, &r.TimeZone, &r.OrderingPaused, &r.PrepTimeMinutes, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRestaurant inserts a restaurant and returns the stored row
+func (s *SQLStore) CreateRestaurant(ctx context.Context, req CreateRestaurantRequest) (*Restaurant, error) {
+	currency := req.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	timeZone := req.TimeZone
+	if timeZone == "" {
+		timeZone = DefaultTimeZone
+	}
+	prepTime := DefaultPrepTimeMinutes
+	if req.PrepTimeMinutes != nil {
+		prepTime = *req.PrepTimeMinutes
+	}
+	query := `INSERT INTO restaurants (name, address, phone, email, cuisine_type, currency, time_zone, prep_time_minutes) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := s.db.ExecContext(ctx, query, req.Name, req.Address, req.Phone, req.Email, req.CuisineType, currency, timeZone, prepTime)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "restaurants", nil)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRestaurant(ctx, int(id))
+}
+
+// ListRestaurants returns a page of restaurants, newest first by default
+func (s *SQLStore) ListRestaurants(ctx context.Context, opts ListOptions) ([]Restaurant, PageInfo, error) {
+	restaurants := []Restaurant{}
+	info, err := s.list(ctx, restaurantListing, restaurantColumns, opts, func(row rowScanner) error {
+		r, err := scanRestaurant(row)
+		if err != nil {
+			return err
+		}
+		restaurants = append(restaurants, *r)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := s.attachOpenStatus(ctx, s.db, restaurants); err != nil {
+		return nil, info, err
+	}
+	return restaurants, info, nil
+}
+
+// GetRestaurant returns a restaurant by ID
+func (s *SQLStore) GetRestaurant(ctx context.Context, id int) (*Restaurant, error) {
+	query := `SELECT ` + restaurantColumns + ` FROM restaurants WHERE id = ?`
+	r, err := scanRestaurant(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	restaurants := []Restaurant{*r}
+	if err := s.attachOpenStatus(ctx, s.db, restaurants); err != nil {
+		return nil, err
+	}
+	return &restaurants[0], nil
+}
+
+// UpdateRestaurant overwrites a restaurant and returns the stored row
+func (s *SQLStore) UpdateRestaurant(ctx context.Context, id int, req CreateRestaurantRequest) (*Restaurant, error) {
+	query := `UPDATE restaurants SET name = ?, address = ?, phone = ?, email = ?, cuisine_type = ?,
+		currency = COALESCE(NULLIF(?, ''), currency), time_zone = COALESCE(NULLIF(?, ''), time_zone),
+		prep_time_minutes = COALESCE(?, prep_time_minutes) WHERE id = ?`
+	result, err := s.db.ExecContext(ctx, query, req.Name, req.Address, req.Phone, req.Email, req.CuisineType, req.Currency, req.TimeZone, req.PrepTimeMinutes, id)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "restaurants", nil)
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return s.GetRestaurant(ctx, id)
+}
+
+// DeleteRestaurant removes a restaurant
+func (s *SQLStore) DeleteRestaurant(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM restaurants WHERE id = ?`, id)
+	if err != nil {
+		return deleteError(ctx, s.db, err, "restaurants", id)
+	}
+	return requireAffected(result)
+}
+
+// GetRestaurantPricing returns the pricing of a restaurant
+func (s *SQLStore) GetRestaurantPricing(ctx context.Context, restaurantID int) (*RestaurantPricing, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM restaurants WHERE id = ?)`, restaurantID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	pricing := RestaurantPricing{RestaurantID: restaurantID}
+	if err := s.loadRestaurantPricing(ctx, s.db, &pricing); err != nil {
+		return nil, err
+	}
+	return &pricing, nil
+}
+
+// loadRestaurantPricing fills in the pricing of pricing.RestaurantID
+func (s *SQLStore) loadRestaurantPricing(ctx context.Context, q sqlConn, pricing *RestaurantPricing) error {
+	pricing.Taxes = []TaxRate{}
+	pricing.DeliveryFeeBands = []DeliveryFeeBand{}
+
+	query := `SELECT prices_include_tax, service_charge_rate FROM restaurant_pricing WHERE restaurant_id = ?`
+	err := q.QueryRowContext(ctx, query, pricing.RestaurantID).Scan(&pricing.PricesIncludeTax, &pricing.ServiceChargeRate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT name, rate FROM restaurant_taxes WHERE restaurant_id = ? ORDER BY id`, pricing.RestaurantID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var tax TaxRate
+		if err := rows.Scan(&tax.Name, &tax.Rate); err != nil {
+			rows.Close()
+			return err
+		}
+		pricing.Taxes = append(pricing.Taxes, tax)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `SELECT max_distance_km, fee FROM delivery_fee_bands WHERE restaurant_id = ? ORDER BY max_distance_km`
+	rows, err = q.QueryContext(ctx, query, pricing.RestaurantID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var band DeliveryFeeBand
+		if err := rows.Scan(&band.MaxDistanceKm, &band.Fee); err != nil {
+			return err
+		}
+		pricing.DeliveryFeeBands = append(pricing.DeliveryFeeBands, band)
+	}
+	return rows.Err()
+}
+
+// UpdateRestaurantPricing replaces the pricing of a restaurant in one
+// transaction
+func (s *SQLStore) UpdateRestaurantPricing(ctx context.Context, restaurantID int, req RestaurantPricingRequest) (*RestaurantPricing, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM restaurants WHERE id = ?`+s.forUpdate(), restaurantID).Scan(&id)
+		if err != nil {
+			return notFound(err)
+		}
+		for _, table := range []string{"restaurant_pricing", "restaurant_taxes", "delivery_fee_bands"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE restaurant_id = ?`, restaurantID); err != nil {
+				return err
+			}
+		}
+
+		query := `INSERT INTO restaurant_pricing (restaurant_id, prices_include_tax, service_charge_rate) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, restaurantID, req.PricesIncludeTax, req.ServiceChargeRate); err != nil {
+			return writeError(ctx, tx, err, "restaurant_pricing", nil)
+		}
+		for _, tax := range req.Taxes {
+			query = `INSERT INTO restaurant_taxes (restaurant_id, name, rate) VALUES (?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, restaurantID, tax.Name, tax.Rate); err != nil {
+				return writeError(ctx, tx, err, "restaurant_taxes", nil)
+			}
+		}
+		for _, band := range req.DeliveryFeeBands {
+			query = `INSERT INTO delivery_fee_bands (restaurant_id, max_distance_km, fee) VALUES (?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, restaurantID, band.MaxDistanceKm, band.Fee); err != nil {
+				return writeError(ctx, tx, err, "delivery_fee_bands", nil)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRestaurantPricing(ctx, restaurantID)
+}
+
+// attachOpenStatus computes the opening status of restaurants now from
+// their opening hours
+func (s *SQLStore) attachOpenStatus(ctx context.Context, q sqlConn, restaurants []Restaurant) error {
+	hours := make(map[int]*OpeningHours, len(restaurants))
+	for _, r := range restaurants {
+		hours[r.ID] = &OpeningHours{RestaurantID: r.ID, TimeZone: r.TimeZone}
+	}
+	if err := s.loadOpeningHours(ctx, q, hours); err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range restaurants {
+		restaurants[i].setOpenStatus(*hours[restaurants[i].ID], now)
+	}
+	return nil
+}
+
+// loadOpeningHours fills in the shifts and closures of hours, keyed by
+// restaurant ID
+func (s *SQLStore) loadOpeningHours(ctx context.Context, q sqlConn, hours map[int]*OpeningHours) error {
+	ids := make([]int, 0, len(hours))
+	for id, h := range hours {
+		h.Shifts, h.Closures = []OpeningShift{}, []Closure{}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `SELECT restaurant_id, day, opens_at, closes_at FROM opening_hours`
+	err := s.selectIn(ctx, q, query, "restaurant_id", ids, " ORDER BY position, id", func(row rowScanner) error {
+		var restaurantID int
+		var shift OpeningShift
+		if err := row.Scan(&restaurantID, &shift.Day, &shift.OpensAt, &shift.ClosesAt); err != nil {
+			return err
+		}
+		hours[restaurantID].Shifts = append(hours[restaurantID].Shifts, shift)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	query = `SELECT restaurant_id, start_date, end_date, reason FROM restaurant_closures`
+	return s.selectIn(ctx, q, query, "restaurant_id", ids, " ORDER BY start_date, id", func(row rowScanner) error {
+		var restaurantID int
+		var c Closure
+		var startDate, endDate time.Time
+		if err := row.Scan(&restaurantID, &startDate, &endDate, &c.Reason); err != nil {
+			return err
+		}
+		c.StartDate, c.EndDate = startDate.Format("2006-01-02"), endDate.Format("2006-01-02")
+		hours[restaurantID].Closures = append(hours[restaurantID].Closures, c)
+		return nil
+	})
+}
+
+// GetOpeningHours returns the shifts and closures of a restaurant
+func (s *SQLStore) GetOpeningHours(ctx context.Context, restaurantID int) (*OpeningHours, error) {
+	hours := OpeningHours{RestaurantID: restaurantID}
+	query := `SELECT time_zone, ordering_paused, prep_time_minutes FROM restaurants WHERE id = ?`
+	if err := s.db.QueryRowContext(ctx, query, restaurantID).Scan(&hours.TimeZone, &hours.OrderingPaused, &hours.PrepTimeMinutes); err != nil {
+		return nil, notFound(err)
+	}
+	if err := s.loadOpeningHours(ctx, s.db, map[int]*OpeningHours{restaurantID: &hours}); err != nil {
+		return nil, err
+	}
+	return &hours, nil
+}
+
+// UpdateOpeningHours replaces the shifts and closures of a restaurant in
+// one transaction
+func (s *SQLStore) UpdateOpeningHours(ctx context.Context, restaurantID int, req OpeningHoursRequest) (*OpeningHours, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM restaurants WHERE id = ?`+s.forUpdate(), restaurantID).Scan(&id)
+		if err != nil {
+			return notFound(err)
+		}
+		for _, table := range []string{"opening_hours", "restaurant_closures"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE restaurant_id = ?`, restaurantID); err != nil {
+				return err
+			}
+		}
+
+		shifts, closures := req.hours()
+		for position, shift := range shifts {
+			query := `INSERT INTO opening_hours (restaurant_id, day, opens_at, closes_at, position) VALUES (?, ?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, restaurantID, shift.Day, shift.OpensAt, shift.ClosesAt, position); err != nil {
+				return writeError(ctx, tx, err, "opening_hours", nil)
+			}
+		}
+		for _, c := range closures {
+			query := `INSERT INTO restaurant_closures (restaurant_id, start_date, end_date, reason) VALUES (?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, restaurantID, c.StartDate, c.EndDate, c.Reason); err != nil {
+				return writeError(ctx, tx, err, "restaurant_closures", nil)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetOpeningHours(ctx, restaurantID)
+}
+
+// SetOrderingPaused pauses or resumes ordering at a restaurant
+func (s *SQLStore) SetOrderingPaused(ctx context.Context, restaurantID int, paused bool) (*Restaurant, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE restaurants SET ordering_paused = ? WHERE id = ?`, paused, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return s.GetRestaurant(ctx, restaurantID)
+}
+
+const menuItemColumns = `id, restaurant_id, name, description, price, category_id, category, kind, is_available, created_at, updated_at`
+
+func scanMenuItem(row rowScanner) (*MenuItem, error) {
+	var m MenuItem
+	var categoryID sql.NullInt64
+	err := row.Scan(&m.ID, &m.RestaurantID, &m.Name, &m.Description, &m.Price, &categoryID, &m.Category, &m.Kind, &m.IsAvailable, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		m.CategoryID = &id
+	}
+	return &m, nil
+}
+
+// menuItemCategory returns the category ID and name to store with a menu
+// item written with req. A category named by req.Category alone is
+// created if the restaurant has none by that name.
+func (s *SQLStore) menuItemCategory(ctx context.Context, tx *sql.Tx, req CreateMenuItemRequest) (*int, string, error) {
+	if req.CategoryID != nil {
+		query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = ?` + s.forUpdate()
+		c, err := scanCategory(tx.QueryRowContext(ctx, query, *req.CategoryID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", &ConstraintError{Kind: MissingReference, Table: "menu_items", Column: "category_id", RefTable: "categories"}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if fields := categoryOfMenuItem(req.RestaurantID, *c); len(fields) > 0 {
+			return nil, "", &CategoryError{Fields: fields}
+		}
+		return &c.ID, c.Name, nil
+	}
+
+	name := strings.TrimSpace(req.Category)
+	if name == "" {
+		return nil, "", nil
+	}
+	categories, err := s.restaurantCategories(ctx, tx, req.RestaurantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if c := findCategory(categories, name); c != nil {
+		return &c.ID, c.Name, nil
+	}
+	query := `INSERT INTO categories (restaurant_id, name, position, is_visible) VALUES (?, ?, ?, TRUE)`
+	result, err := tx.ExecContext(ctx, query, req.RestaurantID, name, nextCategoryPosition(categories))
+	if err != nil {
+		return nil, "", writeError(ctx, tx, err, "categories", map[string]int{"restaurant_id": req.RestaurantID})
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	categoryID := int(id)
+	return &categoryID, name, nil
+}
+
+// CreateMenuItem inserts a menu item and returns the stored row
+func (s *SQLStore) CreateMenuItem(ctx context.Context, req CreateMenuItemRequest) (*MenuItem, error) {
+	kind := req.Kind
+	if kind == "" {
+		kind = "item"
+	}
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		categoryID, category, err := s.menuItemCategory(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+		query := `INSERT INTO menu_items (restaurant_id, name, description, price, category_id, category, kind, is_available) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		result, err := tx.ExecContext(ctx, query, req.RestaurantID, req.Name, req.Description, req.Price, categoryID, category, kind, req.IsAvailable)
+		if err != nil {
+			return writeError(ctx, tx, err, "menu_items", menuItemRefs(req))
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMenuItem(ctx, int(id))
+}
+
+// menuItemRefs returns the references of a menu item request for writeError
+func menuItemRefs(req CreateMenuItemRequest) map[string]int {
+	refs := map[string]int{"restaurant_id": req.RestaurantID}
+	if req.CategoryID != nil {
+		refs["category_id"] = *req.CategoryID
+	}
+	return refs
+}
+
+// ListMenuItems returns a page of menu items, ordered by restaurant,
+// category and name by default
+func (s *SQLStore) ListMenuItems(ctx context.Context, opts ListOptions) ([]MenuItem, PageInfo, error) {
+	menuItems := []MenuItem{}
+	info, err := s.list(ctx, menuItemListing, menuItemColumns, opts, func(row rowScanner) error {
+		m, err := scanMenuItem(row)
+		if err != nil {
+			return err
+		}
+		menuItems = append(menuItems, *m)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := s.attachMenuItemRelations(ctx, s.db, menuItems); err != nil {
+		return nil, info, err
+	}
+	return menuItems, info, nil
+}
+
+// GetMenuItem returns a menu item by ID
+func (s *SQLStore) GetMenuItem(ctx context.Context, id int) (*MenuItem, error) {
+	query := `SELECT ` + menuItemColumns + ` FROM menu_items WHERE id = ?`
+	m, err := scanMenuItem(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	menuItems := []MenuItem{*m}
+	if err := s.attachMenuItemRelations(ctx, s.db, menuItems); err != nil {
+		return nil, err
+	}
+	return &menuItems[0], nil
+}
+
+// UpdateMenuItem overwrites a menu item and returns the stored row. The
+// kind of a menu item is kept.
+func (s *SQLStore) UpdateMenuItem(ctx context.Context, id int, req CreateMenuItemRequest) (*MenuItem, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var found int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM menu_items WHERE id = ?`+s.forUpdate(), id).Scan(&found)
+		if err != nil {
+			return notFound(err)
+		}
+		categoryID, category, err := s.menuItemCategory(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+		query := `UPDATE menu_items SET restaurant_id = ?, name = ?, description = ?, price = ?, category_id = ?, category = ?, is_available = ? WHERE id = ?`
+		_, err = tx.ExecContext(ctx, query, req.RestaurantID, req.Name, req.Description, req.Price, categoryID, category, req.IsAvailable, id)
+		if err != nil {
+			return writeError(ctx, tx, err, "menu_items", menuItemRefs(req))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMenuItem(ctx, id)
+}
+
+// DeleteMenuItem removes a menu item
+func (s *SQLStore) DeleteMenuItem(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM menu_items WHERE id = ?`, id)
+	if err != nil {
+		return deleteError(ctx, s.db, err, "menu_items", id)
+	}
+	return requireAffected(result)
+}
+
+// attachMenuItemRelations loads the option groups, bundle slots and
+// availability windows of menuItems
+func (s *SQLStore) attachMenuItemRelations(ctx context.Context, q sqlConn, menuItems []MenuItem) error {
+	if err := s.attachOptionGroups(ctx, q, menuItems); err != nil {
+		return err
+	}
+	if err := s.attachBundleSlots(ctx, q, menuItems); err != nil {
+		return err
+	}
+	ids := make([]int, len(menuItems))
+	for i, m := range menuItems {
+		ids[i] = m.ID
+	}
+	windows, err := s.loadAvailability(ctx, q, "menu_item_availability", "menu_item_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range menuItems {
+		menuItems[i].Availability = windows[menuItems[i].ID]
+	}
+	return nil
+}
+
+// attachOptionGroups loads the option groups of menuItems, in display order
+func (s *SQLStore) attachOptionGroups(ctx context.Context, q sqlConn, menuItems []MenuItem) error {
+	if len(menuItems) == 0 {
+		return nil
+	}
+	ids := make([]int, len(menuItems))
+	for i, m := range menuItems {
+		ids[i] = m.ID
+	}
+
+	options := make(map[int][]MenuOption)
+	query := `SELECT o.id, o.option_group_id, o.name, o.price_delta, o.is_available FROM menu_options o JOIN option_groups g ON g.id = o.option_group_id`
+	err := s.selectIn(ctx, q, query, "g.menu_item_id", ids, " ORDER BY o.position, o.id", func(row rowScanner) error {
+		var o MenuOption
+		if err := row.Scan(&o.ID, &o.OptionGroupID, &o.Name, &o.PriceDelta, &o.IsAvailable); err != nil {
+			return err
+		}
+		options[o.OptionGroupID] = append(options[o.OptionGroupID], o)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[int][]OptionGroup)
+	query = `SELECT id, menu_item_id, name, min_selections, max_selections FROM option_groups`
+	err = s.selectIn(ctx, q, query, "menu_item_id", ids, " ORDER BY position, id", func(row rowScanner) error {
+		var g OptionGroup
+		if err := row.Scan(&g.ID, &g.MenuItemID, &g.Name, &g.MinSelections, &g.MaxSelections); err != nil {
+			return err
+		}
+		g.Options = options[g.ID]
+		groups[g.MenuItemID] = append(groups[g.MenuItemID], g)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range menuItems {
+		menuItems[i].OptionGroups = groups[menuItems[i].ID]
+	}
+	return nil
+}
+
+// UpdateMenuItemOptions replaces the option groups of a menu item in one
+// transaction. Groups and options are matched to the existing ones by
+// name; the rest are deleted, which leaves orders their copies of them.
+func (s *SQLStore) UpdateMenuItemOptions(ctx context.Context, id int, req MenuItemOptionsRequest) (*MenuItem, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var found int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM menu_items WHERE id = ?`+s.forUpdate(), id).Scan(&found)
+		if err != nil {
+			return notFound(err)
+		}
+		current := []MenuItem{{ID: id}}
+		if err := s.attachOptionGroups(ctx, tx, current); err != nil {
+			return err
+		}
+		existing := current[0].OptionGroups
+
+		// Delete what the request leaves out first, so no name is held twice
+		for _, g := range existing {
+			kept := findOptionGroupRequest(req.OptionGroups, g.Name)
+			if kept == nil {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM option_groups WHERE id = ?`, g.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, o := range g.Options {
+				if findMenuOptionRequest(kept.Options, o.Name) == nil {
+					if _, err := tx.ExecContext(ctx, `DELETE FROM menu_options WHERE id = ?`, o.ID); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for position, g := range req.OptionGroups {
+			var groupID int64
+			var options []MenuOption
+			if eg := findOptionGroup(existing, g.Name); eg != nil {
+				groupID, options = int64(eg.ID), eg.Options
+				query := `UPDATE option_groups SET name = ?, min_selections = ?, max_selections = ?, position = ? WHERE id = ?`
+				if _, err := tx.ExecContext(ctx, query, g.Name, g.MinSelections, g.MaxSelections, position, groupID); err != nil {
+					return writeError(ctx, tx, err, "option_groups", nil)
+				}
+			} else {
+				query := `INSERT INTO option_groups (menu_item_id, name, min_selections, max_selections, position) VALUES (?, ?, ?, ?, ?)`
+				result, err := tx.ExecContext(ctx, query, id, g.Name, g.MinSelections, g.MaxSelections, position)
+				if err != nil {
+					return writeError(ctx, tx, err, "option_groups", map[string]int{"menu_item_id": id})
+				}
+				if groupID, err = result.LastInsertId(); err != nil {
+					return err
+				}
+			}
+
+			for optionPosition, o := range g.Options {
+				var err error
+				if eo := findMenuOption(options, o.Name); eo != nil {
+					query := `UPDATE menu_options SET name = ?, price_delta = ?, is_available = ?, position = ? WHERE id = ?`
+					_, err = tx.ExecContext(ctx, query, o.Name, o.PriceDelta, o.IsAvailable, optionPosition, eo.ID)
+				} else {
+					query := `INSERT INTO menu_options (option_group_id, name, price_delta, is_available, position) VALUES (?, ?, ?, ?, ?)`
+					_, err = tx.ExecContext(ctx, query, groupID, o.Name, o.PriceDelta, o.IsAvailable, optionPosition)
+				}
+				if err != nil {
+					return writeError(ctx, tx, err, "menu_options", nil)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMenuItem(ctx, id)
+}
+
+// attachBundleSlots loads the slots of the bundles among menuItems, in
+// display order, with the name, availability, category and schedule of
+// their items
+func (s *SQLStore) attachBundleSlots(ctx context.Context, q sqlConn, menuItems []MenuItem) error {
+	var ids []int
+	for _, m := range menuItems {
+		if m.Kind == "bundle" {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	items := make(map[int][]BundleSlotItem)
+	var componentIDs []int
+	query := `SELECT i.slot_id, i.menu_item_id, m.name, i.price_delta, m.is_available, m.category_id FROM bundle_slot_items i
+		JOIN bundle_slots b ON b.id = i.slot_id JOIN menu_items m ON m.id = i.menu_item_id`
+	err := s.selectIn(ctx, q, query, "b.bundle_id", ids, " ORDER BY i.position, i.id", func(row rowScanner) error {
+		var slotID int
+		var item BundleSlotItem
+		var categoryID sql.NullInt64
+		if err := row.Scan(&slotID, &item.MenuItemID, &item.Name, &item.PriceDelta, &item.IsAvailable, &categoryID); err != nil {
+			return err
+		}
+		if categoryID.Valid {
+			id := int(categoryID.Int64)
+			item.categoryID = &id
+		}
+		items[slotID] = append(items[slotID], item)
+		componentIDs = append(componentIDs, item.MenuItemID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	windows, err := s.loadAvailability(ctx, q, "menu_item_availability", "menu_item_id", componentIDs)
+	if err != nil {
+		return err
+	}
+	for slotID := range items {
+		for i := range items[slotID] {
+			items[slotID][i].availability = windows[items[slotID][i].MenuItemID]
+		}
+	}
+
+	slots := make(map[int][]BundleSlot)
+	query = `SELECT id, bundle_id, name, quantity FROM bundle_slots`
+	err = s.selectIn(ctx, q, query, "bundle_id", ids, " ORDER BY position, id", func(row rowScanner) error {
+		var slot BundleSlot
+		if err := row.Scan(&slot.ID, &slot.BundleID, &slot.Name, &slot.Quantity); err != nil {
+			return err
+		}
+		slot.Items = items[slot.ID]
+		slots[slot.BundleID] = append(slots[slot.BundleID], slot)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range menuItems {
+		menuItems[i].BundleSlots = slots[menuItems[i].ID]
+	}
+	return nil
+}
+
+// UpdateBundleSlots replaces the slots of a bundle in one transaction.
+// Slots are matched to the existing ones by name and their items replaced;
+// orders keep the component lines they were placed with.
+func (s *SQLStore) UpdateBundleSlots(ctx context.Context, id int, req BundleSlotsRequest) (*MenuItem, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the slots before the bundle, in the order CreateOrder locks
+		// them, so that replacing them cannot deadlock with an order
+		if err := lockRows(ctx, tx, `SELECT id FROM bundle_slots WHERE bundle_id = ?`+s.forUpdate(), id); err != nil {
+			return err
+		}
+		query := `SELECT ` + menuItemColumns + ` FROM menu_items WHERE id = ?` + s.forUpdate()
+		bundle, err := scanMenuItem(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return notFound(err)
+		}
+		current := []MenuItem{*bundle}
+		if err := s.attachBundleSlots(ctx, tx, current); err != nil {
+			return err
+		}
+		existing := current[0].BundleSlots
+
+		var itemIDs []int
+		for _, slot := range req.Slots {
+			for _, item := range slot.Items {
+				itemIDs = append(itemIDs, item.MenuItemID)
+			}
+		}
+		menuItems := make(map[int]MenuItem)
+		err = s.selectIn(ctx, tx, `SELECT `+menuItemColumns+` FROM menu_items`, "id", itemIDs, "", func(row rowScanner) error {
+			m, err := scanMenuItem(row)
+			if err == nil {
+				menuItems[m.ID] = *m
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if fields := req.problems(*bundle, menuItems); len(fields) > 0 {
+			return &BundleError{Fields: fields}
+		}
+
+		// Delete the slots the request leaves out first, so no name is held twice
+		for _, slot := range existing {
+			if findBundleSlotRequest(req.Slots, slot.Name) == nil {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM bundle_slots WHERE id = ?`, slot.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for position, slot := range req.Slots {
+			quantity := slot.Quantity
+			if quantity == 0 {
+				quantity = 1
+			}
+			var slotID int64
+			if es := findBundleSlot(existing, slot.Name); es != nil {
+				slotID = int64(es.ID)
+				query := `UPDATE bundle_slots SET name = ?, quantity = ?, position = ? WHERE id = ?`
+				if _, err := tx.ExecContext(ctx, query, slot.Name, quantity, position, slotID); err != nil {
+					return writeError(ctx, tx, err, "bundle_slots", nil)
+				}
+				if _, err := tx.ExecContext(ctx, `DELETE FROM bundle_slot_items WHERE slot_id = ?`, slotID); err != nil {
+					return err
+				}
+			} else {
+				query := `INSERT INTO bundle_slots (bundle_id, name, quantity, position) VALUES (?, ?, ?, ?)`
+				result, err := tx.ExecContext(ctx, query, id, slot.Name, quantity, position)
+				if err != nil {
+					return writeError(ctx, tx, err, "bundle_slots", map[string]int{"bundle_id": id})
+				}
+				if slotID, err = result.LastInsertId(); err != nil {
+					return err
+				}
+			}
+
+			query := `INSERT INTO bundle_slot_items (slot_id, menu_item_id, price_delta, position) VALUES (?, ?, ?, ?)`
+			for itemPosition, item := range slot.Items {
+				if _, err := tx.ExecContext(ctx, query, slotID, item.MenuItemID, item.PriceDelta, itemPosition); err != nil {
+					return writeError(ctx, tx, err, "bundle_slot_items", map[string]int{"menu_item_id": item.MenuItemID})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMenuItem(ctx, id)
+}
+
+// loadAvailability returns the availability windows stored in table for
+// the owners ids, identified by column, in display order
+func (s *SQLStore) loadAvailability(ctx context.Context, q sqlConn, table, column string, ids []int) (map[int][]AvailabilityWindow, error) {
+	windows := make(map[int][]AvailabilityWindow)
+	if len(ids) == 0 {
+		return windows, nil
+	}
+	query := `SELECT ` + column + `, days, start_time, end_time, start_date, end_date FROM ` + table
+	err := s.selectIn(ctx, q, query, column, ids, " ORDER BY position, id", func(row rowScanner) error {
+		var ownerID int
+		var w AvailabilityWindow
+		var startDate, endDate sql.NullTime
+		if err := row.Scan(&ownerID, &w.Days, &w.StartTime, &w.EndTime, &startDate, &endDate); err != nil {
+			return err
+		}
+		if startDate.Valid {
+			date := startDate.Time.Format("2006-01-02")
+			w.StartDate = &date
+		}
+		if endDate.Valid {
+			date := endDate.Time.Format("2006-01-02")
+			w.EndDate = &date
+		}
+		windows[ownerID] = append(windows[ownerID], w)
+		return nil
+	})
+	return windows, err
+}
+
+// replaceAvailability replaces the availability windows stored in table for
+// the owner id, identified by column
+func (s *SQLStore) replaceAvailability(ctx context.Context, tx *sql.Tx, table, column string, id int, req AvailabilityRequest) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE `+column+` = ?`, id); err != nil {
+		return err
+	}
+	query := `INSERT INTO ` + table + ` (` + column + `, days, start_time, end_time, start_date, end_date, position) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for position, w := range req.windows() {
+		if _, err := tx.ExecContext(ctx, query, id, w.Days, w.StartTime, w.EndTime, w.StartDate, w.EndDate, position); err != nil {
+			return writeError(ctx, tx, err, table, nil)
+		}
+	}
+	return nil
+}
+
+// UpdateMenuItemAvailability replaces the availability windows of a menu
+// item in one transaction
+func (s *SQLStore) UpdateMenuItemAvailability(ctx context.Context, id int, req AvailabilityRequest) (*MenuItem, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM menu_items WHERE id = ?`+s.forUpdate(), id).Scan(&exists); err != nil {
+			return notFound(err)
+		}
+		return s.replaceAvailability(ctx, tx, "menu_item_availability", "menu_item_id", id, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMenuItem(ctx, id)
+}
+
+const categoryColumns = `id, restaurant_id, parent_id, name, description, position, is_visible, created_at, updated_at`
+
+func scanCategory(row rowScanner) (*Category, error) {
+	var c Category
+	var parentID sql.NullInt64
+	var description sql.NullString
+	err := row.Scan(&c.ID, &c.RestaurantID, &parentID, &c.Name, &description, &c.Position, &c.IsVisible, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	c.Description = description.String
+	return &c, nil
+}
+
+// attachCategoryAvailability loads the availability windows of categories
+func (s *SQLStore) attachCategoryAvailability(ctx context.Context, q sqlConn, categories []Category) error {
+	ids := make([]int, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID
+	}
+	windows, err := s.loadAvailability(ctx, q, "category_availability", "category_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range categories {
+		categories[i].Availability = windows[categories[i].ID]
+	}
+	return nil
+}
+
+// restaurantCategories returns every category of a restaurant with its
+// availability windows
+func (s *SQLStore) restaurantCategories(ctx context.Context, q sqlConn, restaurantID int) ([]Category, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE restaurant_id = ? ORDER BY position, name, id`, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	categories := []Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, s.attachCategoryAvailability(ctx, q, categories)
+}
+
+// categoryProblems checks req against the categories of its restaurant and
+// its parent, which may belong to another restaurant
+func (s *SQLStore) categoryProblems(ctx context.Context, tx *sql.Tx, req CategoryRequest, current *Category) error {
+	categories, err := s.restaurantCategories(ctx, tx, req.RestaurantID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]Category, len(categories)+1)
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	if req.ParentID != nil {
+		if _, ok := byID[*req.ParentID]; !ok {
+			parent, err := scanCategory(tx.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = ?`, *req.ParentID))
+			if err == nil {
+				byID[parent.ID] = *parent
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+	if fields := req.problems(current, byID); len(fields) > 0 {
+		return &CategoryError{Fields: fields}
+	}
+	return nil
+}
+
+// categoryRefs returns the references of a category request for writeError
+func categoryRefs(req CategoryRequest) map[string]int {
+	refs := map[string]int{"restaurant_id": req.RestaurantID}
+	if req.ParentID != nil {
+		refs["parent_id"] = *req.ParentID
+	}
+	return refs
+}
+
+// CreateCategory inserts a category and returns the stored row
+func (s *SQLStore) CreateCategory(ctx context.Context, req CategoryRequest) (*Category, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := s.categoryProblems(ctx, tx, req, nil); err != nil {
+			return err
+		}
+		query := `INSERT INTO categories (restaurant_id, parent_id, name, description, position, is_visible) VALUES (?, ?, ?, ?, ?, ?)`
+		result, err := tx.ExecContext(ctx, query, req.RestaurantID, req.ParentID, req.Name, req.Description, req.Position, req.IsVisible)
+		if err != nil {
+			return writeError(ctx, tx, err, "categories", categoryRefs(req))
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCategory(ctx, int(id))
+}
+
+// ListCategories returns a page of categories, in display order by default
+func (s *SQLStore) ListCategories(ctx context.Context, opts ListOptions) ([]Category, PageInfo, error) {
+	categories := []Category{}
+	info, err := s.list(ctx, categoryListing, categoryColumns, opts, func(row rowScanner) error {
+		c, err := scanCategory(row)
+		if err != nil {
+			return err
+		}
+		categories = append(categories, *c)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := s.attachCategoryAvailability(ctx, s.db, categories); err != nil {
+		return nil, info, err
+	}
+	return categories, info, nil
+}
+
+// GetCategory returns a category by ID with its availability windows
+func (s *SQLStore) GetCategory(ctx context.Context, id int) (*Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = ?`
+	c, err := scanCategory(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	categories := []Category{*c}
+	if err := s.attachCategoryAvailability(ctx, s.db, categories); err != nil {
+		return nil, err
+	}
+	return &categories[0], nil
+}
+
+// UpdateCategory overwrites a category and returns the stored row. Its
+// menu items take its new name.
+func (s *SQLStore) UpdateCategory(ctx context.Context, id int, req CategoryRequest) (*Category, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := scanCategory(tx.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = ?`+s.forUpdate(), id))
+		if err != nil {
+			return notFound(err)
+		}
+		if err := s.categoryProblems(ctx, tx, req, current); err != nil {
+			return err
+		}
+		query := `UPDATE categories SET parent_id = ?, name = ?, description = ?, position = ?, is_visible = ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, req.ParentID, req.Name, req.Description, req.Position, req.IsVisible, id); err != nil {
+			return writeError(ctx, tx, err, "categories", categoryRefs(req))
+		}
+		if req.Name != current.Name {
+			if _, err := tx.ExecContext(ctx, `UPDATE menu_items SET category = ? WHERE category_id = ?`, req.Name, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCategory(ctx, id)
+}
+
+// DeleteCategory removes a category in one transaction, moving its
+// subcategories up to its parent and leaving its menu items uncategorized
+func (s *SQLStore) DeleteCategory(ctx context.Context, id int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var parentID sql.NullInt64
+		err := tx.QueryRowContext(ctx, `SELECT parent_id FROM categories WHERE id = ?`+s.forUpdate(), id).Scan(&parentID)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET parent_id = ? WHERE parent_id = ?`, parentID, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE menu_items SET category_id = NULL, category = '' WHERE category_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id); err != nil {
+			return deleteError(ctx, tx, err, "categories", id)
+		}
+		return nil
+	})
+}
+
+// UpdateCategoryAvailability replaces the availability windows of a
+// category in one transaction
+func (s *SQLStore) UpdateCategoryAvailability(ctx context.Context, id int, req AvailabilityRequest) (*Category, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`+s.forUpdate(), id).Scan(&exists); err != nil {
+			return notFound(err)
+		}
+		return s.replaceAvailability(ctx, tx, "category_availability", "category_id", id, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCategory(ctx, id)
+}
+
+// GetRestaurantMenu returns the menu of a restaurant at time at with the
+// option groups, bundle slots and schedules of its items
+func (s *SQLStore) GetRestaurantMenu(ctx context.Context, restaurantID int, includeHidden bool, at time.Time) (*Menu, error) {
+	restaurant, err := s.GetRestaurant(ctx, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.restaurantCategories(ctx, s.db, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+menuItemColumns+` FROM menu_items WHERE restaurant_id = ?`, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	menuItems := []MenuItem{}
+	for rows.Next() {
+		m, err := scanMenuItem(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		menuItems = append(menuItems, *m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.attachMenuItemRelations(ctx, s.db, menuItems); err != nil {
+		return nil, err
+	}
+	return buildMenu(*restaurant, categories, menuItems, includeHidden, at), nil
+}
+
+const customerColumns = `id, name, email, phone, address, created_at, updated_at`
+
+func scanCustomer(row rowScanner) (*Customer, error) {
+	var customer Customer
+	err := row.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.Address, &customer.CreatedAt, &customer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// CreateCustomer inserts a customer and returns the stored row
+func (s *SQLStore) CreateCustomer(ctx context.Context, req CreateCustomerRequest) (*Customer, error) {
+	query := `INSERT INTO customers (name, email, phone, address) VALUES (?, ?, ?, ?)`
+	result, err := s.db.ExecContext(ctx, query, req.Name, req.Email, req.Phone, req.Address)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "customers", nil)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCustomer(ctx, int(id))
+}
+
+// ListCustomers returns a page of customers, newest first by default
+func (s *SQLStore) ListCustomers(ctx context.Context, opts ListOptions) ([]Customer, PageInfo, error) {
+	customers := []Customer{}
+	info, err := s.list(ctx, customerListing, customerColumns, opts, func(row rowScanner) error {
+		customer, err := scanCustomer(row)
+		if err != nil {
+			return err
+		}
+		customers = append(customers, *customer)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	return customers, info, nil
+}
+
+// GetCustomer returns a customer by ID
+func (s *SQLStore) GetCustomer(ctx context.Context, id int) (*Customer, error) {
+	query := `SELECT ` + customerColumns + ` FROM customers WHERE id = ?`
+	customer, err := scanCustomer(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return customer, nil
+}
+
+// UpdateCustomer overwrites a customer and returns the stored row
+func (s *SQLStore) UpdateCustomer(ctx context.Context, id int, req CreateCustomerRequest) (*Customer, error) {
+	query := `UPDATE customers SET name = ?, email = ?, phone = ?, address = ? WHERE id = ?`
+	result, err := s.db.ExecContext(ctx, query, req.Name, req.Email, req.Phone, req.Address, id)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "customers", nil)
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return s.GetCustomer(ctx, id)
+}
+
+// DeleteCustomer removes a customer
+func (s *SQLStore) DeleteCustomer(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM customers WHERE id = ?`, id)
+	if err != nil {
+		return deleteError(ctx, s.db, err, "customers", id)
+	}
+	return requireAffected(result)
+}
+
+const orderColumns = `id, customer_id, restaurant_id, subtotal, discount_amount, discount_lines, service_charge, delivery_fee, tip_amount, tax_amount, tax_inclusive, tax_lines, total_amount, currency, status, order_date, delivery_address, delivery_distance_km, notes, scheduled_for, release_at`
+
+const orderItemColumns = `id, order_id, menu_item_id, quantity, unit_price, bundle_item_id, bundle_slot`
+
+func scanOrder(row rowScanner) (*Order, error) {
+	var order Order
+	var distance sql.NullFloat64
+	var scheduledFor, releaseAt sql.NullTime
+	p := &order.Pricing
+	err := row.Scan(&order.ID, &order.CustomerID, &order.RestaurantID,
+		&p.Subtotal, &p.Discount, &p.Discounts, &p.ServiceCharge, &p.DeliveryFee, &p.Tip, &p.TaxTotal, &p.TaxInclusive, &p.Taxes,
+		&order.TotalAmount, &order.Currency, &order.Status, &order.OrderDate, &order.DeliveryAddress, &distance, &order.Notes, &scheduledFor, &releaseAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Total = order.TotalAmount
+	if distance.Valid {
+		order.DeliveryDistanceKm = &distance.Float64
+	}
+	if scheduledFor.Valid {
+		order.ScheduledFor = &scheduledFor.Time
+	}
+	if releaseAt.Valid {
+		order.ReleaseAt = &releaseAt.Time
+	}
+	return &order, nil
+}
+
+// pricedOrder is an order request checked and priced by prepareOrder
+type pricedOrder struct {
+	currency  string
+	menuItems map[int]MenuItem
+	prices    []Money
+	pricing   PriceBreakdown
+	// releaseAt is set for scheduled orders
+	releaseAt *time.Time
+}
+
+// status returns the status the order is placed in
+func (p *pricedOrder) status() string {
+	if p.releaseAt != nil {
+		return "scheduled"
+	}
+	return "pending"
+}
+
+// prepareOrder checks req and prices it from the menu, the pricing of its
+// restaurant and its promotions, returning an *OrderError if it cannot be
+// placed. A customer ID of 0 is not checked. With lock, the ordered menu
+// items and the promotions with usage limits stay locked until the
+// transaction q belongs to ends.
+func (s *SQLStore) prepareOrder(ctx context.Context, q sqlConn, req CreateOrderRequest, lock bool) (*pricedOrder, error) {
+	ids := make([]interface{}, len(req.Items))
+	for i, item := range req.Items {
+		ids[i] = item.MenuItemID
+	}
+	if lock {
+		if err := s.lockOrderedMenuItems(ctx, q, ids); err != nil {
+			return nil, err
+		}
+	}
+	query := `SELECT id, restaurant_id, price, category_id, category, kind, is_available FROM menu_items WHERE id IN (` + placeholders(len(ids)) + `)`
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return nil, err
+	}
+	var found []MenuItem
+	for rows.Next() {
+		var m MenuItem
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.RestaurantID, &m.Price, &categoryID, &m.Category, &m.Kind, &m.IsAvailable); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if categoryID.Valid {
+			id := int(categoryID.Int64)
+			m.CategoryID = &id
+		}
+		found = append(found, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.attachMenuItemRelations(ctx, q, found); err != nil {
+		return nil, err
+	}
+	menuItems := make(map[int]MenuItem, len(found))
+	for _, m := range found {
+		menuItems[m.ID] = m
+	}
+
+	customerExists := req.CustomerID == 0
+	if !customerExists {
+		query = `SELECT EXISTS(SELECT 1 FROM customers WHERE id = ?)`
+		if err := q.QueryRowContext(ctx, query, req.CustomerID).Scan(&customerExists); err != nil {
+			return nil, err
+		}
+	}
+	restaurant := Restaurant{ID: req.RestaurantID}
+	query = `SELECT currency, time_zone, ordering_paused, prep_time_minutes FROM restaurants WHERE id = ?`
+	err = q.QueryRowContext(ctx, query, req.RestaurantID).Scan(&restaurant.Currency, &restaurant.TimeZone, &restaurant.OrderingPaused, &restaurant.PrepTimeMinutes)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	restaurantExists := err == nil
+	currency := restaurant.Currency
+	now := time.Now()
+	var categories []Category
+	hours := OpeningHours{
+		RestaurantID:    req.RestaurantID,
+		TimeZone:        restaurant.TimeZone,
+		OrderingPaused:  restaurant.OrderingPaused,
+		PrepTimeMinutes: restaurant.PrepTimeMinutes,
+	}
+	pricing := RestaurantPricing{RestaurantID: req.RestaurantID}
+	promotions := orderPromotions{Now: now, Currency: currency, Usage: map[int]promotionUsage{}}
+	if restaurantExists {
+		if categories, err = s.restaurantCategories(ctx, q, req.RestaurantID); err != nil {
+			return nil, err
+		}
+		if err := s.loadOpeningHours(ctx, q, map[int]*OpeningHours{req.RestaurantID: &hours}); err != nil {
+			return nil, err
+		}
+		if err := s.loadRestaurantPricing(ctx, q, &pricing); err != nil {
+			return nil, err
+		}
+		if err := s.loadOrderPromotions(ctx, q, req, &promotions, lock); err != nil {
+			return nil, err
+		}
+	}
+	clock := newMenuClock(restaurant, categories, req.orderTime(now))
+	if oerr := checkOrder(req, customerExists, restaurantExists, menuItems, hours, clock, pricing, promotions); oerr != nil {
+		return nil, oerr
+	}
+
+	prices, breakdown, _ := priceOrder(req, menuItems, pricing, promotions)
+	return &pricedOrder{currency: currency, menuItems: menuItems, prices: prices, pricing: breakdown, releaseAt: hours.releaseAt(req.ScheduledFor)}, nil
+}
+
+// lockOrderedMenuItems locks the menu items with the given IDs, the slots
+// of those that are bundles and their components until the transaction
+// ends. The slots are locked first, as UpdateBundleSlots does, so that the
+// components read from them cannot be swapped. The menu items and the
+// components are then locked in one statement in ID order, so that orders
+// sharing items cannot deadlock, and before they are read, so that the order
+// is checked against rows nobody else can change.
+func (s *SQLStore) lockOrderedMenuItems(ctx context.Context, q sqlConn, ids []interface{}) error {
+	if s.forUpdate() == "" {
+		return nil
+	}
+	query := `SELECT i.menu_item_id FROM bundle_slots b LEFT JOIN bundle_slot_items i ON i.slot_id = b.id
+		WHERE b.bundle_id IN (` + placeholders(len(ids)) + `) ORDER BY b.id, i.id` + s.forUpdate()
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	locked := append([]interface{}{}, ids...)
+	for rows.Next() {
+		var component sql.NullInt64
+		if err := rows.Scan(&component); err != nil {
+			rows.Close()
+			return err
+		}
+		if component.Valid {
+			locked = append(locked, component.Int64)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `SELECT id FROM menu_items WHERE id IN (` + placeholders(len(locked)) + `) ORDER BY id` + s.forUpdate()
+	return lockRows(ctx, q, query, locked...)
+}
+
+// lockRows runs query, a SELECT ... FOR UPDATE, for the locks it takes
+func lockRows(ctx context.Context, q sqlConn, query string, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		// Only the locks are needed
+	}
+	return rows.Err()
+}
+
+// CreateOrder checks and prices the order and inserts it in one transaction
+func (s *SQLStore) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Order, error) {
+	var orderID int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		priced, err := s.prepareOrder(ctx, tx, req, true)
+		if err != nil {
+			return err
+		}
+
+		// Create order, priced in the restaurant's currency
+		p := priced.pricing
+		query := `
+			INSERT INTO orders (customer_id, restaurant_id, subtotal, discount_amount, discount_lines, service_charge, delivery_fee,
+				tip_amount, tax_amount, tax_inclusive, tax_lines, total_amount, currency, status, delivery_address, delivery_distance_km, notes,
+				scheduled_for, release_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		var scheduledFor, releaseAt interface{}
+		if req.ScheduledFor != nil {
+			scheduledFor, releaseAt = s.sqlArg(*req.ScheduledFor), s.sqlArg(*priced.releaseAt)
+		}
+		result, err := tx.ExecContext(ctx, query, req.CustomerID, req.RestaurantID,
+			p.Subtotal, p.Discount, p.Discounts, p.ServiceCharge, p.DeliveryFee, p.Tip, p.TaxTotal, p.TaxInclusive, p.Taxes,
+			p.Total, priced.currency, priced.status(), req.DeliveryAddress, req.DeliveryDistanceKm, req.Notes, scheduledFor, releaseAt)
+		if err != nil {
+			refs := map[string]int{"customer_id": req.CustomerID, "restaurant_id": req.RestaurantID}
+			return writeError(ctx, tx, err, "orders", refs)
+		}
+
+		orderID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// Create order items with copies of their options, each bundle
+		// followed by the lines of its components
+		itemQuery := `INSERT INTO order_items (order_id, menu_item_id, quantity, unit_price, bundle_item_id, bundle_slot) VALUES (?, ?, ?, ?, ?, ?)`
+		optionQuery := `INSERT INTO order_item_options (order_item_id, option_id, group_name, name, price_delta) VALUES (?, ?, ?, ?, ?)`
+		for i, item := range req.Items {
+			result, err := tx.ExecContext(ctx, itemQuery, orderID, item.MenuItemID, item.Quantity, priced.prices[i], nil, "")
+			if err != nil {
+				return writeError(ctx, tx, err, "order_items", map[string]int{"menu_item_id": item.MenuItemID})
+			}
+			itemID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			m := priced.menuItems[item.MenuItemID]
+			for _, o := range m.selectedOptions(item.OptionIDs) {
+				if _, err := tx.ExecContext(ctx, optionQuery, itemID, o.OptionID, o.GroupName, o.Name, o.PriceDelta); err != nil {
+					return writeError(ctx, tx, err, "order_item_options", map[string]int{"option_id": *o.OptionID})
+				}
+			}
+			for _, c := range m.components(item.BundleChoices) {
+				_, err := tx.ExecContext(ctx, itemQuery, orderID, c.Item.MenuItemID, item.Quantity*c.Slot.Quantity, 0, itemID, c.Slot.Name)
+				if err != nil {
+					return writeError(ctx, tx, err, "order_items", map[string]int{"menu_item_id": c.Item.MenuItemID})
+				}
+			}
+		}
+
+		// Record the promotions the order got against their usage limits
+		redemptionQuery := `INSERT INTO promotion_redemptions (promotion_id, order_id, customer_id, amount) VALUES (?, ?, ?, ?)`
+		for _, line := range p.Discounts {
+			if _, err := tx.ExecContext(ctx, redemptionQuery, line.PromotionID, orderID, req.CustomerID, line.Amount); err != nil {
+				return writeError(ctx, tx, err, "promotion_redemptions", map[string]int{"promotion_id": line.PromotionID})
+			}
+		}
+		return insertStatusEvent(ctx, tx, OrderStatusEvent{OrderID: int(orderID), ToStatus: priced.status()})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrder(ctx, int(orderID))
+}
+
+// QuoteOrder prices req like CreateOrder without locking or storing anything
+func (s *SQLStore) QuoteOrder(ctx context.Context, req CreateOrderRequest) (*OrderQuote, error) {
+	priced, err := s.prepareOrder(ctx, s.db, req, false)
+	if err != nil {
+		return nil, err
+	}
+	return newOrderQuote(req, priced.currency, priced.prices, priced.pricing), nil
+}
+
+// ListOrders returns a page of orders with the related records selected by
+// opts.Include, newest first by default
+func (s *SQLStore) ListOrders(ctx context.Context, opts ListOptions) ([]Order, PageInfo, error) {
+	orders := []Order{}
+	info, err := s.list(ctx, orderListing, orderColumns, opts, func(row rowScanner) error {
+		order, err := scanOrder(row)
+		if err != nil {
+			return err
+		}
+		orders = append(orders, *order)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+
+	if err := s.loadOrderRelations(ctx, orders, opts); err != nil {
+		return nil, info, err
+	}
+	return orders, info, nil
+}
+
+// GetOrder returns an order by ID together with its items and their menu items
+func (s *SQLStore) GetOrder(ctx context.Context, id int) (*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = ?`
+	order, err := scanOrder(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	orders := []Order{*order}
+	if err := s.loadOrderRelations(ctx, orders, ListOptions{Include: orderListing.defaultInclude}); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// loadOrderRelations attaches the related records selected by opts.Include
+// to orders. Each relation is loaded with a single query however many
+// orders there are.
+func (s *SQLStore) loadOrderRelations(ctx context.Context, orders []Order, opts ListOptions) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	if opts.includes("items") {
+		orderIDs := make([]int, len(orders))
+		for i, o := range orders {
+			orderIDs[i] = o.ID
+		}
+		items := make(map[int][]OrderItem)
+		var menuItemIDs []int
+		err := s.selectIn(ctx, s.db, `SELECT `+orderItemColumns+` FROM order_items`, "order_id", orderIDs, " ORDER BY id", func(row rowScanner) error {
+			var item OrderItem
+			var bundleItemID sql.NullInt64
+			if err := row.Scan(&item.ID, &item.OrderID, &item.MenuItemID, &item.Quantity, &item.UnitPrice, &bundleItemID, &item.BundleSlot); err != nil {
+				return err
+			}
+			if bundleItemID.Valid {
+				id := int(bundleItemID.Int64)
+				item.BundleItemID = &id
+			}
+			items[item.OrderID] = append(items[item.OrderID], item)
+			menuItemIDs = append(menuItemIDs, item.MenuItemID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		options := make(map[int][]OrderItemOption)
+		// Selected by order so the number of queries does not grow with the items
+		query := `SELECT o.id, o.order_item_id, o.option_id, o.group_name, o.name, o.price_delta FROM order_item_options o JOIN order_items i ON i.id = o.order_item_id`
+		err = s.selectIn(ctx, s.db, query, "i.order_id", orderIDs, " ORDER BY o.id", func(row rowScanner) error {
+			var o OrderItemOption
+			var optionID sql.NullInt64
+			if err := row.Scan(&o.ID, &o.OrderItemID, &optionID, &o.GroupName, &o.Name, &o.PriceDelta); err != nil {
+				return err
+			}
+			if optionID.Valid {
+				id := int(optionID.Int64)
+				o.OptionID = &id
+			}
+			options[o.OrderItemID] = append(options[o.OrderItemID], o)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		menuItems := make(map[int]*MenuItem)
+		if opts.includes("items.menu_item") {
+			err := s.selectIn(ctx, s.db, `SELECT `+menuItemColumns+` FROM menu_items`, "id", menuItemIDs, "", func(row rowScanner) error {
+				m, err := scanMenuItem(row)
+				if err == nil {
+					menuItems[m.ID] = m
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range orders {
+			orders[i].Items = items[orders[i].ID]
+			for j := range orders[i].Items {
+				orders[i].Items[j].Options = options[orders[i].Items[j].ID]
+				// Orders share the menu item records; copy them so the
+				// response does not alias one pointer across orders
+				if m, ok := menuItems[orders[i].Items[j].MenuItemID]; ok {
+					m := *m
+					orders[i].Items[j].MenuItem = &m
+				}
+			}
+		}
+	}
+
+	if opts.includes("customer") {
+		ids := make([]int, len(orders))
+		for i, o := range orders {
+			ids[i] = o.CustomerID
+		}
+		customers := make(map[int]*Customer)
+		err := s.selectIn(ctx, s.db, `SELECT `+customerColumns+` FROM customers`, "id", ids, "", func(row rowScanner) error {
+			c, err := scanCustomer(row)
+			if err == nil {
+				customers[c.ID] = c
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for i := range orders {
+			orders[i].Customer = customers[orders[i].CustomerID]
+		}
+	}
+
+	if opts.includes("restaurant") {
+		ids := make([]int, len(orders))
+		for i, o := range orders {
+			ids[i] = o.RestaurantID
+		}
+		restaurants := make(map[int]*Restaurant)
+		err := s.selectIn(ctx, s.db, `SELECT `+restaurantColumns+` FROM restaurants`, "id", ids, "", func(row rowScanner) error {
+			r, err := scanRestaurant(row)
+			if err == nil {
+				restaurants[r.ID] = r
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		list := make([]Restaurant, 0, len(restaurants))
+		for _, r := range restaurants {
+			list = append(list, *r)
+		}
+		if err := s.attachOpenStatus(ctx, s.db, list); err != nil {
+			return err
+		}
+		for i := range list {
+			restaurants[list[i].ID] = &list[i]
+		}
+		for i := range orders {
+			orders[i].Restaurant = restaurants[orders[i].RestaurantID]
+		}
+	}
+	return nil
+}
+
+// maxInValues caps the values bound to one IN list, staying well below the
+// placeholder limits of MySQL and SQLite
+const maxInValues = 1000
+
+// selectIn runs query with q, a SELECT without a WHERE clause, for the rows whose
+// column is one of ids and calls scan for each row. Duplicate ids are sent
+// once; lists longer than maxInValues are split into several queries.
+func (s *SQLStore) selectIn(ctx context.Context, q sqlConn, query, column string, ids []int, suffix string, scan func(rowScanner) error) error {
+	seen := make(map[int]bool, len(ids))
+	var args []interface{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+
+	for len(args) > 0 {
+		n := len(args)
+		if n > maxInValues {
+			n = maxInValues
+		}
+		chunk := args[:n]
+		args = args[n:]
+
+		rows, err := q.QueryContext(ctx, query+` WHERE `+column+` IN (`+placeholders(len(chunk))+`)`+suffix, chunk...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateOrderStatus moves an order to req.Status, records the change and
+// returns the stored order. The order is locked while the transition is
+// checked, so concurrent updates cannot both move it out of the same status.
+func (s *SQLStore) UpdateOrderStatus(ctx context.Context, id int, req UpdateOrderStatusRequest) (*Order, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var current string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = ?`+s.forUpdate(), id).Scan(&current)
+		if err != nil {
+			return notFound(err)
+		}
+		if !canTransitionOrder(current, req.Status) {
+			return &TransitionError{From: current, To: req.Status}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE orders SET status = ? WHERE id = ?`, req.Status, id); err != nil {
+			return writeError(ctx, tx, err, "orders", nil)
+		}
+		// Cancelled orders give their promotions back
+		if req.Status == "cancelled" {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM promotion_redemptions WHERE order_id = ?`, id); err != nil {
+				return err
+			}
+		}
+		return insertStatusEvent(ctx, tx, OrderStatusEvent{
+			OrderID:    id,
+			FromStatus: current,
+			ToStatus:   req.Status,
+			Actor:      req.Actor,
+			Reason:     req.Reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetOrder(ctx, id)
+}
+
+// ReleaseScheduledOrders moves up to limit scheduled orders whose release
+// time has come by now to pending, the earliest first, in one transaction.
+// Replicas can release at the same time: on MySQL each skips the orders
+// another one has locked, and an order only moves if it is still
+// scheduled.
+func (s *SQLStore) ReleaseScheduledOrders(ctx context.Context, now time.Time, limit int) (int, error) {
+	released := 0
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		query := `SELECT id FROM orders WHERE status = 'scheduled' AND release_at <= ? ORDER BY release_at, id LIMIT ?`
+		if s.db.Driver != "sqlite" {
+			query += ` FOR UPDATE SKIP LOCKED`
+		}
+		rows, err := tx.QueryContext(ctx, query, s.sqlArg(now), limit)
+		if err != nil {
+			return err
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			result, err := tx.ExecContext(ctx, `UPDATE orders SET status = 'pending' WHERE id = ? AND status = 'scheduled'`, id)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err != nil || n == 0 {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if err := insertStatusEvent(ctx, tx, releaseEvent(id)); err != nil {
+				return err
+			}
+			released++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return released, nil
+}
+
+// insertStatusEvent adds an event to the status history of an order, stamped
+// with the database clock. Empty strings are stored as NULL.
+func insertStatusEvent(ctx context.Context, tx *sql.Tx, e OrderStatusEvent) error {
+	nullable := func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	query := `INSERT INTO order_status_events (order_id, from_status, to_status, actor, reason) VALUES (?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, e.OrderID, nullable(e.FromStatus), e.ToStatus, nullable(e.Actor), nullable(e.Reason))
+	if err != nil {
+		return writeError(ctx, tx, err, "order_status_events", map[string]int{"order_id": e.OrderID})
+	}
+	return nil
+}
+
+// ListOrderStatusEvents returns the status history of an order, oldest first
+func (s *SQLStore) ListOrderStatusEvents(ctx context.Context, orderID int) ([]OrderStatusEvent, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM orders WHERE id = ?)`, orderID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	query := `
+		SELECT id, order_id, COALESCE(from_status, ''), to_status, COALESCE(actor, ''), COALESCE(reason, ''), created_at
+		FROM order_status_events
+		WHERE order_id = ?
+		ORDER BY id
+	`
+	rows, err := s.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []OrderStatusEvent{}
+	for rows.Next() {
+		var e OrderStatusEvent
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.FromStatus, &e.ToStatus, &e.Actor, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DeleteOrder removes an order; its items are removed by ON DELETE CASCADE
+func (s *SQLStore) DeleteOrder(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, id)
+	if err != nil {
+		return deleteError(ctx, s.db, err, "orders", id)
+	}
+	return requireAffected(result)
+}
+
+const promotionColumns = `id, code, name, description, restaurant_id, currency, kind, percent_off, amount_off, menu_item_id, category,
+	buy_quantity, get_quantity, min_subtotal, max_redemptions, max_redemptions_per_customer, starts_at, ends_at, is_active,
+	(SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_redemptions.promotion_id = promotions.id), created_at, updated_at`
+
+func scanPromotion(row rowScanner) (*Promotion, error) {
+	var p Promotion
+	var code, description, category sql.NullString
+	var restaurantID, menuItemID sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&p.ID, &code, &p.Name, &description, &restaurantID, &p.Currency, &p.Kind, &p.PercentOff, &p.AmountOff,
+		&menuItemID, &category, &p.BuyQuantity, &p.GetQuantity, &p.MinSubtotal, &p.MaxRedemptions, &p.MaxRedemptionsPerCustomer,
+		&startsAt, &endsAt, &p.IsActive, &p.Redemptions, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Code, p.Description, p.Category = code.String, description.String, category.String
+	if restaurantID.Valid {
+		id := int(restaurantID.Int64)
+		p.RestaurantID = &id
+	}
+	if menuItemID.Valid {
+		id := int(menuItemID.Int64)
+		p.MenuItemID = &id
+	}
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	return &p, nil
+}
+
+// promotionArgs returns the column values of req in the order of the
+// INSERT and UPDATE statements of promotions. A promotion without a code
+// stores NULL, which UNIQUE allows any number of times.
+func (s *SQLStore) promotionArgs(req PromotionRequest) []interface{} {
+	var code interface{}
+	if req.Code != "" {
+		code = req.Code
+	}
+	timeArg := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return s.sqlArg(*t)
+	}
+	return []interface{}{code, req.Name, req.Description, req.RestaurantID, req.Kind, req.PercentOff, req.AmountOff,
+		req.MenuItemID, req.Category, req.BuyQuantity, req.GetQuantity, req.MinSubtotal, req.MaxRedemptions,
+		req.MaxRedemptionsPerCustomer, timeArg(req.StartsAt), timeArg(req.EndsAt), req.IsActive}
+}
+
+// promotionRefs returns the references of req for writeError
+func promotionRefs(req PromotionRequest) map[string]int {
+	refs := map[string]int{}
+	if req.RestaurantID != nil {
+		refs["restaurant_id"] = *req.RestaurantID
+	}
+	if req.MenuItemID != nil {
+		refs["menu_item_id"] = *req.MenuItemID
+	}
+	return refs
+}
+
+// promotionRestaurantID returns the restaurant_id argument of the currency
+// subquery, which finds no restaurant for promotions of every restaurant
+func promotionRestaurantID(req PromotionRequest) int {
+	if req.RestaurantID == nil {
+		return 0
+	}
+	return *req.RestaurantID
+}
+
+// CreatePromotion inserts a promotion and returns the stored row
+func (s *SQLStore) CreatePromotion(ctx context.Context, req PromotionRequest) (*Promotion, error) {
+	query := `
+		INSERT INTO promotions (code, name, description, restaurant_id, kind, percent_off, amount_off, menu_item_id, category,
+			buy_quantity, get_quantity, min_subtotal, max_redemptions, max_redemptions_per_customer, starts_at, ends_at, is_active, currency)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+			COALESCE(NULLIF(?, ''), (SELECT currency FROM restaurants WHERE id = ?), ?))
+	`
+	args := append(s.promotionArgs(req), req.Currency, promotionRestaurantID(req), DefaultCurrency)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "promotions", promotionRefs(req))
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPromotion(ctx, int(id))
+}
+
+// ListPromotions returns a page of promotions, newest first by default
+func (s *SQLStore) ListPromotions(ctx context.Context, opts ListOptions) ([]Promotion, PageInfo, error) {
+	promotions := []Promotion{}
+	info, err := s.list(ctx, promotionListing, promotionColumns, opts, func(row rowScanner) error {
+		p, err := scanPromotion(row)
+		if err != nil {
+			return err
+		}
+		promotions = append(promotions, *p)
+		return nil
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	return promotions, info, nil
+}
+
+// GetPromotion returns a promotion by ID
+func (s *SQLStore) GetPromotion(ctx context.Context, id int) (*Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = ?`
+	p, err := scanPromotion(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+// UpdatePromotion overwrites a promotion and returns the stored row. Left
+// out of the update, the currency follows the restaurant or stays as is.
+func (s *SQLStore) UpdatePromotion(ctx context.Context, id int, req PromotionRequest) (*Promotion, error) {
+	query := `
+		UPDATE promotions SET code = ?, name = ?, description = ?, restaurant_id = ?, kind = ?, percent_off = ?, amount_off = ?,
+			menu_item_id = ?, category = ?, buy_quantity = ?, get_quantity = ?, min_subtotal = ?, max_redemptions = ?,
+			max_redemptions_per_customer = ?, starts_at = ?, ends_at = ?, is_active = ?,
+			currency = COALESCE(NULLIF(?, ''), (SELECT currency FROM restaurants WHERE id = ?), currency)
+		WHERE id = ?
+	`
+	args := append(s.promotionArgs(req), req.Currency, promotionRestaurantID(req), id)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, writeError(ctx, s.db, err, "promotions", promotionRefs(req))
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return s.GetPromotion(ctx, id)
+}
+
+// DeletePromotion removes a promotion no order has redeemed
+func (s *SQLStore) DeletePromotion(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM promotions WHERE id = ?`, id)
+	if err != nil {
+		return deleteError(ctx, s.db, err, "promotions", id)
+	}
+	return requireAffected(result)
+}
+
+// loadOrderPromotions loads the promotions req may get into promotions and
+// counts the redemptions of those with usage limits. With lock, those stay
+// locked until the transaction q belongs to ends, so concurrent orders
+// cannot both take the last redemption; the redemptions are counted with a
+// locking read so the count includes orders committed since the
+// transaction began.
+func (s *SQLStore) loadOrderPromotions(ctx context.Context, q sqlConn, req CreateOrderRequest, promotions *orderPromotions, lock bool) error {
+	query := `
+		SELECT ` + promotionColumns + ` FROM promotions
+		WHERE is_active = TRUE AND ((code IS NULL AND (restaurant_id IS NULL OR restaurant_id = ?)) OR code = ?)
+		ORDER BY id
+	`
+	rows, err := q.QueryContext(ctx, query, req.RestaurantID, req.PromotionCode)
+	if err != nil {
+		return err
+	}
+	var limited []interface{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		promotions.Candidates = append(promotions.Candidates, *p)
+		if p.MaxRedemptions > 0 || p.MaxRedemptionsPerCustomer > 0 {
+			limited = append(limited, p.ID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(limited) == 0 {
+		return nil
+	}
+
+	// SQLite transactions hold the write lock already
+	if lock && s.forUpdate() != "" {
+		var n int
+		query = `SELECT COUNT(*) FROM promotions WHERE id IN (` + placeholders(len(limited)) + `)` + s.forUpdate()
+		if err := q.QueryRowContext(ctx, query, limited...).Scan(&n); err != nil {
+			return err
+		}
+	}
+
+	query = `
+		SELECT promotion_id, COUNT(*), SUM(CASE WHEN customer_id = ? THEN 1 ELSE 0 END)
+		FROM promotion_redemptions
+		WHERE promotion_id IN (` + placeholders(len(limited)) + `)
+		GROUP BY promotion_id
+	`
+	if lock {
+		query += s.forUpdate()
+	}
+	rows, err = q.QueryContext(ctx, query, append([]interface{}{req.CustomerID}, limited...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var usage promotionUsage
+		if err := rows.Scan(&id, &usage.Total, &usage.Customer); err != nil {
+			return err
+		}
+		promotions.Usage[id] = usage
+	}
+	return rows.Err()
+}
+
+const idempotencyColumns = `request_hash, COALESCE(status_code, 0), COALESCE(content_type, ''), response_body, created_at, expires_at`
+
+// ClaimIdempotencyKey inserts rec as in progress. When the key is taken the
+// existing row is returned if it is still live, and replaced by rec if not.
+func (s *SQLStore) ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (*IdempotencyRecord, error) {
+	var stored *IdempotencyRecord
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query, rec.Scope, rec.Key, rec.RequestHash, s.sqlArg(rec.CreatedAt), s.sqlArg(rec.ExpiresAt))
+		if err == nil {
+			return nil
+		}
+		if cerr := classifyConstraint(err, "idempotency_keys", false); cerr == nil || cerr.Kind != UniqueViolation {
+			return err
+		}
+
+		existing := IdempotencyRecord{Scope: rec.Scope, Key: rec.Key}
+		query = `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE scope = ? AND idempotency_key = ?` + s.forUpdate()
+		err = tx.QueryRowContext(ctx, query, rec.Scope, rec.Key).Scan(
+			&existing.RequestHash, &existing.StatusCode, &existing.ContentType, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if existing.live(rec.CreatedAt) {
+			stored = &existing
+			return nil
+		}
+
+		// The record expired or its request was abandoned
+		query = `
+			UPDATE idempotency_keys
+			SET request_hash = ?, status_code = NULL, content_type = NULL, response_body = NULL, created_at = ?, expires_at = ?
+			WHERE scope = ? AND idempotency_key = ?
+		`
+		_, err = tx.ExecContext(ctx, query, rec.RequestHash, s.sqlArg(rec.CreatedAt), s.sqlArg(rec.ExpiresAt), rec.Scope, rec.Key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// CompleteIdempotencyKey stores the response of rec and deletes expired rows
+func (s *SQLStore) CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	query := `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, response_body = ?
+		WHERE ` + idempotencyClaim
+	result, err := s.db.ExecContext(ctx, query, rec.StatusCode, rec.ContentType, rec.Body, rec.Scope, rec.Key, rec.RequestHash, s.sqlArg(rec.CreatedAt))
+	if err != nil {
+		return err
+	}
+	if err := requireClaim(result); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < ?`, s.sqlArg(rec.CreatedAt))
+	return err
+}
+
+// ReleaseIdempotencyKey deletes the row of a key that is still in progress
+func (s *SQLStore) ReleaseIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	query := `DELETE FROM idempotency_keys WHERE ` + idempotencyClaim
+	result, err := s.db.ExecContext(ctx, query, rec.Scope, rec.Key, rec.RequestHash, s.sqlArg(rec.CreatedAt))
+	if err != nil {
+		return err
+	}
+	return requireClaim(result)
+}
+
+// idempotencyClaim selects the row of a key while it is held by the claim
+// with the given request hash and creation time. A retry taking the key
+// over after the lock timeout resets both.
+const idempotencyClaim = `scope = ? AND idempotency_key = ? AND request_hash = ? AND created_at = ? AND status_code IS NULL`
+
+// requireClaim returns ErrIdempotencyKeyLost unless result changed a row
+func requireClaim(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"modernc.org/sqlite"
+)
+
+// queryCount counts the statements prepared through the "sqlite-counting" driver
+var queryCount atomic.Int64
+
+func init() {
+	sql.Register("sqlite-counting", countingDriver{&sqlite.Driver{}})
+}
+
+// countingDriver wraps a driver and counts every statement it runs. Its
+// connections only expose driver.Conn, so database/sql prepares each
+// statement through Prepare.
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	queryCount.Add(1)
+	return c.Conn.Prepare(query)
+}
+
+// newCountingStore creates a migrated SQLite store with orders generated
+// from seed data
+func newCountingStore(tb testing.TB, orders int) *SQLStore {
+	tb.Helper()
+	ctx := context.Background()
+
+	path := filepath.Join(tb.TempDir(), "bench.db")
+	sqlDB, err := sql.Open("sqlite-counting", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db := &Database{DB: sqlDB, Driver: "sqlite"}
+	tb.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		tb.Fatal(err)
+	}
+
+	store := NewSQLStore(db)
+	fixtures := GenerateFixtures(GenerateOptions{
+		Restaurants:            5,
+		MenuItemsPerRestaurant: 10,
+		Customers:              20,
+		Orders:                 orders,
+		Seed:                   1,
+	})
+	if err := NewSeeder(store).Load(ctx, fixtures); err != nil {
+		tb.Fatal(err)
+	}
+	return store
+}
+
+// countListOrders returns the number of statements run by one ListOrders call
+func countListOrders(tb testing.TB, store *SQLStore, opts ListOptions) ([]Order, int64) {
+	tb.Helper()
+	before := queryCount.Load()
+	orders, _, err := store.ListOrders(context.Background(), opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return orders, queryCount.Load() - before
+}
+
+var allOrderIncludes = []string{"items", "items.menu_item", "customer", "restaurant"}
+
+// TestListOrdersQueryCount checks that loading a page of orders takes the
+// same number of queries whatever the page size
+func TestListOrdersQueryCount(t *testing.T) {
+	store := newCountingStore(t, 500)
+
+	for _, include := range [][]string{nil, {"items"}, allOrderIncludes} {
+		// COUNT, SELECT and one query per included relation, plus one for
+		// the options of the items and two for the shifts and closures the
+		// restaurants' opening status is computed from
+		want := int64(2 + len(include))
+		if len(include) > 0 {
+			want++
+		}
+		if (ListOptions{Include: include}).includes("restaurant") {
+			want += 2
+		}
+		for _, limit := range []int{1, 50, 500} {
+			opts := ListOptions{Limit: limit, Include: include}
+			orders, got := countListOrders(t, store, opts)
+			if len(orders) != limit {
+				t.Fatalf("include=%v limit=%d: got %d orders", include, limit, len(orders))
+			}
+			if got != want {
+				t.Errorf("include=%v limit=%d: ran %d queries, want %d", include, limit, got, want)
+			}
+			for _, o := range orders {
+				if opts.includes("items") && (len(o.Items) == 0 || (opts.includes("items.menu_item") && o.Items[0].MenuItem == nil)) {
+					t.Fatalf("include=%v: order %d is missing its items", include, o.ID)
+				}
+				if opts.includes("customer") && (o.Customer == nil || o.Customer.ID != o.CustomerID) {
+					t.Fatalf("include=%v: order %d has the wrong customer", include, o.ID)
+				}
+				if opts.includes("restaurant") && (o.Restaurant == nil || o.Restaurant.ID != o.RestaurantID) {
+					t.Fatalf("include=%v: order %d has the wrong restaurant", include, o.ID)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkListOrders(b *testing.B) {
+	store := newCountingStore(b, 500)
+	ctx := context.Background()
+
+	for _, include := range [][]string{{"items"}, allOrderIncludes} {
+		for _, limit := range []int{10, 100, 500} {
+			name := fmt.Sprintf("include=%s/limit=%d", strings.Join(include, ","), limit)
+			b.Run(name, func(b *testing.B) {
+				opts := ListOptions{Limit: limit, Include: include}
+				before := queryCount.Load()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, _, err := store.ListOrders(ctx, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+				b.ReportMetric(float64(queryCount.Load()-before)/float64(b.N), "queries/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// foreignKey describes one foreign key of the schema in migrations/
+type foreignKey struct {
+	table    string
+	column   string
+	refTable string
+	cascade  bool // ON DELETE CASCADE rather than RESTRICT
+	setNull  bool // ON DELETE SET NULL, which never blocks a delete
+}
+
+var foreignKeys = []foreignKey{
+	{table: "menu_items", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "orders", column: "customer_id", refTable: "customers"},
+	{table: "orders", column: "restaurant_id", refTable: "restaurants"},
+	{table: "order_items", column: "order_id", refTable: "orders", cascade: true},
+	{table: "order_items", column: "menu_item_id", refTable: "menu_items"},
+	{table: "order_status_events", column: "order_id", refTable: "orders", cascade: true},
+	{table: "restaurant_pricing", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "restaurant_taxes", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "delivery_fee_bands", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "promotions", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "promotions", column: "menu_item_id", refTable: "menu_items", cascade: true},
+	{table: "promotion_redemptions", column: "promotion_id", refTable: "promotions"},
+	{table: "promotion_redemptions", column: "order_id", refTable: "orders", cascade: true},
+	{table: "option_groups", column: "menu_item_id", refTable: "menu_items", cascade: true},
+	{table: "menu_options", column: "option_group_id", refTable: "option_groups", cascade: true},
+	{table: "order_item_options", column: "order_item_id", refTable: "order_items", cascade: true},
+	{table: "order_item_options", column: "option_id", refTable: "menu_options", setNull: true},
+	{table: "bundle_slots", column: "bundle_id", refTable: "menu_items", cascade: true},
+	{table: "bundle_slot_items", column: "slot_id", refTable: "bundle_slots", cascade: true},
+	{table: "bundle_slot_items", column: "menu_item_id", refTable: "menu_items", cascade: true},
+	{table: "categories", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "categories", column: "parent_id", refTable: "categories", setNull: true},
+	{table: "menu_items", column: "category_id", refTable: "categories", setNull: true},
+	{table: "menu_item_availability", column: "menu_item_id", refTable: "menu_items", cascade: true},
+	{table: "category_availability", column: "category_id", refTable: "categories", cascade: true},
+	{table: "opening_hours", column: "restaurant_id", refTable: "restaurants", cascade: true},
+	{table: "restaurant_closures", column: "restaurant_id", refTable: "restaurants", cascade: true},
+}
+
+// MySQL error numbers for constraint violations
+const (
+	mysqlErrDupEntry             = 1062
+	mysqlErrNoReferencedRow      = 1216
+	mysqlErrRowIsReferenced      = 1217
+	mysqlErrRowIsReferenced2     = 1451
+	mysqlErrNoReferencedRow2     = 1452
+	mysqlErrCheckConstraint      = 3819
+	mysqlErrCheckConstraintMaria = 4025
+)
+
+var (
+	// Duplicate entry 'a@b.c' for key 'customers.email' (the table prefix is MySQL 8 only)
+	mysqlDuplicateKey = regexp.MustCompile("for key '(?:[^'.]+\\.)?([^']+)'")
+	// a foreign key constraint fails (`db`.`orders`, CONSTRAINT `orders_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`))
+	mysqlForeignKey = regexp.MustCompile("\\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `[^`]+` FOREIGN KEY \\(`([^`]+)`\\) REFERENCES `([^`]+)`")
+	// UNIQUE constraint failed: customers.email
+	sqliteColumn = regexp.MustCompile(`constraint failed: (\w+)\.(\w+)`)
+)
+
+// classifyConstraint returns the constraint violation described by err, or
+// nil if err is not one. table is the table the statement wrote to and
+// deleting says whether it was a DELETE; both fill in what the driver
+// leaves out.
+func classifyConstraint(err error, table string, deleting bool) *ConstraintError {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		cerr := &ConstraintError{Table: table, Err: err}
+		switch mysqlErr.Number {
+		case mysqlErrDupEntry:
+			cerr.Kind = UniqueViolation
+			if m := mysqlDuplicateKey.FindStringSubmatch(mysqlErr.Message); m != nil {
+				cerr.Column = m[1]
+				if cerr.Column == "PRIMARY" {
+					cerr.Column = "id"
+				}
+			}
+		case mysqlErrNoReferencedRow, mysqlErrNoReferencedRow2:
+			cerr.Kind = MissingReference
+			if m := mysqlForeignKey.FindStringSubmatch(mysqlErr.Message); m != nil {
+				cerr.Table, cerr.Column, cerr.RefTable = m[1], m[2], m[3]
+			}
+		case mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2:
+			cerr.Kind = StillReferenced
+			if m := mysqlForeignKey.FindStringSubmatch(mysqlErr.Message); m != nil {
+				cerr.RefTable = m[1]
+			}
+		case mysqlErrCheckConstraint, mysqlErrCheckConstraintMaria:
+			cerr.Kind = CheckViolation
+		default:
+			return nil
+		}
+		return cerr
+	}
+
+	// The extended result code is not reliable for foreign keys (violations
+	// found at the end of a statement report SQLITE_CONSTRAINT_TRIGGER), so
+	// the message decides the kind
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT {
+		cerr := &ConstraintError{Table: table, Err: err}
+		msg := sqliteErr.Error()
+		switch {
+		case strings.Contains(msg, "UNIQUE constraint failed"), strings.Contains(msg, "PRIMARY KEY constraint failed"):
+			cerr.Kind = UniqueViolation
+			if m := sqliteColumn.FindStringSubmatch(msg); m != nil {
+				cerr.Table, cerr.Column = m[1], m[2]
+			}
+		case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+			// SQLite does not say which foreign key failed
+			cerr.Kind = MissingReference
+			if deleting {
+				cerr.Kind = StillReferenced
+			}
+		case strings.Contains(msg, "CHECK constraint failed"), strings.Contains(msg, "NOT NULL constraint failed"):
+			cerr.Kind = CheckViolation
+			if m := sqliteColumn.FindStringSubmatch(msg); m != nil {
+				cerr.Table, cerr.Column = m[1], m[2]
+			}
+		default:
+			return nil
+		}
+		return cerr
+	}
+
+	return nil
+}
+
+// writeError converts err from an INSERT or UPDATE on table into a
+// *ConstraintError where possible. refs holds the value written to each
+// foreign key column; when the driver does not name the failing foreign
+// key they are looked up with q to find the one that is missing.
+func writeError(ctx context.Context, q sqlConn, err error, table string, refs map[string]int) error {
+	cerr := classifyConstraint(err, table, false)
+	if cerr == nil {
+		return err
+	}
+	if cerr.Kind == MissingReference && cerr.Column == "" {
+		for _, fk := range foreignKeys {
+			id, ok := refs[fk.column]
+			if fk.table != table || !ok {
+				continue
+			}
+			var exists bool
+			query := `SELECT EXISTS(SELECT 1 FROM ` + fk.refTable + ` WHERE id = ?)`
+			if err := q.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				cerr.Column, cerr.RefTable = fk.column, fk.refTable
+				break
+			}
+		}
+	}
+	return cerr
+}
+
+// deleteError converts err from deleting row id of table into a
+// *ConstraintError where possible, finding the blocking table with q when
+// the driver does not name it
+func deleteError(ctx context.Context, q sqlConn, err error, table string, id int) error {
+	cerr := classifyConstraint(err, table, true)
+	if cerr == nil {
+		return err
+	}
+	if cerr.Kind == StillReferenced && cerr.RefTable == "" {
+		refTable, err := blockingTable(ctx, q, table, "?", id)
+		if err != nil {
+			return err
+		}
+		cerr.RefTable = refTable
+	}
+	return cerr
+}
+
+// blockingTable returns the first table with a RESTRICT foreign key on the
+// rows of table selected by idsQuery. Direct references are checked before
+// following ON DELETE CASCADE keys to the rows that would be deleted with them.
+func blockingTable(ctx context.Context, q sqlConn, table, idsQuery string, args ...interface{}) (string, error) {
+	for _, fk := range foreignKeys {
+		if fk.refTable != table || fk.cascade || fk.setNull {
+			continue
+		}
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM ` + fk.table + ` WHERE ` + fk.column + ` IN (` + idsQuery + `))`
+		if err := q.QueryRowContext(ctx, query, args...).Scan(&exists); err != nil {
+			return "", err
+		}
+		if exists {
+			return fk.table, nil
+		}
+	}
+	for _, fk := range foreignKeys {
+		if fk.refTable != table || !fk.cascade {
+			continue
+		}
+		children := `SELECT id FROM ` + fk.table + ` WHERE ` + fk.column + ` IN (` + idsQuery + `)`
+		refTable, err := blockingTable(ctx, q, fk.table, children, args...)
+		if refTable != "" || err != nil {
+			return refTable, err
+		}
+	}
+	return "", nil
+}
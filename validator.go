@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// RequestValidator checks the `validate` tags on request structs. It is
+// registered as the Echo validator, so handlers call c.Validate after Bind
+// and return its error, a Validation Error listing every failing field.
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+// NewRequestValidator creates a validator that reports fields by their JSON
+// names, knows the order_status rule and checks Money fields in major units
+// and Percent fields in percent
+func NewRequestValidator() *RequestValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("order_status", func(fl validator.FieldLevel) bool {
+		return isValidOrderStatus(fl.Field().String())
+	})
+	// Rules on Money fields are written in major units, e.g. max=99999999.99,
+	// and rules on Percent fields in percent, e.g. lte=100
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(Money).Float64()
+	}, Money(0))
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(Percent).Float64()
+	}, Percent(0))
+	return &RequestValidator{validate: v}
+}
+
+// FieldError describes one failing field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Validate implements echo.Validator
+func (v *RequestValidator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := make([]FieldError, len(errs))
+	for n, fe := range errs {
+		// Drop the struct name: CreateOrderRequest.items[0].quantity -> items[0].quantity
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		fields[n] = FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(field, fe),
+		}
+	}
+	return Validation(fields)
+}
+
+// fieldErrorMessage renders a readable message for the rules used in models.go
+func fieldErrorMessage(field string, fe validator.FieldError) string {
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "min":
+		if isList {
+			return fmt.Sprintf("%s must contain at least %s item(s)", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if isList {
+			return fmt.Sprintf("%s must contain at most %s item(s)", field, fe.Param())
+		}
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "unique":
+		return fmt.Sprintf("%s must not contain duplicates", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(fe.Param()), ", "))
+	case "datetime":
+		return fmt.Sprintf("%s must be formatted like %s", field, fe.Param())
+	case "timezone":
+		return fmt.Sprintf("%s must be an IANA time zone such as Europe/Paris", field)
+	case "iso4217":
+		return fmt.Sprintf("%s must be an ISO 4217 currency code such as USD", field)
+	case "order_status":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(OrderStatuses, ", "))
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+	}
+}